@@ -38,6 +38,9 @@ import (
    "github.com/kabanero-io/events-operator/pkg/connections"
    "github.com/kabanero-io/events-operator/pkg/listeners"
    "github.com/kabanero-io/events-operator/pkg/status"
+   "github.com/kabanero-io/events-operator/pkg/templates"
+   "github.com/kabanero-io/events-operator/pkg/debug"
+   "github.com/kabanero-io/events-operator/pkg/utils"
 
     routev1 "github.com/openshift/api/route/v1"
 
@@ -138,22 +141,56 @@ func main() {
 	}
 
 
+    /* Events in progress are cancelled when the process is shutting down */
+    stopCh := signals.SetupSignalHandler()
+    eventCtx, cancelEvents := context.WithCancel(context.Background())
+    go func() {
+        <-stopCh
+        cancelEvents()
+    }()
+
     /* TODO: get image name from the current running pod. We can't do it due to initialization order issue */
     /* Init events execution environment */
     client := mgr.GetClient()
+    repositoryFileCache := utils.NewRepositoryFileCache(utils.DEFAULT_REPOSITORY_FILE_CACHE_SIZE)
     env := &eventenv.EventEnv {
+        Context: eventCtx,
         Client: client,
+        Cache: mgr.GetCache(),
+        APIReader: mgr.GetAPIReader(),
         EventMgr: managers.NewEventManager(),
         ConnectionsMgr: connections.NewConnectionsManager(),
         ListenerMgr: listeners.NewDefaultListenerManager(),
         StatusMgr: status.NewStatusManager(),
         StatusUpdater: status.NewSatusUpdater(client, operatorNamespace, mediatorName, time.Second*2),
+        TemplateMgr: templates.NewTemplateManager(),
+        DebugMgr: debug.NewDebugManager(),
+        DownloadYAML: repositoryFileCache.DownloadYAML,
+        GetPullRequest: utils.GetPullRequest,
+        GetPermission: utils.GetPermission,
+        ReportStatus: utils.ReportStatus,
+        CompareCommits: utils.CompareCommits,
+        ListFiles: utils.ListRepositoryFiles,
         IsOperator:  isOperator,
         MediatorName: mediatorName,
         Namespace: operatorNamespace,
         KabaneroIntegration: true,
     }
     eventenv.InitEventEnv(env)
+    env.DebugMgr.AddStats("repositoryFileCache", func() interface{} {
+        return repositoryFileCache.Stats()
+    })
+
+    if !isOperator {
+        /* debug endpoint of the worker. It is not exposed through the service */
+        err = env.ListenerMgr.NewListener(env.DebugMgr.Handler(), listeners.ListenerOptions{
+            Host: debug.DEFAULT_DEBUG_HOST,
+            Port: debug.DEFAULT_DEBUG_PORT,
+        })
+        if err != nil {
+            log.Error(err, "Unable to start debug endpoint")
+        }
+    }
 
 	log.Info("Registering Components.")
 
@@ -194,7 +231,7 @@ func main() {
 
 
 	// Start the Cmd
-	if err := mgr.Start(signals.SetupSignalHandler()); err != nil {
+	if err := mgr.Start(stopCh); err != nil {
 		log.Error(err, "Manager exited non-zero")
 		os.Exit(1)
 	}
@@ -0,0 +1,135 @@
+/*
+Copyright 2020 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/* mediationtest runs a recorded request through the mediations of an EventMediator without a cluster.
+   Events are not sent. Instead, the matched mediation, the final variables, and the events that would
+   have been sent are printed, and optionally compared against a golden file.
+*/
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"k8s.io/klog"
+)
+
+/* Flag that may be repeated */
+type stringsFlag []string
+
+func (s *stringsFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringsFlag) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s -mediator <file> -request <file> [options]\n\nOptions:\n", os.Args[0])
+	flag.PrintDefaults()
+}
+
+func main() {
+	klog.InitFlags(nil)
+	opts := &options{}
+	var repoFiles, resourceFiles, permissions, changedFiles stringsFlag
+	var golden string
+	var update, verbose bool
+	flag.StringVar(&opts.mediatorFile, "mediator", "", "file containing the EventMediator")
+	flag.StringVar(&opts.connectionsFile, "connections", "", "file containing EventConnections that route the events sent by the mediations")
+	flag.StringVar(&opts.requestFile, "request", "", "file containing the recorded request: url, header, body, and remoteAddr")
+	flag.Var(&repoFiles, "repo-file", "repository file returned when a mediation downloads it, as <name>=<path>, or <name>@<ref>=<path> for the file at a commit. May be repeated")
+	flag.Var(&resourceFiles, "resources", "file containing Kubernetes resources to be read by the mediation. May be repeated")
+	flag.Var(&permissions, "permission", "repository permission of a GitHub user for ChatOps commands, as <login>=<permission>. May be repeated")
+	flag.Var(&changedFiles, "changed-file", "file changed between two commits, as returned by the GitHub compare API for pull requests and large pushes. May be repeated")
+	flag.StringVar(&opts.pullRequestFile, "pull-request", "", "file containing the pull request, as returned by the GitHub API, of a ChatOps command")
+	flag.StringVar(&opts.namespace, "namespace", "", "namespace of the worker. Defaults to the namespace of the mediator")
+	flag.BoolVar(&opts.kabaneroIntegration, "kabanero", false, "enable Kabanero integration")
+	flag.BoolVar(&opts.trace, "trace", false, "include a step-by-step trace of the evaluation")
+	flag.StringVar(&golden, "golden", "", "golden file to compare the output against")
+	flag.BoolVar(&update, "update", false, "write the output to the golden file instead of comparing")
+	flag.BoolVar(&verbose, "verbose", false, "print the log of the mediator worker")
+	flag.Usage = usage
+	flag.Parse()
+
+	if !verbose {
+		flag.Set("logtostderr", "false")
+		flag.Set("alsologtostderr", "false")
+		flag.Set("stderrthreshold", "FATAL")
+		klog.SetOutput(ioutil.Discard)
+	}
+	if opts.mediatorFile == "" || opts.requestFile == "" {
+		usage()
+		os.Exit(2)
+	}
+	opts.resourceFiles = resourceFiles
+	opts.changedFiles = changedFiles
+
+	opts.repoFiles = make(map[string]string)
+	for _, repoFile := range repoFiles {
+		parts := strings.SplitN(repoFile, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			fmt.Fprintf(os.Stderr, "Invalid -repo-file %v: expecting <name>=<path>\n", repoFile)
+			os.Exit(2)
+		}
+		opts.repoFiles[parts[0]] = parts[1]
+	}
+
+	opts.permissions = make(map[string]string)
+	for _, permission := range permissions {
+		parts := strings.SplitN(permission, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			fmt.Fprintf(os.Stderr, "Invalid -permission %v: expecting <login>=<permission>\n", permission)
+			os.Exit(2)
+		}
+		opts.permissions[parts[0]] = parts[1]
+	}
+
+	output, err := run(opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(2)
+	}
+
+	if golden == "" {
+		os.Stdout.Write(output)
+		return
+	}
+	if update {
+		err = ioutil.WriteFile(golden, output, 0644)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to write golden file %v: %v\n", golden, err)
+			os.Exit(2)
+		}
+		return
+	}
+	expected, err := ioutil.ReadFile(golden)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to read golden file %v: %v\n", golden, err)
+		os.Exit(2)
+	}
+	if !bytes.Equal(expected, output) {
+		fmt.Fprintf(os.Stderr, "Output does not match golden file %v. Actual output:\n", golden)
+		os.Stdout.Write(output)
+		os.Exit(1)
+	}
+}
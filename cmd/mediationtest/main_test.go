@@ -0,0 +1,601 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	eventsv1alpha1 "github.com/kabanero-io/events-operator/pkg/apis/events/v1alpha1"
+	"github.com/kabanero-io/events-operator/pkg/status"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	sigsyaml "sigs.k8s.io/yaml"
+)
+
+func TestMediationTest(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Mediation Test Suite")
+}
+
+var _ = Describe("TestMediationRunner", func() {
+	newOptions := func() *options {
+		return &options{
+			mediatorFile:    "testdata/mediator.yaml",
+			connectionsFile: "testdata/connections.yaml",
+			requestFile:     "testdata/request.yaml",
+			repoFiles:       map[string]string{".appsody-config.yaml": "testdata/appsody-config.yaml"},
+		}
+	}
+
+	/* Return a copy of a file with old replaced by new */
+	replaced := func(fileName string, old string, new string) string {
+		buf, err := ioutil.ReadFile(fileName)
+		Expect(err).ToNot(HaveOccurred())
+		file, err := ioutil.TempFile("", "mediationtest")
+		Expect(err).ToNot(HaveOccurred())
+		_, err = file.Write(bytes.Replace(buf, []byte(old), []byte(new), 1))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(file.Close()).To(Succeed())
+		return file.Name()
+	}
+
+	It("should match the golden file", func() {
+		output, err := run(newOptions())
+		Expect(err).ToNot(HaveOccurred())
+		expected, err := ioutil.ReadFile("testdata/appsody.golden.yaml")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(output)).To(Equal(string(expected)))
+	})
+
+	It("should not match without the repository file", func() {
+		opts := newOptions()
+		opts.repoFiles = map[string]string{}
+		output, err := run(opts)
+		Expect(err).ToNot(HaveOccurred())
+		res := &result{}
+		Expect(sigsyaml.Unmarshal(output, res)).To(Succeed())
+		Expect(res.Mediations).To(BeEmpty())
+		Expect(res.SendEvents).To(BeEmpty())
+	})
+
+	It("should report a missing destination", func() {
+		opts := newOptions()
+		opts.connectionsFile = ""
+		output, err := run(opts)
+		Expect(err).ToNot(HaveOccurred())
+		res := &result{}
+		Expect(sigsyaml.Unmarshal(output, res)).To(Succeed())
+		Expect(res.Mediations).To(HaveLen(1))
+		Expect(res.Mediations[0].Name).To(Equal("appsody"))
+		/* outside of a try, the mediation continues */
+		Expect(res.Mediations[0].Error).To(BeEmpty())
+		Expect(res.Error).To(BeEmpty())
+		Expect(res.SendEvents).To(BeEmpty())
+		Expect(res.Status).ToNot(BeEmpty())
+		Expect(res.Status[0].Operation).To(Equal(status.OPERATION_SEND_EVENT))
+		Expect(res.Status[0].Input).To(ContainElement(eventsv1alpha1.EventStatusParameter{Name: status.PARAM_DESTINATION, Value: "dest"}))
+		Expect(res.Status[0].Result).To(Equal(status.RESULT_FAILED))
+		Expect(res.Status[0].Message).To(Equal("Destination not found in any EventConnection"))
+	})
+
+	It("should include the trace when requested", func() {
+		opts := newOptions()
+		opts.trace = true
+		output, err := run(opts)
+		Expect(err).ToNot(HaveOccurred())
+		res := &result{}
+		Expect(sigsyaml.Unmarshal(output, res)).To(Succeed())
+		Expect(res.Mediations).To(HaveLen(1))
+		trace := res.Mediations[0].Trace
+		Expect(trace).ToNot(BeEmpty())
+		Expect(trace[len(trace)-1].Statement).To(Equal("sendEvent"))
+	})
+
+	It("should process the event with all matching mediations by priority", func() {
+		opts := newOptions()
+		opts.mediatorFile = "testdata/fanout-mediator.yaml"
+		output, err := run(opts)
+		Expect(err).ToNot(HaveOccurred())
+		res := &result{}
+		Expect(sigsyaml.Unmarshal(output, res)).To(Succeed())
+		Expect(res.Error).To(BeEmpty())
+		Expect(res.Mediations).To(HaveLen(2))
+		Expect(res.Mediations[0].Name).To(Equal("audit"))
+		Expect(res.Mediations[0].Variables["audited"]).To(Equal("kabanero-io/demo"))
+		Expect(res.Mediations[1].Name).To(Equal("appsody"))
+		Expect(res.SendEvents).To(HaveLen(1))
+		/* the body assigned by audit is not seen by appsody */
+		Expect(res.SendEvents[0].Payload).ToNot(HaveKey("audited"))
+
+		/* each mediation records its own status */
+		mediations := make([]string, 0)
+		for _, record := range res.Status {
+			for _, param := range record.Input {
+				if param.Name == status.PARAM_MEDIATION && record.Operation == status.OPERATION_EVALUATE_MEDIATION {
+					mediations = append(mediations, param.Value)
+				}
+			}
+		}
+		Expect(mediations).To(ConsistOf("audit", "appsody"))
+	})
+
+	It("should process the event with the matching mediation of highest priority", func() {
+		opts := newOptions()
+		opts.mediatorFile = replaced("testdata/fanout-mediator.yaml", "allMatches", "firstMatch")
+		defer os.Remove(opts.mediatorFile)
+		output, err := run(opts)
+		Expect(err).ToNot(HaveOccurred())
+		res := &result{}
+		Expect(sigsyaml.Unmarshal(output, res)).To(Succeed())
+		Expect(res.Mediations).To(HaveLen(1))
+		Expect(res.Mediations[0].Name).To(Equal("audit"))
+		Expect(res.SendEvents).To(BeEmpty())
+	})
+
+	It("should not download the repository file unless the selector condition matches", func() {
+		/* the repository file can not be read, so matching fails if it is downloaded */
+		opts := newOptions()
+		opts.repoFiles = map[string]string{".appsody-config.yaml": "testdata/missing.yaml"}
+		output, err := run(opts)
+		Expect(err).ToNot(HaveOccurred())
+		res := &result{}
+		Expect(sigsyaml.Unmarshal(output, res)).To(Succeed())
+		Expect(res.Error).ToNot(BeEmpty())
+
+		opts.mediatorFile = replaced("testdata/mediator.yaml", `== "push"`, `== "pull_request"`)
+		defer os.Remove(opts.mediatorFile)
+		output, err = run(opts)
+		Expect(err).ToNot(HaveOccurred())
+		res = &result{}
+		Expect(sigsyaml.Unmarshal(output, res)).To(Succeed())
+		Expect(res.Error).To(BeEmpty())
+		Expect(res.Mediations).To(BeEmpty())
+	})
+
+	It("should capture the parameters of the url pattern", func() {
+		opts := newOptions()
+		mediatorFile := replaced("testdata/mediator.yaml", "urlPattern: webhook", "urlPattern: /teams/{team}/webhook")
+		defer os.Remove(mediatorFile)
+		opts.mediatorFile = replaced(mediatorFile, "        - name: stack\n", "        - name: team\n          valueExpression: 'path.team'\n        - name: stack\n")
+		defer os.Remove(opts.mediatorFile)
+		opts.requestFile = replaced("testdata/request.yaml", "url: /webhook", "url: /teams/blue/webhook")
+		defer os.Remove(opts.requestFile)
+		output, err := run(opts)
+		Expect(err).ToNot(HaveOccurred())
+		res := &result{}
+		Expect(sigsyaml.Unmarshal(output, res)).To(Succeed())
+		Expect(res.Error).To(BeEmpty())
+		Expect(res.Mediations).To(HaveLen(1))
+		Expect(res.Mediations[0].Variables["team"]).To(Equal("blue"))
+		Expect(res.SendEvents).To(HaveLen(1))
+
+		/* the pattern does not match other urls */
+		opts.requestFile = replaced("testdata/request.yaml", "url: /webhook", "url: /teams/blue/other")
+		defer os.Remove(opts.requestFile)
+		output, err = run(opts)
+		Expect(err).ToNot(HaveOccurred())
+		res = &result{}
+		Expect(sigsyaml.Unmarshal(output, res)).To(Succeed())
+		Expect(res.Mediations).To(BeEmpty())
+	})
+
+	It("should record a status when no repository of the mediator matches", func() {
+		opts := newOptions()
+		opts.mediatorFile = replaced("testdata/mediator.yaml", "    - github:", "    - urlPattern: https://github.com/other/*\n      github:")
+		defer os.Remove(opts.mediatorFile)
+		output, err := run(opts)
+		Expect(err).ToNot(HaveOccurred())
+		res := &result{}
+		Expect(sigsyaml.Unmarshal(output, res)).To(Succeed())
+		Expect(res.Error).To(ContainSubstring("no repository"))
+		Expect(res.SendEvents).To(BeEmpty())
+		messages := make([]string, 0)
+		for _, record := range res.Status {
+			messages = append(messages, record.Message)
+		}
+		Expect(messages).To(ContainElement(ContainSubstring("No repository of the mediator matches repository https://github.com/kabanero-io/demo")))
+
+		opts.mediatorFile = replaced("testdata/mediator.yaml", "    - github:", "    - org: kabanero-io\n      github:")
+		defer os.Remove(opts.mediatorFile)
+		output, err = run(opts)
+		Expect(err).ToNot(HaveOccurred())
+		res = &result{}
+		Expect(sigsyaml.Unmarshal(output, res)).To(Succeed())
+		Expect(res.Error).To(BeEmpty())
+		Expect(res.SendEvents).To(HaveLen(1))
+	})
+
+	It("should resolve the listener of the stack of a devfile", func() {
+		opts := newOptions()
+		opts.mediatorFile = "testdata/devfile-mediator.yaml"
+		opts.repoFiles = map[string]string{"devfile.yaml": "testdata/devfile.yaml"}
+		opts.resourceFiles = []string{"testdata/stacks.yaml"}
+		opts.kabaneroIntegration = true
+		output, err := run(opts)
+		Expect(err).ToNot(HaveOccurred())
+		res := &result{}
+		Expect(sigsyaml.Unmarshal(output, res)).To(Succeed())
+		Expect(res.Error).To(BeEmpty())
+		Expect(res.SendEvents).To(HaveLen(1))
+		payload := res.SendEvents[0].Payload.(map[string]interface{})
+		Expect(payload["webhooks-devfile-name"]).To(Equal("demo"))
+		Expect(payload["webhooks-devfile-version"]).To(Equal("1.0.2"))
+		Expect(payload["webhooks-devfile-stack"]).To(Equal("nodejs"))
+		Expect(payload["webhooks-devfile-stack-version"]).To(Equal("0.3"))
+		Expect(payload["webhooks-devfile-attributes"]).To(Equal(map[string]interface{}{"team": "blue", "pipeline": "build-push"}))
+		Expect(payload["webhooks-kabanero-tekton-listener"]).To(Equal("http://el-listener-nodejs-031.tekton-pipelines.svc.cluster.local:8080"))
+
+		/* a devfile of another schema version is rejected */
+		opts.repoFiles = map[string]string{"devfile.yaml": replaced("testdata/devfile.yaml", "schemaVersion: 2.1.0", "schemaVersion: 1.0.0")}
+		defer os.Remove(opts.repoFiles["devfile.yaml"])
+		output, err = run(opts)
+		Expect(err).ToNot(HaveOccurred())
+		res = &result{}
+		Expect(sigsyaml.Unmarshal(output, res)).To(Succeed())
+		Expect(res.Error).To(ContainSubstring("schemaVersion"))
+		Expect(res.SendEvents).To(BeEmpty())
+	})
+
+	It("should resolve the listener of the highest stack version satisfying the constraint", func() {
+		opts := newOptions()
+		opts.resourceFiles = []string{"testdata/stacks.yaml"}
+		opts.kabaneroIntegration = true
+		listener := func() string {
+			output, err := run(opts)
+			Expect(err).ToNot(HaveOccurred())
+			res := &result{}
+			Expect(sigsyaml.Unmarshal(output, res)).To(Succeed())
+			Expect(res.Error).To(BeEmpty())
+			Expect(res.SendEvents).To(HaveLen(1))
+			return res.SendEvents[0].Payload.(map[string]interface{})["webhooks-kabanero-tekton-listener"].(string)
+		}
+
+		Expect(listener()).To(Equal("http://el-listener-nodejs-031.tekton-pipelines.svc.cluster.local:8080"))
+
+		opts.repoFiles = map[string]string{".appsody-config.yaml": replaced("testdata/appsody-config.yaml", "nodejs:0.3", "nodejs:^0.3")}
+		defer os.Remove(opts.repoFiles[".appsody-config.yaml"])
+		Expect(listener()).To(Equal("http://el-listener-nodejs-031.tekton-pipelines.svc.cluster.local:8080"))
+
+		opts.repoFiles = map[string]string{".appsody-config.yaml": replaced("testdata/appsody-config.yaml", "nodejs:0.3", "nodejs:v0.x")}
+		defer os.Remove(opts.repoFiles[".appsody-config.yaml"])
+		Expect(listener()).To(Equal("http://el-listener-nodejs-040.tekton-pipelines.svc.cluster.local:8080"))
+
+		/* the mediator overrides the constraint of the repository */
+		opts.mediatorFile = replaced("testdata/mediator.yaml", "  mediations:\n", "  stackVersions:\n    - stack: docker.io/kabanero/nodejs\n      version: '>=0.3 <0.4'\n  mediations:\n")
+		defer os.Remove(opts.mediatorFile)
+		Expect(listener()).To(Equal("http://el-listener-nodejs-031.tekton-pipelines.svc.cluster.local:8080"))
+	})
+
+	It("should select the listener of a stack version for the event", func() {
+		opts := newOptions()
+		opts.resourceFiles = []string{"testdata/stacks.yaml"}
+		opts.kabaneroIntegration = true
+		listener := func() string {
+			output, err := run(opts)
+			Expect(err).ToNot(HaveOccurred())
+			res := &result{}
+			Expect(sigsyaml.Unmarshal(output, res)).To(Succeed())
+			Expect(res.Error).To(BeEmpty())
+			Expect(res.SendEvents).To(HaveLen(1))
+			return res.SendEvents[0].Payload.(map[string]interface{})["webhooks-kabanero-tekton-listener"].(string)
+		}
+
+		opts.mediatorFile = replaced("testdata/mediator.yaml", "  mediations:\n", "  listenerSelectors:\n    - eventTypes: [ tag ]\n      pipelines: [ release ]\n  mediations:\n")
+		defer os.Remove(opts.mediatorFile)
+		Expect(listener()).To(Equal("http://el-listener-nodejs-031.tekton-pipelines.svc.cluster.local:8080"))
+
+		opts.requestFile = replaced("testdata/request.yaml", "ref: refs/heads/master", "ref: refs/tags/v1.0.0\n  after: 0d1a26e67d8f5eaf1f6ba5c57fc3c7d91ac0fd1c")
+		defer os.Remove(opts.requestFile)
+		Expect(listener()).To(Equal("http://el-listener-nodejs-031-release.tekton-pipelines.svc.cluster.local:8080"))
+
+		/* rules may match the labels of the listener */
+		opts.mediatorFile = replaced("testdata/mediator.yaml", "  mediations:\n", "  listenerSelectors:\n    - stack: docker.io/kabanero/nodejs\n      eventTypes: [ tag ]\n      matchLabels:\n        events.kabanero.io/event-type: tag\n  mediations:\n")
+		defer os.Remove(opts.mediatorFile)
+		Expect(listener()).To(Equal("http://el-listener-nodejs-031-release.tekton-pipelines.svc.cluster.local:8080"))
+	})
+
+	It("should run ChatOps commands of users with permission", func() {
+		opts := newOptions()
+		opts.mediatorFile = "testdata/command-mediator.yaml"
+		opts.requestFile = "testdata/comment-request.yaml"
+		opts.pullRequestFile = "testdata/pull-request.yaml"
+		opts.permissions = map[string]string{"hubot": "admin"}
+		output, err := run(opts)
+		Expect(err).ToNot(HaveOccurred())
+		res := &result{}
+		Expect(sigsyaml.Unmarshal(output, res)).To(Succeed())
+		Expect(res.Error).To(BeEmpty())
+		Expect(res.SendEvents).To(HaveLen(1))
+		payload := res.SendEvents[0].Payload.(map[string]interface{})
+		Expect(payload["environment"]).To(Equal("prod"))
+		Expect(payload["webhooks-tekton-git-branch"]).To(Equal("fix/readme"))
+		Expect(payload["webhooks-tekton-head-sha"]).To(Equal("ec26c3e57ca3a959ca5aad62de7213c562f8c821"))
+		Expect(payload["webhooks-tekton-git-base-branch"]).To(Equal("master"))
+		Expect(payload["webhooks-tekton-pull-request-number"]).To(BeEquivalentTo(4))
+
+		/* other commands do not match */
+		opts.requestFile = replaced("testdata/comment-request.yaml", "/build prod", "/approve")
+		defer os.Remove(opts.requestFile)
+		output, err = run(opts)
+		Expect(err).ToNot(HaveOccurred())
+		res = &result{}
+		Expect(sigsyaml.Unmarshal(output, res)).To(Succeed())
+		Expect(res.Mediations).To(BeEmpty())
+	})
+
+	It("should read the repository files of ChatOps commands at the head of the pull request", func() {
+		opts := newOptions()
+		opts.mediatorFile = replaced("testdata/command-mediator.yaml", "          permission: write\n", "          permission: write\n        repositoryType:\n          newVariable: body.webhooks-appsody-config\n          file: .appsody-config.yaml\n")
+		defer os.Remove(opts.mediatorFile)
+		opts.requestFile = "testdata/comment-request.yaml"
+		opts.pullRequestFile = "testdata/pull-request.yaml"
+		opts.permissions = map[string]string{"hubot": "admin"}
+		opts.repoFiles = map[string]string{".appsody-config.yaml@ec26c3e57ca3a959ca5aad62de7213c562f8c821": "testdata/appsody-config.yaml"}
+		output, err := run(opts)
+		Expect(err).ToNot(HaveOccurred())
+		res := &result{}
+		Expect(sigsyaml.Unmarshal(output, res)).To(Succeed())
+		Expect(res.Error).To(BeEmpty())
+		Expect(res.SendEvents).To(HaveLen(1))
+		payload := res.SendEvents[0].Payload.(map[string]interface{})
+		Expect(payload["webhooks-appsody-config"]).To(HaveKey("stack"))
+	})
+
+	It("should select the listener of a pull request for ChatOps commands", func() {
+		opts := newOptions()
+		opts.mediatorFile = replaced("testdata/command-mediator.yaml", "          permission: write\n", "          permission: write\n        repositoryType:\n          newVariable: body.webhooks-appsody-config\n          file: .appsody-config.yaml\n")
+		defer os.Remove(opts.mediatorFile)
+		mediatorFile := opts.mediatorFile
+		opts.mediatorFile = replaced(mediatorFile, "  mediations:\n", "  listenerSelectors:\n    - eventTypes: [ pull_request ]\n      pipelines: [ release ]\n  mediations:\n")
+		defer os.Remove(opts.mediatorFile)
+		opts.requestFile = "testdata/comment-request.yaml"
+		opts.pullRequestFile = "testdata/pull-request.yaml"
+		opts.permissions = map[string]string{"hubot": "admin"}
+		opts.repoFiles = map[string]string{".appsody-config.yaml@ec26c3e57ca3a959ca5aad62de7213c562f8c821": "testdata/appsody-config.yaml"}
+		opts.resourceFiles = []string{"testdata/stacks.yaml"}
+		opts.kabaneroIntegration = true
+		output, err := run(opts)
+		Expect(err).ToNot(HaveOccurred())
+		res := &result{}
+		Expect(sigsyaml.Unmarshal(output, res)).To(Succeed())
+		Expect(res.Error).To(BeEmpty())
+		Expect(res.SendEvents).To(HaveLen(1))
+		payload := res.SendEvents[0].Payload.(map[string]interface{})
+		Expect(payload["webhooks-tekton-event-type"]).To(Equal("issue_comment"))
+		Expect(payload["webhooks-kabanero-tekton-listener"]).To(Equal("http://el-listener-nodejs-031-release.tekton-pipelines.svc.cluster.local:8080"))
+	})
+
+	It("should not run ChatOps commands of users without permission", func() {
+		opts := newOptions()
+		opts.mediatorFile = "testdata/command-mediator.yaml"
+		opts.requestFile = "testdata/comment-request.yaml"
+		opts.pullRequestFile = "testdata/pull-request.yaml"
+		opts.permissions = map[string]string{"hubot": "read"}
+		output, err := run(opts)
+		Expect(err).ToNot(HaveOccurred())
+		res := &result{}
+		Expect(sigsyaml.Unmarshal(output, res)).To(Succeed())
+		Expect(res.Error).To(BeEmpty())
+		Expect(res.Mediations).To(BeEmpty())
+		Expect(res.SendEvents).To(BeEmpty())
+		messages := make([]string, 0)
+		for _, record := range res.Status {
+			if record.Operation == status.OPERATION_CHECK_COMMAND_PERMISSION {
+				messages = append(messages, record.Message)
+			}
+		}
+		Expect(messages).To(ConsistOf("User hubot has read permission on repository https://github.com/kabanero-io/demo, but command /build requires write permission"))
+	})
+
+	It("should report the status of the delivery on the head commit", func() {
+		opts := newOptions()
+		opts.mediatorFile = replaced("testdata/mediator.yaml", "      sendTo:", "      reportStatus:\n        context: kabanero/build\n      sendTo:")
+		defer os.Remove(opts.mediatorFile)
+		opts.requestFile = replaced("testdata/request.yaml", "  ref: refs/heads/master\n", "  ref: refs/heads/master\n  after: 0d1a26e67d8f5eaf1f6ba5c57fc3c7d91ac0fd1c\n")
+		defer os.Remove(opts.requestFile)
+
+		reports := func() []statusRecord {
+			output, err := run(opts)
+			Expect(err).ToNot(HaveOccurred())
+			res := &result{}
+			Expect(sigsyaml.Unmarshal(output, res)).To(Succeed())
+			ret := make([]statusRecord, 0)
+			for _, record := range res.Status {
+				if record.Operation == status.OPERATION_REPORT_STATUS {
+					ret = append(ret, record)
+				}
+			}
+			return ret
+		}
+
+		/* no event listener for the stack */
+		records := reports()
+		Expect(records).To(HaveLen(1))
+		Expect(records[0].Result).To(Equal(status.RESULT_DRY_RUN))
+		Expect(records[0].Message).To(Equal("Dry run: status kabanero/build error not reported: No event listener found for the stack of the repository. Event sent to dest"))
+
+		opts.resourceFiles = []string{"testdata/stacks.yaml"}
+		opts.kabaneroIntegration = true
+		records = reports()
+		Expect(records).To(HaveLen(1))
+		Expect(records[0].Result).To(Equal(status.RESULT_DRY_RUN))
+		Expect(records[0].Message).To(Equal("Dry run: status kabanero/build pending not reported: Event sent to dest"))
+		Expect(records[0].Input).To(ContainElement(eventsv1alpha1.EventStatusParameter{Name: status.PARAM_COMMIT, Value: "0d1a26e67d8f5eaf1f6ba5c57fc3c7d91ac0fd1c"}))
+
+		/* no listener for the destination */
+		opts.connectionsFile = ""
+		records = reports()
+		Expect(records).To(HaveLen(1))
+		Expect(records[0].Message).To(ContainSubstring("status kabanero/build error not reported: No listener"))
+	})
+
+	It("should not replace an error reported for the event with pending", func() {
+		opts := newOptions()
+		opts.mediatorFile = replaced("testdata/mediator.yaml", "      sendTo: [ \"dest\" ]\n", "      reportStatus:\n        context: kabanero/build\n      sendTo: [ \"dest\", \"missing\" ]\n")
+		defer os.Remove(opts.mediatorFile)
+		mediatorFile := opts.mediatorFile
+		opts.mediatorFile = replaced(mediatorFile, "        - = : 'sendEvent(dest, body, header)'\n", "        - = : 'sendEvent(missing, body, header)'\n        - = : 'sendEvent(dest, body, header)'\n")
+		defer os.Remove(opts.mediatorFile)
+		opts.requestFile = replaced("testdata/request.yaml", "  ref: refs/heads/master\n", "  ref: refs/heads/master\n  after: 0d1a26e67d8f5eaf1f6ba5c57fc3c7d91ac0fd1c\n")
+		defer os.Remove(opts.requestFile)
+		opts.resourceFiles = []string{"testdata/stacks.yaml"}
+		opts.kabaneroIntegration = true
+
+		output, err := run(opts)
+		Expect(err).ToNot(HaveOccurred())
+		res := &result{}
+		Expect(sigsyaml.Unmarshal(output, res)).To(Succeed())
+		Expect(res.SendEvents).To(HaveLen(1))
+		messages := make([]string, 0)
+		for _, record := range res.Status {
+			if record.Operation == status.OPERATION_REPORT_STATUS {
+				messages = append(messages, record.Message)
+			}
+		}
+		Expect(messages).To(HaveLen(1))
+		Expect(messages[0]).To(HavePrefix("Dry run: status kabanero/build error not reported: No listener: destination missing"))
+	})
+
+	It("should process the event once per project of the changed files", func() {
+		opts := newOptions()
+		opts.mediatorFile = "testdata/monorepo-mediator.yaml"
+		opts.requestFile = "testdata/monorepo-request.yaml"
+		opts.repoFiles = map[string]string{
+			"services/api/.appsody-config.yaml": "testdata/api-config.yaml",
+			"services/web/.appsody-config.yaml": "testdata/web-config.yaml",
+			"docs/.appsody-config.yaml":         "testdata/appsody-config.yaml",
+		}
+		output, err := run(opts)
+		Expect(err).ToNot(HaveOccurred())
+		res := &result{}
+		Expect(sigsyaml.Unmarshal(output, res)).To(Succeed())
+		Expect(res.Error).To(BeEmpty())
+		Expect(res.Mediations).To(HaveLen(2))
+		Expect(res.Mediations[0].Project).To(Equal("services/api"))
+		Expect(res.Mediations[1].Project).To(Equal("services/web"))
+		Expect(res.SendEvents).To(HaveLen(2))
+		api := res.SendEvents[0].Payload.(map[string]interface{})
+		Expect(api["project"]).To(Equal("api"))
+		Expect(api["webhooks-tekton-project-dir"]).To(Equal("services/api"))
+		/* README.md is excluded, and docs is not included */
+		Expect(api["changed"]).To(BeEquivalentTo(4))
+		web := res.SendEvents[1].Payload.(map[string]interface{})
+		Expect(web["project"]).To(Equal("web"))
+		Expect(web["webhooks-tekton-project-dir"]).To(Equal("services/web"))
+
+		/* no changed file is selected by the paths */
+		opts.mediatorFile = replaced("testdata/monorepo-mediator.yaml", `"services/**"`, `"frontend/**"`)
+		defer os.Remove(opts.mediatorFile)
+		output, err = run(opts)
+		Expect(err).ToNot(HaveOccurred())
+		res = &result{}
+		Expect(sigsyaml.Unmarshal(output, res)).To(Succeed())
+		Expect(res.Mediations).To(BeEmpty())
+	})
+
+	It("should not match mediations with paths for events that change no files", func() {
+		opts := newOptions()
+		mediatorFile := replaced("testdata/monorepo-mediator.yaml", "allMatches", "firstMatch")
+		defer os.Remove(mediatorFile)
+		opts.mediatorFile = replaced(mediatorFile, "        - = : 'sendEvent(dest, body, header)'\n",
+			"        - = : 'sendEvent(dest, body, header)'\n    - name: appsody\n      selector:\n        urlPattern: webhook\n      sendTo: [ \"dest\" ]\n      body:\n        - = : 'sendEvent(dest, body, header)'\n")
+		defer os.Remove(opts.mediatorFile)
+		notified := func() {
+			output, err := run(opts)
+			Expect(err).ToNot(HaveOccurred())
+			res := &result{}
+			Expect(sigsyaml.Unmarshal(output, res)).To(Succeed())
+			Expect(res.Error).To(BeEmpty())
+			Expect(res.Mediations).To(HaveLen(1))
+			Expect(res.Mediations[0].Name).To(Equal("appsody"))
+			Expect(res.SendEvents).To(HaveLen(1))
+		}
+
+		opts.requestFile = replaced("testdata/monorepo-request.yaml", "[ push ]", "[ ping ]")
+		defer os.Remove(opts.requestFile)
+		notified()
+
+		/* the push that deletes a branch */
+		opts.requestFile = replaced("testdata/monorepo-request.yaml", "after: 0d1a26e67d8f5eaf1f6ba5c57fc3c7d91ac0fd1c", "after: \"0000000000000000000000000000000000000000\"")
+		defer os.Remove(opts.requestFile)
+		notified()
+	})
+
+	It("should compare the commits of a pull request to find the changed files", func() {
+		opts := newOptions()
+		opts.mediatorFile = "testdata/monorepo-mediator.yaml"
+		requestFile := replaced("testdata/monorepo-request.yaml", "[ push ]", "[ pull_request ]")
+		defer os.Remove(requestFile)
+		opts.requestFile = replaced(requestFile, "  ref: refs/heads/master\n",
+			"  action: opened\n  pull_request:\n    number: 4\n    head: { ref: fix/app, sha: ec26c3e5 }\n    base: { ref: master, sha: f95f852b }\n")
+		defer os.Remove(opts.requestFile)
+		opts.repoFiles = map[string]string{
+			"services/api/.appsody-config.yaml": "testdata/api-config.yaml",
+			"services/web/.appsody-config.yaml": "testdata/web-config.yaml",
+		}
+		opts.changedFiles = []string{"README.md", "services/web/src/app.js"}
+		output, err := run(opts)
+		Expect(err).ToNot(HaveOccurred())
+		res := &result{}
+		Expect(sigsyaml.Unmarshal(output, res)).To(Succeed())
+		Expect(res.Error).To(BeEmpty())
+		Expect(res.Mediations).To(HaveLen(1))
+		Expect(res.Mediations[0].Project).To(Equal("services/web"))
+		Expect(res.SendEvents).To(HaveLen(1))
+		payload := res.SendEvents[0].Payload.(map[string]interface{})
+		Expect(payload["changed"]).To(BeEquivalentTo(1))
+		Expect(payload["webhooks-tekton-git-branch"]).To(Equal("fix/app"))
+	})
+
+	It("should warn that GitHub lists only the first changed files of a comparison", func() {
+		opts := newOptions()
+		opts.mediatorFile = "testdata/monorepo-mediator.yaml"
+		requestFile := replaced("testdata/monorepo-request.yaml", "[ push ]", "[ pull_request ]")
+		defer os.Remove(requestFile)
+		opts.requestFile = replaced(requestFile, "  ref: refs/heads/master\n",
+			"  action: opened\n  pull_request:\n    number: 4\n    head: { ref: fix/app, sha: ec26c3e5 }\n    base: { ref: master, sha: f95f852b }\n")
+		defer os.Remove(opts.requestFile)
+		opts.repoFiles = map[string]string{"services/web/.appsody-config.yaml": "testdata/web-config.yaml"}
+		warnings := func() []string {
+			output, err := run(opts)
+			Expect(err).ToNot(HaveOccurred())
+			res := &result{}
+			Expect(sigsyaml.Unmarshal(output, res)).To(Succeed())
+			Expect(res.SendEvents).To(HaveLen(1))
+			ret := make([]string, 0)
+			for _, record := range res.Status {
+				if record.Result == status.RESULT_WARNING {
+					ret = append(ret, record.Message)
+				}
+			}
+			return ret
+		}
+
+		opts.changedFiles = make([]string, 0)
+		for index := 0; index < 299; index++ {
+			opts.changedFiles = append(opts.changedFiles, fmt.Sprintf("services/web/src/file%v.js", index))
+		}
+		Expect(warnings()).To(BeEmpty())
+
+		opts.changedFiles = append(opts.changedFiles, "services/web/src/app.js")
+		Expect(warnings()).To(ConsistOf(HavePrefix("GitHub lists only the first 300 files changed between f95f852b and ec26c3e5")))
+	})
+
+	It("should reject an EventMediator with an invalid url pattern", func() {
+		opts := newOptions()
+		opts.mediatorFile = replaced("testdata/mediator.yaml", "urlPattern: webhook", "urlPattern: ^teams/(")
+		defer os.Remove(opts.mediatorFile)
+		_, err := run(opts)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("invalid urlPattern ^teams/("))
+	})
+
+	It("should reject a file without an EventMediator", func() {
+		opts := newOptions()
+		opts.mediatorFile = "testdata/connections.yaml"
+		_, err := run(opts)
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,469 @@
+/*
+Copyright 2020 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/google/go-github/github"
+	"github.com/kabanero-io/events-operator/pkg/apis"
+	eventsv1alpha1 "github.com/kabanero-io/events-operator/pkg/apis/events/v1alpha1"
+	"github.com/kabanero-io/events-operator/pkg/connections"
+	"github.com/kabanero-io/events-operator/pkg/controller/eventmediator"
+	"github.com/kabanero-io/events-operator/pkg/debug"
+	"github.com/kabanero-io/events-operator/pkg/event"
+	"github.com/kabanero-io/events-operator/pkg/eventenv"
+	"github.com/kabanero-io/events-operator/pkg/managers"
+	"github.com/kabanero-io/events-operator/pkg/status"
+	"github.com/kabanero-io/events-operator/pkg/templates"
+	"github.com/kabanero-io/events-operator/pkg/utils"
+	kab_operator "github.com/kabanero-io/kabanero-operator/pkg/apis"
+	kabanerov1alpha2 "github.com/kabanero-io/kabanero-operator/pkg/apis/kabanero/v1alpha2"
+	triggers "github.com/tektoncd/triggers/pkg/apis/triggers/v1alpha1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8syaml "k8s.io/apimachinery/pkg/util/yaml"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	sigsyaml "sigs.k8s.io/yaml"
+)
+
+/* Options of a test run */
+type options struct {
+	mediatorFile        string
+	connectionsFile     string
+	requestFile         string
+	repoFiles           map[string]string // name of repository file, or name@ref for the file at a commit, to local path
+	resourceFiles       []string
+	namespace           string
+	kabaneroIntegration bool
+	trace               bool // include the trace of the evaluation in the output
+	permissions         map[string]string // repository permission of GitHub users. Users not listed have no permission
+	pullRequestFile     string            // pull request returned by the GitHub API for ChatOps commands
+	changedFiles        []string          // files returned by the compare API of GitHub
+}
+
+/* A recorded request */
+type request struct {
+	URL        string                 `json:"url"`
+	RemoteAddr string                 `json:"remoteAddr,omitempty"`
+	Header     map[string][]string    `json:"header,omitempty"`
+	Body       map[string]interface{} `json:"body,omitempty"`
+}
+
+/* An event that the mediation would have sent */
+type sentEvent struct {
+	Destination string              `json:"destination"`
+	URL         string              `json:"url"`
+	Header      map[string][]string `json:"header,omitempty"`
+	Payload     interface{}         `json:"payload,omitempty"`
+}
+
+/* A status summary, without the time so that output is repeatable */
+type statusRecord struct {
+	Operation string                                `json:"operation"`
+	Input     []eventsv1alpha1.EventStatusParameter `json:"input,omitempty"`
+	Result    string                                `json:"result"`
+	Message   string                                `json:"message,omitempty"`
+}
+
+/* Output of one mediation that processed the request */
+type mediationOutput struct {
+	Name      string                 `json:"name"`
+	Project   string                 `json:"project,omitempty"`
+	Error     string                 `json:"error,omitempty"`
+	Variables map[string]interface{} `json:"variables,omitempty"`
+	Trace     []debug.TraceStep      `json:"trace,omitempty"`
+}
+
+/* Output of a test run */
+type result struct {
+	Mediations []mediationOutput `json:"mediations"`
+	Error      string            `json:"error,omitempty"`
+	SendEvents []sentEvent       `json:"sendEvents,omitempty"`
+	Status     []statusRecord    `json:"status,omitempty"`
+}
+
+/* Decode every YAML or JSON document in a file into objects created by newObj */
+func decodeFile(fileName string, newObj func() interface{}, add func(interface{})) error {
+	buf, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return err
+	}
+	decoder := k8syaml.NewYAMLOrJSONDecoder(bytes.NewBuffer(buf), 4096)
+	for {
+		obj := newObj()
+		err = decoder.Decode(obj)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("unable to parse %v: %v", fileName, err)
+		}
+		add(obj)
+	}
+}
+
+func loadMediator(fileName string) (*eventsv1alpha1.EventMediator, error) {
+	mediators := make([]*eventsv1alpha1.EventMediator, 0)
+	err := decodeFile(fileName, func() interface{} { return &eventsv1alpha1.EventMediator{} }, func(obj interface{}) {
+		mediators = append(mediators, obj.(*eventsv1alpha1.EventMediator))
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(mediators) != 1 {
+		return nil, fmt.Errorf("%v contains %v documents, but expecting exactly one EventMediator", fileName, len(mediators))
+	}
+	if mediators[0].Kind != "EventMediator" {
+		return nil, fmt.Errorf("%v contains kind %v, but expecting EventMediator", fileName, mediators[0].Kind)
+	}
+	return mediators[0], nil
+}
+
+func loadConnections(fileName string) ([]*eventsv1alpha1.EventConnections, error) {
+	ret := make([]*eventsv1alpha1.EventConnections, 0)
+	err := decodeFile(fileName, func() interface{} { return &eventsv1alpha1.EventConnections{} }, func(obj interface{}) {
+		ret = append(ret, obj.(*eventsv1alpha1.EventConnections))
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, conn := range ret {
+		if conn.Kind != "EventConnections" {
+			return nil, fmt.Errorf("%v contains kind %v, but expecting EventConnections", fileName, conn.Kind)
+		}
+	}
+	return ret, nil
+}
+
+/* Load resources from files. Kinds known to the scheme are converted to their types, so that they may be read with typed clients */
+func loadResources(fileNames []string, namespace string, scheme *runtime.Scheme) ([]runtime.Object, error) {
+	ret := make([]runtime.Object, 0)
+	for _, fileName := range fileNames {
+		var convertErr error
+		err := decodeFile(fileName, func() interface{} { return &map[string]interface{}{} }, func(obj interface{}) {
+			u := &unstructured.Unstructured{Object: *obj.(*map[string]interface{})}
+			if len(u.Object) == 0 {
+				return
+			}
+			if u.GetNamespace() == "" {
+				u.SetNamespace(namespace)
+			}
+			typed, err := scheme.New(u.GroupVersionKind())
+			if err != nil {
+				ret = append(ret, u)
+				return
+			}
+			if err = runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, typed); err != nil && convertErr == nil {
+				convertErr = fmt.Errorf("unable to convert %v %v in %v: %v", u.GetKind(), u.GetName(), fileName, err)
+			}
+			ret = append(ret, typed)
+		})
+		if err != nil {
+			return nil, err
+		}
+		if convertErr != nil {
+			return nil, convertErr
+		}
+	}
+	return ret, nil
+}
+
+/* Index the stacks and event listeners of the resources, as the watches of the worker do */
+func newStackIndex(resources []runtime.Object) *utils.StackListenerIndex {
+	index := utils.NewStackListenerIndex()
+	for _, obj := range resources {
+		switch resource := obj.(type) {
+		case *kabanerov1alpha2.Stack:
+			index.SetStack(resource)
+		case *triggers.EventListener:
+			index.SetEventListener(resource)
+		}
+	}
+	index.SetSynced()
+	return index
+}
+
+func loadRequest(fileName string) (*event.Event, error) {
+	buf, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	req := &request{}
+	err = sigsyaml.Unmarshal(buf, req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse %v: %v", fileName, err)
+	}
+	reqURL, err := url.Parse(req.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url %v in %v: %v", req.URL, fileName, err)
+	}
+	if req.Header == nil {
+		req.Header = make(map[string][]string)
+	}
+	if req.Body == nil {
+		req.Body = make(map[string]interface{})
+	}
+	return &event.Event{
+		URL:        reqURL,
+		RemoteAddr: req.RemoteAddr,
+		Header:     req.Header,
+		Body:       req.Body,
+	}, nil
+}
+
+/* Return a function that reads repository files from local files instead of downloading them. A file at a commit is read from name@ref */
+func stubDownloadYAML(repoFiles map[string]string) eventenv.DownloadYAMLFunc {
+	return func(ctx context.Context, kubeClient client.Client, namespace string, repo *eventsv1alpha1.EventGithubRepository, header map[string][]string, body map[string]interface{}, fileName string, ref string) (map[string]interface{}, bool, error) {
+		path, ok := repoFiles[fileName+"@"+ref]
+		if !ok {
+			path, ok = repoFiles[fileName]
+		}
+		if !ok {
+			return nil, false, nil
+		}
+		buf, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, false, err
+		}
+		/* parse as the downloaded files are parsed */
+		ret, err := utils.YAMLToMap(buf)
+		if err != nil {
+			return nil, false, fmt.Errorf("unable to parse repository file %v: %v", path, err)
+		}
+		return ret, true, nil
+	}
+}
+
+/* Return a function that reads the permission of users from permissions instead of GitHub */
+func stubGetPermission(permissions map[string]string) eventenv.GetPermissionFunc {
+	return func(ctx context.Context, kubeClient client.Client, namespace string, repo *eventsv1alpha1.EventGithubRepository, header map[string][]string, body map[string]interface{}, login string) (string, error) {
+		permission, ok := permissions[login]
+		if !ok {
+			return utils.GITHUB_PERMISSION_NONE, nil
+		}
+		return permission, nil
+	}
+}
+
+/* Return a function that reads a pull request from a file, in the format of the GitHub API, instead of GitHub */
+func stubGetPullRequest(pullRequestFile string) eventenv.GetPullRequestFunc {
+	return func(ctx context.Context, kubeClient client.Client, namespace string, repo *eventsv1alpha1.EventGithubRepository, header map[string][]string, body map[string]interface{}, number int) (*utils.PullRequestRefs, error) {
+		if pullRequestFile == "" {
+			return nil, fmt.Errorf("unable to get pull request %v: no pull request file", number)
+		}
+		buf, err := ioutil.ReadFile(pullRequestFile)
+		if err != nil {
+			return nil, err
+		}
+		pr := &github.PullRequest{}
+		err = sigsyaml.Unmarshal(buf, pr)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse pull request file %v: %v", pullRequestFile, err)
+		}
+		if pr.GetNumber() != number {
+			return nil, fmt.Errorf("unable to get pull request %v: pull request file %v contains pull request %v", number, pullRequestFile, pr.GetNumber())
+		}
+		return &utils.PullRequestRefs{
+			Number:  number,
+			HeadRef: pr.GetHead().GetRef(),
+			HeadSHA: pr.GetHead().GetSHA(),
+			BaseRef: pr.GetBase().GetRef(),
+			BaseSHA: pr.GetBase().GetSHA(),
+		}, nil
+	}
+}
+
+/* Return a function that returns changedFiles instead of comparing commits on GitHub */
+func stubCompareCommits(changedFiles []string) eventenv.CompareCommitsFunc {
+	return func(ctx context.Context, kubeClient client.Client, namespace string, repo *eventsv1alpha1.EventGithubRepository, header map[string][]string, body map[string]interface{}, base, head string) ([]string, error) {
+		return append([]string{}, changedFiles...), nil
+	}
+}
+
+/* Return a function that lists the repository files of repoFiles at a commit instead of listing the tree of the commit on GitHub */
+func stubListFiles(repoFiles map[string]string) eventenv.ListFilesFunc {
+	return func(ctx context.Context, kubeClient client.Client, namespace string, repo *eventsv1alpha1.EventGithubRepository, header map[string][]string, body map[string]interface{}, ref string) ([]string, bool, error) {
+		files := make([]string, 0, len(repoFiles))
+		for name := range repoFiles {
+			if index := strings.LastIndex(name, "@"); index < 0 {
+				files = append(files, name)
+			} else if name[index+1:] == ref {
+				files = append(files, name[:index])
+			}
+		}
+		sort.Strings(files)
+		return files, true, nil
+	}
+}
+
+func newScheme() (*runtime.Scheme, error) {
+	scheme := runtime.NewScheme()
+	for _, addToScheme := range []func(*runtime.Scheme) error{clientgoscheme.AddToScheme, apis.AddToScheme, kab_operator.AddToScheme, triggers.AddToScheme} {
+		if err := addToScheme(scheme); err != nil {
+			return nil, err
+		}
+	}
+	return scheme, nil
+}
+
+/* Run the request through the mediator, and return the result as YAML */
+func run(opts *options) ([]byte, error) {
+	mediator, err := loadMediator(opts.mediatorFile)
+	if err != nil {
+		return nil, err
+	}
+	/* capture events instead of sending them */
+	mediator.Spec.DryRun = true
+	if opts.trace && mediator.Spec.Mediations != nil {
+		for i := range *mediator.Spec.Mediations {
+			(*mediator.Spec.Mediations)[i].Trace = true
+		}
+	}
+
+	namespace := opts.namespace
+	if namespace == "" {
+		namespace = mediator.Namespace
+	}
+	if namespace == "" {
+		namespace = "default"
+	}
+	if mediator.Namespace == "" {
+		mediator.Namespace = namespace
+	}
+
+	connectionsMgr := connections.NewConnectionsManager()
+	if opts.connectionsFile != "" {
+		conns, err := loadConnections(opts.connectionsFile)
+		if err != nil {
+			return nil, err
+		}
+		for _, conn := range conns {
+			connectionsMgr.AddConnections(conn)
+		}
+	}
+
+	scheme, err := newScheme()
+	if err != nil {
+		return nil, err
+	}
+	resources, err := loadResources(opts.resourceFiles, namespace, scheme)
+	if err != nil {
+		return nil, err
+	}
+	kubeClient := fake.NewFakeClientWithScheme(scheme, resources...)
+
+	evt, err := loadRequest(opts.requestFile)
+	if err != nil {
+		return nil, err
+	}
+
+	env := &eventenv.EventEnv{
+		Context:             context.Background(),
+		Client:              kubeClient,
+		Cache:               kubeClient,
+		APIReader:           kubeClient,
+		EventMgr:            managers.NewEventManager(),
+		ConnectionsMgr:      connectionsMgr,
+		StatusMgr:           status.NewStatusManager(),
+		TemplateMgr:         templates.NewTemplateManager(),
+		DebugMgr:            debug.NewDebugManager(),
+		StackIndex:          newStackIndex(resources),
+		DownloadYAML:        stubDownloadYAML(opts.repoFiles),
+		GetPullRequest:      stubGetPullRequest(opts.pullRequestFile),
+		GetPermission:       stubGetPermission(opts.permissions),
+		CompareCommits:      stubCompareCommits(opts.changedFiles),
+		ListFiles:           stubListFiles(opts.repoFiles),
+		MediatorName:        mediator.Name,
+		IsOperator:          false,
+		Namespace:           namespace,
+		KabaneroIntegration: opts.kabaneroIntegration,
+	}
+	eventenv.InitEventEnv(env)
+	/* validated as when the mediator is loaded by the controller */
+	if err := env.EventMgr.AddEventMediator(mediator); err != nil {
+		return nil, err
+	}
+
+	results, err := eventmediator.ProcessEvent(env.Context, env, mediator, evt)
+	res := &result{
+		Mediations: make([]mediationOutput, 0),
+		SendEvents: make([]sentEvent, 0),
+		Status:     make([]statusRecord, 0),
+	}
+	if err != nil {
+		res.Error = err.Error()
+	}
+	for _, mediationResult := range results {
+		output := mediationOutput{Name: mediationResult.Mediation, Project: mediationResult.Project}
+		if mediationResult.Error != nil {
+			output.Error = mediationResult.Error.Error()
+		}
+		if processor := mediationResult.Processor; processor != nil {
+			variables, err := processor.GetVariables()
+			if err != nil {
+				return nil, err
+			}
+			/* the body and header are in the request already */
+			delete(variables, "body")
+			delete(variables, "header")
+			output.Variables = variables
+			if trace := processor.GetTrace(); trace != nil {
+				output.Trace = trace.Steps
+			}
+		}
+		res.Mediations = append(res.Mediations, output)
+	}
+
+	/* dry runs are returned newest first */
+	dryRuns := env.DebugMgr.GetDryRuns()
+	for i := len(dryRuns) - 1; i >= 0; i-- {
+		dryRun := dryRuns[i]
+		sent := sentEvent{
+			Destination: dryRun.Destination,
+			URL:         dryRun.URL,
+			Header:      dryRun.Header,
+		}
+		if len(dryRun.Payload) > 0 {
+			if err := json.Unmarshal(dryRun.Payload, &sent.Payload); err != nil {
+				sent.Payload = string(dryRun.Payload)
+			}
+		}
+		res.SendEvents = append(res.SendEvents, sent)
+	}
+
+	for _, summary := range env.StatusMgr.GetStatusSummary() {
+		res.Status = append(res.Status, statusRecord{
+			Operation: summary.Operation,
+			Input:     summary.Input,
+			Result:    summary.Result,
+			Message:   summary.Message,
+		})
+	}
+
+	return sigsyaml.Marshal(res)
+}
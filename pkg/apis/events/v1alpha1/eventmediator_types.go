@@ -1,6 +1,7 @@
 package v1alpha1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -24,23 +25,56 @@ type EventMediatorSpec struct {
     CreateListener  bool `json:"createListener,omitempty"`
     InsecureListener bool `json:"insecureListener,omitempty"`
     CreateRoute    bool `json:"createRoute,omitempty"`
+    DryRun bool `json:"dryRun,omitempty"` // evaluate all mediations without sending events or creating resources
     Repositories *[]EventRepository `json:"repositories,omitempty"`
 
     // global variables 
     Variables *[]EventMediationVariable `json:"variables,omitempty"`
 
+    // kinds of resources that may be read with the getResource and listResources functions
+    AllowedResources *[]EventAllowedResource `json:"allowedResources,omitempty"`
+
+    // garbage collection of resources created by applyResources: none (default), or mediator to delete them with the mediator
+    GarbageCollectionPolicy string `json:"garbageCollectionPolicy,omitempty"`
+
+    // templates that may be rendered with the template function
+    Templates *[]EventMediationTemplate `json:"templates,omitempty"`
+
+    // default limits on the evaluation of each event by the mediations
+    Limits *EventMediationLimits `json:"limits,omitempty"`
+
+    // which mediations process an event: firstMatch (default) for the first mediation that matches, or allMatches
+    MatchPolicy string `json:"matchPolicy,omitempty"`
+
+    // version constraints of stacks, overriding the versions requested by the repositories
+    StackVersions *[]EventStackVersion `json:"stackVersions,omitempty"`
+
+    // rules to select the event listener of a stack version among those of its pipelines, tried before the rules of the stack
+    ListenerSelectors *[]EventListenerSelector `json:"listenerSelectors,omitempty"`
+
     // mediations
     Mediations *[]EventMediationImpl `json:"mediations,omitempty"`
     // Functions *[]EventFunctionImpl `json:"functions,omitempty"`
 }
 
+/* Configuration of the repositories matching urlPattern and org. An entry without them matches any repository */
 type EventRepository struct {
+    UrlPattern string `json:"urlPattern,omitempty"` // glob matching the html_url of the repository, such as https://github.com/kabanero-io/*
+    Org string `json:"org,omitempty"` // owner of the repository
     Github *EventGithubRepository `json:"github,omitempty"`
 }
 
 type EventGithubRepository struct {
     Secret string `json:"secret,omitempty"`
     WebhookSecret string `json:"webhookSecret,omitempty"`
+    App *EventGithubApp `json:"app,omitempty"` // GitHub App to access repositories, instead of the user name and token of secret
+}
+
+/* GitHub App to access repositories. Its installation tokens are cached until they expire */
+type EventGithubApp struct {
+    AppID int64 `json:"appID"`
+    InstallationID int64 `json:"installationID,omitempty"` // discovered from the repository of each event if not specified
+    PrivateKey corev1.SecretKeySelector `json:"privateKey"` // key of a Secret containing the PEM encoded private key of the app
 }
 
 
@@ -55,6 +89,9 @@ type EventGithubRepository struct {
   3) if and body
   4) switch
   5) if and switch
+  6) try, with optional onError
+  7) fail
+  8) if and fail
   TBD: switch and default
 */
 type EventStatement struct {
@@ -63,6 +100,9 @@ type EventStatement struct {
     Switch  *[]EventStatement `json:"switch,omitempty"`
     Body *[]EventStatement `json:"body,omitempty"`
     Default *[]EventStatement `json:"default,omitempty"`
+    Try *[]EventStatement `json:"try,omitempty"`
+    OnError *[]EventStatement `json:"onError,omitempty"` // evaluated with variable error set if try fails
+    Fail *string `json:"fail,omitempty"` // expression for the message to end the mediation with
 }
 
 type EventFunctionImpl struct {
@@ -74,6 +114,7 @@ type EventFunctionImpl struct {
 
 type EventMediationImpl  struct {
     Name string `json:"name"`
+    Priority int `json:"priority,omitempty"` // mediations with higher priority are matched first. Default 0
     // Input string `json:"input,omitempty"`
     SendTo []string `json:"sendTo,omitempty"`
     Selector *EventMediationSelector `json:"selector,omitempty"`
@@ -81,23 +122,113 @@ type EventMediationImpl  struct {
     // local variables
     Variables *[]EventMediationVariable `json:"variables,omitempty"`
 
+    DryRun bool `json:"dryRun,omitempty"` // evaluate without sending events or creating resources
+    Trace bool `json:"trace,omitempty"` // record a step-by-step trace of the evaluation of each event
+
+    Limits *EventMediationLimits `json:"limits,omitempty"` // overrides the limits of the mediator
+
+    ReportStatus *EventMediationReportStatus `json:"reportStatus,omitempty"` // report the delivery of events to GitHub on the head commit of the event
+
     Body []EventStatement `json:"body,omitempty"`
 }
 
+/* Commit status or check run posted on the head commit of a GitHub event when the mediation sends the event:
+   pending when the event is sent, and error when it can not be sent */
+type EventMediationReportStatus struct {
+    Type string `json:"type,omitempty"` // status (default) for a commit status, or checkRun for a check run. Only GitHub Apps may create check runs
+    Context string `json:"context,omitempty"` // context of the commit status, or name of the check run. Default <mediator>/<mediation>
+    TargetURL string `json:"targetURL,omitempty"` // URL linked from the status, such as a dashboard of the pipelines
+}
+
+/* Limits on the evaluation of one event by a mediation. A limit that is not set uses the default. */
+type EventMediationLimits struct {
+    MaxDepth int `json:"maxDepth,omitempty"` // maximum nesting depth of statements
+    MaxStatements int `json:"maxStatements,omitempty"` // maximum number of statements executed
+    MaxCost int64 `json:"maxCost,omitempty"` // maximum number of expression nodes evaluated
+    Timeout string `json:"timeout,omitempty"` // maximum duration of the evaluation, such as 30s
+}
+
 type EventMediationVariable struct {
     Name string `json:"name"`
     Value *string `json:"value,omitempty"` // value treated as tring
     ValueExpression *string `json:"valueExpression,omitempty"`     // value intrepreted as CEL expression
+    ValueFrom *EventVariableSource `json:"valueFrom,omitempty"` // value read from a Secret, ConfigMap, or environment variable
+    Type string `json:"type,omitempty"` // declared type of the value: int, bool, list, map, or string
+    Default *string `json:"default,omitempty"` // CEL expression used when the value can not be evaluated or found
+}
+
+/* Source of the value of a variable. Exactly one of the sources is to be specified.
+   Secrets and ConfigMaps are read from the namespace of the mediator.
+*/
+type EventVariableSource struct {
+    SecretKeyRef *corev1.SecretKeySelector `json:"secretKeyRef,omitempty"`
+    ConfigMapKeyRef *corev1.ConfigMapKeySelector `json:"configMapKeyRef,omitempty"`
+    Env string `json:"env,omitempty"` // name of an environment variable of the mediator worker
+}
+
+/* A kind of resource mediations are allowed to read. Kind "*" allows all kinds of the apiVersion except Secrets.
+   Secrets must be allowed explicitly.
+*/
+type EventAllowedResource struct {
+    APIVersion string `json:"apiVersion"`
+    Kind string `json:"kind"`
+}
+
+/* A Go template. Exactly one of Template or ConfigMap should be set */
+type EventMediationTemplate struct {
+    Name string `json:"name"`
+    Template *string `json:"template,omitempty"` // inline template
+    ConfigMap *EventTemplateConfigMap `json:"configMap,omitempty"` // template stored in a ConfigMap in the same namespace
+    Format string `json:"format,omitempty"` // text (default) to render a string, yaml or json to render a map
+}
+
+type EventTemplateConfigMap struct {
+    Name string `json:"name"`
+    Key string `json:"key"`
 }
 
 type EventMediationSelector struct {
-    UrlPattern string `json:"urlPattern,omitempty"`
+    UrlPattern string `json:"urlPattern,omitempty"` // plain path, glob with {name} parameters, or regular expression starting with ^
+    Condition string `json:"condition,omitempty"` // CEL expression on header and body, evaluated before the repository file is downloaded
     RepositoryType *EventMediationRepositoryType `json:"repositoryType,omitempty"`
+    Command *EventMediationCommand `json:"command,omitempty"` // ChatOps command in a comment on a pull request
+    Paths *EventMediationPaths `json:"paths,omitempty"` // globs of the files changed by a push or pull request
+}
+
+/* Version constraint of a stack, used to find the event listener of repositories of the stack */
+type EventStackVersion struct {
+    Stack string `json:"stack"` // image of an Appsody stack without its tag, such as docker.io/kabanero/nodejs, or name of the stack of a devfile
+    Version string `json:"version"` // constraint on the versions of the stack, such as ^0.3, or >=0.2 <0.4
+}
+
+/* Rule to select the event listener of a stack version among the EventListener assets of its pipelines.
+   The rule applies to the stacks and events it lists, and selects the first listener that matches all of its fields */
+type EventListenerSelector struct {
+    Stack string `json:"stack,omitempty"` // image of an Appsody stack without its tag, or name of the stack. All stacks if empty
+    EventTypes []string `json:"eventTypes,omitempty"` // events the rule applies to: push, pull_request, tag, or another GitHub event. All events if empty
+    Pipelines []string `json:"pipelines,omitempty"` // globs of the names of the pipelines of the listener. Any pipeline if empty
+    Listeners []string `json:"listeners,omitempty"` // globs of the names of the listener. Any listener if empty
+    ExcludeListeners []string `json:"excludeListeners,omitempty"` // globs of the names of listeners not to select
+    MatchLabels map[string]string `json:"matchLabels,omitempty"` // labels of the EventListener resource
+    MatchAnnotations map[string]string `json:"matchAnnotations,omitempty"` // annotations of the EventListener resource
+}
+
+/* Files changed by a push or pull request. The mediation matches if any changed file is included and not excluded */
+type EventMediationPaths struct {
+    Include []string `json:"include,omitempty"` // globs of the files to include, such as services/api/**. All files if empty
+    Exclude []string `json:"exclude,omitempty"` // globs of the files to exclude, such as **/*.md
+}
+
+/* ChatOps command in a comment on a pull request, such as /retest or /build prod */
+type EventMediationCommand struct {
+    Names []string `json:"names,omitempty"` // names of the commands, without the leading /. Any command if empty
+    Permission string `json:"permission,omitempty"` // minimum permission of the author of the comment on the repository: read, triage, write, maintain, or admin. Default write
 }
 
 type EventMediationRepositoryType struct {
     File string `json:"file"`
     NewVariable string `json:"newVariable"`
+    Discover bool `json:"discover,omitempty"` // find the file in the directories of the changed files, and process the event once per project directory
 }
 
 // EventMediatorStatus defines the observed state of EventMediator
@@ -106,6 +237,16 @@ type EventMediatorStatus struct {
 	// Important: Run "operator-sdk generate k8s" to regenerate code after modifying this file
 	// Add custom validation using kubebuilder tags: https://book-v1.book.kubebuilder.io/beyond_basics/generating_crd.html
     Summary []EventStatusSummary `json:"summary"`
+    Conditions []EventMediatorCondition `json:"conditions,omitempty"`
+}
+
+/* Condition of a mediator, such as whether the event listeners of all active stack versions are available */
+type EventMediatorCondition struct {
+    Type string `json:"type"`
+    Status string `json:"status"` // True, False, or Unknown
+    Reason string `json:"reason,omitempty"`
+    Message string `json:"message,omitempty"`
+    LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
 }
 
 type EventStatusParameter struct {
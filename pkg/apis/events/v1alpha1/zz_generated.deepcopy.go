@@ -5,9 +5,26 @@
 package v1alpha1
 
 import (
+	v1 "k8s.io/api/core/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EventAllowedResource) DeepCopyInto(out *EventAllowedResource) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EventAllowedResource.
+func (in *EventAllowedResource) DeepCopy() *EventAllowedResource {
+	if in == nil {
+		return nil
+	}
+	out := new(EventAllowedResource)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *EventConnection) DeepCopyInto(out *EventConnection) {
 	*out = *in
@@ -182,9 +199,31 @@ func (in *EventFunctionImpl) DeepCopy() *EventFunctionImpl {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EventGithubApp) DeepCopyInto(out *EventGithubApp) {
+	*out = *in
+	in.PrivateKey.DeepCopyInto(&out.PrivateKey)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EventGithubApp.
+func (in *EventGithubApp) DeepCopy() *EventGithubApp {
+	if in == nil {
+		return nil
+	}
+	out := new(EventGithubApp)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *EventGithubRepository) DeepCopyInto(out *EventGithubRepository) {
 	*out = *in
+	if in.App != nil {
+		in, out := &in.App, &out.App
+		*out = new(EventGithubApp)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -198,6 +237,77 @@ func (in *EventGithubRepository) DeepCopy() *EventGithubRepository {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EventListenerSelector) DeepCopyInto(out *EventListenerSelector) {
+	*out = *in
+	if in.EventTypes != nil {
+		in, out := &in.EventTypes, &out.EventTypes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Pipelines != nil {
+		in, out := &in.Pipelines, &out.Pipelines
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Listeners != nil {
+		in, out := &in.Listeners, &out.Listeners
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ExcludeListeners != nil {
+		in, out := &in.ExcludeListeners, &out.ExcludeListeners
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.MatchLabels != nil {
+		in, out := &in.MatchLabels, &out.MatchLabels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.MatchAnnotations != nil {
+		in, out := &in.MatchAnnotations, &out.MatchAnnotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EventListenerSelector.
+func (in *EventListenerSelector) DeepCopy() *EventListenerSelector {
+	if in == nil {
+		return nil
+	}
+	out := new(EventListenerSelector)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EventMediationCommand) DeepCopyInto(out *EventMediationCommand) {
+	*out = *in
+	if in.Names != nil {
+		in, out := &in.Names, &out.Names
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EventMediationCommand.
+func (in *EventMediationCommand) DeepCopy() *EventMediationCommand {
+	if in == nil {
+		return nil
+	}
+	out := new(EventMediationCommand)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *EventMediationImpl) DeepCopyInto(out *EventMediationImpl) {
 	*out = *in
@@ -222,6 +332,16 @@ func (in *EventMediationImpl) DeepCopyInto(out *EventMediationImpl) {
 			}
 		}
 	}
+	if in.Limits != nil {
+		in, out := &in.Limits, &out.Limits
+		*out = new(EventMediationLimits)
+		**out = **in
+	}
+	if in.ReportStatus != nil {
+		in, out := &in.ReportStatus, &out.ReportStatus
+		*out = new(EventMediationReportStatus)
+		**out = **in
+	}
 	if in.Body != nil {
 		in, out := &in.Body, &out.Body
 		*out = make([]EventStatement, len(*in))
@@ -242,6 +362,64 @@ func (in *EventMediationImpl) DeepCopy() *EventMediationImpl {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EventMediationLimits) DeepCopyInto(out *EventMediationLimits) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EventMediationLimits.
+func (in *EventMediationLimits) DeepCopy() *EventMediationLimits {
+	if in == nil {
+		return nil
+	}
+	out := new(EventMediationLimits)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EventMediationPaths) DeepCopyInto(out *EventMediationPaths) {
+	*out = *in
+	if in.Include != nil {
+		in, out := &in.Include, &out.Include
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Exclude != nil {
+		in, out := &in.Exclude, &out.Exclude
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EventMediationPaths.
+func (in *EventMediationPaths) DeepCopy() *EventMediationPaths {
+	if in == nil {
+		return nil
+	}
+	out := new(EventMediationPaths)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EventMediationReportStatus) DeepCopyInto(out *EventMediationReportStatus) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EventMediationReportStatus.
+func (in *EventMediationReportStatus) DeepCopy() *EventMediationReportStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(EventMediationReportStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *EventMediationRepositoryType) DeepCopyInto(out *EventMediationRepositoryType) {
 	*out = *in
@@ -266,6 +444,16 @@ func (in *EventMediationSelector) DeepCopyInto(out *EventMediationSelector) {
 		*out = new(EventMediationRepositoryType)
 		**out = **in
 	}
+	if in.Command != nil {
+		in, out := &in.Command, &out.Command
+		*out = new(EventMediationCommand)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Paths != nil {
+		in, out := &in.Paths, &out.Paths
+		*out = new(EventMediationPaths)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -279,6 +467,32 @@ func (in *EventMediationSelector) DeepCopy() *EventMediationSelector {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EventMediationTemplate) DeepCopyInto(out *EventMediationTemplate) {
+	*out = *in
+	if in.Template != nil {
+		in, out := &in.Template, &out.Template
+		*out = new(string)
+		**out = **in
+	}
+	if in.ConfigMap != nil {
+		in, out := &in.ConfigMap, &out.ConfigMap
+		*out = new(EventTemplateConfigMap)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EventMediationTemplate.
+func (in *EventMediationTemplate) DeepCopy() *EventMediationTemplate {
+	if in == nil {
+		return nil
+	}
+	out := new(EventMediationTemplate)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *EventMediationVariable) DeepCopyInto(out *EventMediationVariable) {
 	*out = *in
@@ -292,6 +506,16 @@ func (in *EventMediationVariable) DeepCopyInto(out *EventMediationVariable) {
 		*out = new(string)
 		**out = **in
 	}
+	if in.ValueFrom != nil {
+		in, out := &in.ValueFrom, &out.ValueFrom
+		*out = new(EventVariableSource)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Default != nil {
+		in, out := &in.Default, &out.Default
+		*out = new(string)
+		**out = **in
+	}
 	return
 }
 
@@ -333,6 +557,23 @@ func (in *EventMediator) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EventMediatorCondition) DeepCopyInto(out *EventMediatorCondition) {
+	*out = *in
+	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EventMediatorCondition.
+func (in *EventMediatorCondition) DeepCopy() *EventMediatorCondition {
+	if in == nil {
+		return nil
+	}
+	out := new(EventMediatorCondition)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *EventMediatorList) DeepCopyInto(out *EventMediatorList) {
 	*out = *in
@@ -407,6 +648,51 @@ func (in *EventMediatorSpec) DeepCopyInto(out *EventMediatorSpec) {
 			}
 		}
 	}
+	if in.AllowedResources != nil {
+		in, out := &in.AllowedResources, &out.AllowedResources
+		*out = new([]EventAllowedResource)
+		if **in != nil {
+			in, out := *in, *out
+			*out = make([]EventAllowedResource, len(*in))
+			copy(*out, *in)
+		}
+	}
+	if in.Templates != nil {
+		in, out := &in.Templates, &out.Templates
+		*out = new([]EventMediationTemplate)
+		if **in != nil {
+			in, out := *in, *out
+			*out = make([]EventMediationTemplate, len(*in))
+			for i := range *in {
+				(*in)[i].DeepCopyInto(&(*out)[i])
+			}
+		}
+	}
+	if in.Limits != nil {
+		in, out := &in.Limits, &out.Limits
+		*out = new(EventMediationLimits)
+		**out = **in
+	}
+	if in.StackVersions != nil {
+		in, out := &in.StackVersions, &out.StackVersions
+		*out = new([]EventStackVersion)
+		if **in != nil {
+			in, out := *in, *out
+			*out = make([]EventStackVersion, len(*in))
+			copy(*out, *in)
+		}
+	}
+	if in.ListenerSelectors != nil {
+		in, out := &in.ListenerSelectors, &out.ListenerSelectors
+		*out = new([]EventListenerSelector)
+		if **in != nil {
+			in, out := *in, *out
+			*out = make([]EventListenerSelector, len(*in))
+			for i := range *in {
+				(*in)[i].DeepCopyInto(&(*out)[i])
+			}
+		}
+	}
 	if in.Mediations != nil {
 		in, out := &in.Mediations, &out.Mediations
 		*out = new([]EventMediationImpl)
@@ -441,6 +727,13 @@ func (in *EventMediatorStatus) DeepCopyInto(out *EventMediatorStatus) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]EventMediatorCondition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
@@ -460,7 +753,7 @@ func (in *EventRepository) DeepCopyInto(out *EventRepository) {
 	if in.Github != nil {
 		in, out := &in.Github, &out.Github
 		*out = new(EventGithubRepository)
-		**out = **in
+		(*in).DeepCopyInto(*out)
 	}
 	return
 }
@@ -496,6 +789,22 @@ func (in *EventSourceEndpoint) DeepCopy() *EventSourceEndpoint {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EventStackVersion) DeepCopyInto(out *EventStackVersion) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EventStackVersion.
+func (in *EventStackVersion) DeepCopy() *EventStackVersion {
+	if in == nil {
+		return nil
+	}
+	out := new(EventStackVersion)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *EventStatement) DeepCopyInto(out *EventStatement) {
 	*out = *in
@@ -542,6 +851,33 @@ func (in *EventStatement) DeepCopyInto(out *EventStatement) {
 			}
 		}
 	}
+	if in.Try != nil {
+		in, out := &in.Try, &out.Try
+		*out = new([]EventStatement)
+		if **in != nil {
+			in, out := *in, *out
+			*out = make([]EventStatement, len(*in))
+			for i := range *in {
+				(*in)[i].DeepCopyInto(&(*out)[i])
+			}
+		}
+	}
+	if in.OnError != nil {
+		in, out := &in.OnError, &out.OnError
+		*out = new([]EventStatement)
+		if **in != nil {
+			in, out := *in, *out
+			*out = make([]EventStatement, len(*in))
+			for i := range *in {
+				(*in)[i].DeepCopyInto(&(*out)[i])
+			}
+		}
+	}
+	if in.Fail != nil {
+		in, out := &in.Fail, &out.Fail
+		*out = new(string)
+		**out = **in
+	}
 	return
 }
 
@@ -593,6 +929,48 @@ func (in *EventStatusSummary) DeepCopy() *EventStatusSummary {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EventTemplateConfigMap) DeepCopyInto(out *EventTemplateConfigMap) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EventTemplateConfigMap.
+func (in *EventTemplateConfigMap) DeepCopy() *EventTemplateConfigMap {
+	if in == nil {
+		return nil
+	}
+	out := new(EventTemplateConfigMap)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EventVariableSource) DeepCopyInto(out *EventVariableSource) {
+	*out = *in
+	if in.SecretKeyRef != nil {
+		in, out := &in.SecretKeyRef, &out.SecretKeyRef
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ConfigMapKeyRef != nil {
+		in, out := &in.ConfigMapKeyRef, &out.ConfigMapKeyRef
+		*out = new(v1.ConfigMapKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EventVariableSource.
+func (in *EventVariableSource) DeepCopy() *EventVariableSource {
+	if in == nil {
+		return nil
+	}
+	out := new(EventVariableSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *HttpsEndpoint) DeepCopyInto(out *HttpsEndpoint) {
 	*out = *in
@@ -7,6 +7,7 @@ import (
     "github.com/go-logr/logr"
     eventsv1alpha1 "github.com/kabanero-io/events-operator/pkg/apis/events/v1alpha1"
     "github.com/kabanero-io/events-operator/pkg/status"
+    "github.com/kabanero-io/events-operator/pkg/debug"
     "github.com/kabanero-io/events-operator/pkg/event"
     "github.com/kabanero-io/events-operator/pkg/eventcel"
     "github.com/kabanero-io/events-operator/pkg/eventenv"
@@ -32,20 +33,28 @@ import (
     "sigs.k8s.io/controller-runtime/pkg/predicate"
     "sigs.k8s.io/controller-runtime/pkg/reconcile"
     "sigs.k8s.io/controller-runtime/pkg/source"
+    "k8s.io/client-go/util/workqueue"
+    toolscache "k8s.io/client-go/tools/cache"
 
     triggers "github.com/tektoncd/triggers/pkg/apis/triggers/v1alpha1"
 
     "bytes"
     "crypto/tls"
+    "encoding/json"
     "fmt"
     "k8s.io/klog"
     "net/http"
+    "sort"
     "strings"
+    "sync"
     "time"
 )
 
 const (
     EVENTS_OPERATOR = "events-operator"
+
+    MATCH_POLICY_FIRST = "firstMatch" // process an event with the first mediation that matches
+    MATCH_POLICY_ALL = "allMatches" // process an event with every mediation that matches
 )
 
 var log = logf.Log.WithName("controller_eventmediator")
@@ -139,11 +148,44 @@ func add(mgr manager.Manager, r reconcile.Reconciler) error {
         }
         klog.Infof("Started to watch Secrets")
 
-        /* Should only watch stacks and Tekton listeners if Kabanero integrtion is enabled */
+        /* watch for ConfigMaps to drop cached templates when they change */
+        err = c.Watch(
+        &source.Kind{Type: &corev1.ConfigMap{}},
+        &handler.Funcs{
+            UpdateFunc: func(e k8sevent.UpdateEvent, q workqueue.RateLimitingInterface) {
+                eventenv.GetEventEnv().TemplateMgr.Invalidate(e.MetaNew.GetNamespace(), e.MetaNew.GetName())
+            },
+            DeleteFunc: func(e k8sevent.DeleteEvent, q workqueue.RateLimitingInterface) {
+                eventenv.GetEventEnv().TemplateMgr.Invalidate(e.Meta.GetNamespace(), e.Meta.GetName())
+            },
+        })
+	    if err != nil {
+            klog.Infof("Unable to watch ConfigMaps: %v", err)
+		    return err
+        }
+        klog.Infof("Started to watch ConfigMaps")
+
+        /* Should only watch stacks and Tekton listeners if Kabanero integrtion is enabled.
+           They feed the index that resolves stacks to event listeners. Status changes are not filtered, as they hold the active versions and listener URLs */
         if eventenv.GetEventEnv().KabaneroIntegration {
+            stackIndex := utils.NewStackListenerIndex()
             err = c.Watch(
             &source.Kind{Type: &kabanerov1alpha2.Stack{}},
-            &handler.EnqueueRequestForObject{ }, controllerPredicate)
+            &handler.Funcs{
+                CreateFunc: func(e k8sevent.CreateEvent, q workqueue.RateLimitingInterface) {
+                    if stack, ok := e.Object.(*kabanerov1alpha2.Stack); ok {
+                        updateStackIndex(stackIndex, func(index *utils.StackListenerIndex) { index.SetStack(stack) })
+                    }
+                },
+                UpdateFunc: func(e k8sevent.UpdateEvent, q workqueue.RateLimitingInterface) {
+                    if stack, ok := e.ObjectNew.(*kabanerov1alpha2.Stack); ok {
+                        updateStackIndex(stackIndex, func(index *utils.StackListenerIndex) { index.SetStack(stack) })
+                    }
+                },
+                DeleteFunc: func(e k8sevent.DeleteEvent, q workqueue.RateLimitingInterface) {
+                    updateStackIndex(stackIndex, func(index *utils.StackListenerIndex) { index.DeleteStack(e.Meta.GetNamespace(), e.Meta.GetName()) })
+                },
+            })
 	        if err != nil {
                 /* we may be running in an environment where stacks are not defined */
                 klog.Infof("Unable to watch stacks: %v", err)
@@ -153,19 +195,116 @@ func add(mgr manager.Manager, r reconcile.Reconciler) error {
 
             err = c.Watch(
             &source.Kind{Type: &triggers.EventListener{}},
-            &handler.EnqueueRequestForObject{ }, controllerPredicate)
+            &handler.Funcs{
+                CreateFunc: func(e k8sevent.CreateEvent, q workqueue.RateLimitingInterface) {
+                    if listener, ok := e.Object.(*triggers.EventListener); ok {
+                        updateStackIndex(stackIndex, func(index *utils.StackListenerIndex) { index.SetEventListener(listener) })
+                    }
+                },
+                UpdateFunc: func(e k8sevent.UpdateEvent, q workqueue.RateLimitingInterface) {
+                    if listener, ok := e.ObjectNew.(*triggers.EventListener); ok {
+                        updateStackIndex(stackIndex, func(index *utils.StackListenerIndex) { index.SetEventListener(listener) })
+                    }
+                },
+                DeleteFunc: func(e k8sevent.DeleteEvent, q workqueue.RateLimitingInterface) {
+                    updateStackIndex(stackIndex, func(index *utils.StackListenerIndex) { index.DeleteEventListener(e.Meta.GetNamespace(), e.Meta.GetName()) })
+                },
+            })
 	        if err != nil {
                 /* we may be running in an environment where stacks are not defined */
                 klog.Infof("Unable to watch EventListener: %v", err)
                 return err
             }
             klog.Infof("Started watching EventListener")
+
+            /* Lookups read the API server until the watches have synced, and if they never do */
+            env := eventenv.GetEventEnv()
+            env.StackIndex = stackIndex
+            env.DebugMgr.AddStats("stackIndex", func() interface{} {
+                return stackIndex.Stats()
+            })
+            /* The informers of the watches only exist once the manager starts the controller, so wait for them in a runnable of the manager */
+            err = mgr.Add(manager.RunnableFunc(func(stop <-chan struct{}) error {
+                stackInformer, err := mgr.GetCache().GetInformer(&kabanerov1alpha2.Stack{})
+                if err != nil {
+                    klog.Errorf("Stack index not used: unable to get the informer of stacks: %v", err)
+                    return nil
+                }
+                listenerInformer, err := mgr.GetCache().GetInformer(&triggers.EventListener{})
+                if err != nil {
+                    klog.Errorf("Stack index not used: unable to get the informer of event listeners: %v", err)
+                    return nil
+                }
+                if !toolscache.WaitForCacheSync(stop, stackInformer.HasSynced, listenerInformer.HasSynced) {
+                    klog.Errorf("Stack index not used: the watches of stacks and event listeners did not sync")
+                    return nil
+                }
+                klog.Infof("Stack index synced")
+                stackIndex.SetSynced()
+                scheduleStackCondition(stackIndex)
+                return nil
+            }))
+	        if err != nil {
+                return err
+            }
         }
     }
 
 	return nil
 }
 
+/* How long changes of the stack index accumulate before the condition on stack listeners is updated, so that bursts of changes update it once */
+const STACK_CONDITION_DELAY = 2 * time.Second
+
+var stackConditionMutex sync.Mutex
+var stackConditionScheduled bool // true if the condition is to be updated
+
+/* Apply a change to the stack index, and schedule the update of the condition of the mediator */
+func updateStackIndex(stackIndex *utils.StackListenerIndex, change func(*utils.StackListenerIndex)) {
+    change(stackIndex)
+    scheduleStackCondition(stackIndex)
+}
+
+/* Update the condition after STACK_CONDITION_DELAY, unless already scheduled. Not until the index has synced, as it is incomplete before */
+func scheduleStackCondition(stackIndex *utils.StackListenerIndex) {
+    if !stackIndex.Synced() {
+        return
+    }
+    stackConditionMutex.Lock()
+    defer stackConditionMutex.Unlock()
+
+    if stackConditionScheduled {
+        return
+    }
+    stackConditionScheduled = true
+    time.AfterFunc(STACK_CONDITION_DELAY, func() {
+        stackConditionMutex.Lock()
+        stackConditionScheduled = false
+        stackConditionMutex.Unlock()
+        updateStackCondition(stackIndex)
+    })
+}
+
+/* Update the condition of the mediator on the active stack versions without event listeners */
+func updateStackCondition(stackIndex *utils.StackListenerIndex) {
+    env := eventenv.GetEventEnv()
+    condition := eventsv1alpha1.EventMediatorCondition {
+        Type: status.CONDITION_STACK_LISTENERS_AVAILABLE,
+        Status: status.CONDITION_TRUE,
+        Reason: status.REASON_LISTENERS_AVAILABLE,
+        Message: "All active stack versions have an event listener",
+    }
+    if missing := stackIndex.StacksWithoutListeners(); len(missing) > 0 {
+        condition.Status = status.CONDITION_FALSE
+        condition.Reason = status.REASON_LISTENERS_MISSING
+        condition.Message = "Active stack versions without a usable event listener: " + strings.Join(missing, ", ")
+    }
+    env.StatusMgr.SetCondition(condition)
+    if env.StatusUpdater != nil {
+        env.StatusMgr.SendStatus(env.StatusUpdater)
+    }
+}
+
 // blank assignment to verify that ReconcileEventMediator implements reconcile.Reconciler
 var _ reconcile.Reconciler = &ReconcileEventMediator{}
 
@@ -212,7 +351,21 @@ func (r *ReconcileEventMediator) Reconcile(request reconcile.Request) (reconcile
         if instance.ObjectMeta.Name ==  env.MediatorName {
             /*  We should handle this */
             env := eventenv.GetEventEnv()
-            env.EventMgr.AddEventMediator(instance)
+            if err := env.EventMgr.AddEventMediator(instance); err != nil {
+                /* The previous version of the mediator, if any, remains in use. Not requeued, as only a change to the mediator fixes it. */
+                reqLogger.Error(err, "unable to load EventMediator")
+                summary := &eventsv1alpha1.EventStatusSummary  {
+                     Operation: status.OPERATION_LOAD_MEDIATOR,
+                     Input: []eventsv1alpha1.EventStatusParameter { },
+                     Result: status.RESULT_FAILED,
+                     Message: err.Error(),
+                }
+                env.StatusMgr.AddEventSummary(summary)
+                if env.StatusUpdater != nil {
+                    env.StatusMgr.SendStatus(env.StatusUpdater)
+                }
+                return reconcile.Result{}, nil
+            }
 
             if instance.Spec.CreateListener {
                 port :=  getListenerPort(instance)
@@ -220,7 +373,7 @@ func (r *ReconcileEventMediator) Reconcile(request reconcile.Request) (reconcile
                     /* start new listener */
                     key := eventsv1alpha1.MediatorHashKey(instance)
                     workerQueue := event.NewQueue()
-                    listenerHandler, err := validateMessageHandler(key, event.EnqueueHandler(workerQueue))
+                    listenerHandler, err := validateMessageHandler(key, event.EnqueueHandler(env.Context, workerQueue))
                     if err != nil {
                         return reconcile.Result{}, err
                     }
@@ -242,7 +395,7 @@ func (r *ReconcileEventMediator) Reconcile(request reconcile.Request) (reconcile
                     }
 
                     // Start the queue worker
-                    go event.ProcessQueueWorker(workerQueue, generateMessageHandler(env, key))
+                    go event.ProcessQueueWorker(env.Context, workerQueue, generateMessageHandler(env, key))
                 }
             }
         }
@@ -583,51 +736,130 @@ func portChangedForService(service *corev1.Service, mediator *eventsv1alpha1.Eve
 
 
 
+/* Result of matching a mediation to an event */
+type mediationMatch struct {
+    matches bool // true if the mediation should be used
+    hasRepoType bool // true if the repository marker file is specified
+    repoTypeValue map[string]interface{} // content of the repository marker file, if specified and it exists
+    pathParams map[string]string // parameters captured by the urlPattern, or nil if it is not a pattern
+    command *utils.GithubCommand // the ChatOps command of the event, if the selector has a command
+    changedFiles []string // files changed by the event that are selected by the paths, if the selector has paths or discovers projects
+    projects []mediationProject // projects discovered through the repository marker file, each processed separately. nil if not discovering
+}
+
+/* A project of a monorepo: a directory that contains the repository marker file */
+type mediationProject struct {
+    dir string // directory of the project, or . for the root of the repository
+    repoTypeValue map[string]interface{} // content of the marker file of the project
+}
+
 /* Check if the mediation should be used to process this event
   Input:
+       ctx: context of the event
        mediationImpl: the mediation to match
+       urlPattern: the compiled urlPattern of the selector of the mediation, or nil if there is no selector
        body: the incoming message body
        header: the header of the message
        path: the path component of the incoming request
        kubeClient: kubernetes client
   Return 
-   error: error message if not nil
-   bool: true if mediation should be used. A mediation matches if :
+   mediationMatch: the result of matching. The mediation should be used if matches is true. A mediation matches if :
       - A selector is not present, and the name of the mediation matches the path
       - A selector is present, and 
         - the urlPattern, if specified, matches the path. 
-        - the repository marker file, if specified, is found.
-   bool: true if repository marker file is specified
-   map[string]interface{}: content of the repository marker file, if the repository marker file is specified and exists.
-                           An error message is returned if the marker file is specified, but there is a problem in
-                           locating and reading it.
+        - the condition, if specified, evaluates to true.
+        - the command, if specified, is in a new comment on a pull request by a user with the required permission.
+        - the paths, if specified, select a file changed by the push or pull request.
+        - the repository marker file, if specified, is found. When discovering projects, it is found in a directory of a changed file,
+          or in one of their parents.
+   error: error message if not nil. An error message is returned if the marker file is specified, but there is a problem in
+          locating and reading it, or if the paths are specified, but the changed files cannot be read.
 */
-func mediationMatches(mediator *eventsv1alpha1.EventMediator, mediationImpl *eventsv1alpha1.EventMediationImpl, header map[string][]string, 
-    body map[string]interface{}, path string, kubeClient client.Client, namespace string, remoteAddr string) (error, bool, bool, map[string]interface{}) {
+func mediationMatches(ctx context.Context, mediator *eventsv1alpha1.EventMediator, mediationImpl *eventsv1alpha1.EventMediationImpl, urlPattern *utils.URLPattern, header map[string][]string, 
+    body map[string]interface{}, path string, kubeClient client.Client, namespace string, remoteAddr string) (*mediationMatch, error) {
     klog.Infof("Entry mediationMatches() for mediation %v, path %s", mediationImpl.Name, path)
 
     emptyMap := make(map[string]interface{})
     if mediationImpl.Selector == nil {
         /* no selector. Mediation name is the path name */
         if  mediationImpl.Name == path {
-            return nil, true, false, emptyMap
+            return &mediationMatch{ matches: true, hasRepoType: false, repoTypeValue: emptyMap, pathParams: nil }, nil
         }
     } else {
         selector := mediationImpl.Selector
-        urlPatternMatch := selector.UrlPattern == path
-        klog.Infof("url path matching selector urlPattern %v to path %v, result: %v", selector.UrlPattern, path, urlPatternMatch)
+        urlPatternMatch, pathParams := urlPattern.Match(path)
+        klog.Infof("url path matching selector urlPattern %v to path %v, result: %v, parameters: %v", selector.UrlPattern, path, urlPatternMatch, pathParams)
 
         if !urlPatternMatch {
-            return nil, false, false, emptyMap
+            return &mediationMatch{ matches: false, hasRepoType: false, repoTypeValue: emptyMap, pathParams: pathParams }, nil
+        }
+
+        if selector.Condition != "" {
+            /* evaluated before the repository file is downloaded, so that events that do not match skip the download */
+            conditionMatch, err := eventcel.MatchCondition(ctx, mediator, header, body, selector.Condition)
+            if err != nil {
+                /* for example, the body does not contain an attribute of the condition */
+                summary := &eventsv1alpha1.EventStatusSummary  {
+                     Operation: status.OPERATION_FIND_MEDIATION,
+                     Input: []eventsv1alpha1.EventStatusParameter { 
+                                { Name: status.PARAM_MEDIATION,
+                                  Value: mediationImpl.Name,
+                                },
+                            },
+                     Result: status.RESULT_FAILED,
+                     Message: fmt.Sprintf("Unable to evaluate selector condition %v: %v", selector.Condition, err),
+                }
+                eventenv.GetEventEnv().StatusMgr.AddEventSummary(summary)
+                klog.Infof("mediation %v does not match: unable to evaluate selector condition %v: %v", mediationImpl.Name, selector.Condition, err)
+                return &mediationMatch{ matches: false, hasRepoType: false, repoTypeValue: emptyMap, pathParams: pathParams }, nil
+            }
+            klog.Infof("selector condition %v of mediation %v, result: %v", selector.Condition, mediationImpl.Name, conditionMatch)
+            if !conditionMatch {
+                return &mediationMatch{ matches: false, hasRepoType: false, repoTypeValue: emptyMap, pathParams: pathParams }, nil
+            }
+        }
+
+        var err error
+        var command *utils.GithubCommand
+        if selector.Command != nil {
+            command, err = matchCommand(ctx, mediator, mediationImpl, header, body, kubeClient, namespace)
+            if err != nil {
+                return nil, err
+            }
+            if command == nil {
+                return &mediationMatch{ matches: false, hasRepoType: false, repoTypeValue: emptyMap, pathParams: pathParams }, nil
+            }
         }
 
         repositoryType := selector.RepositoryType
+        discover := repositoryType != nil && repositoryType.Discover
+        var changedFiles []string
+        if selector.Paths != nil || discover {
+            var hasChanges bool
+            changedFiles, hasChanges, err = getChangedFiles(ctx, mediator, mediationImpl, header, body, command, kubeClient, namespace)
+            if err != nil {
+                return nil, err
+            }
+            if !hasChanges {
+                klog.Infof("mediation %v does not match: the event changes no files", mediationImpl.Name)
+                return &mediationMatch{ matches: false, hasRepoType: false, repoTypeValue: emptyMap, pathParams: pathParams }, nil
+            }
+            if selector.Paths != nil {
+                changedFiles = utils.FilterPaths(changedFiles, selector.Paths.Include, selector.Paths.Exclude)
+                if len(changedFiles) == 0 {
+                    klog.Infof("mediation %v does not match: no changed file is selected by paths %v", mediationImpl.Name, *selector.Paths)
+                    return &mediationMatch{ matches: false, hasRepoType: false, repoTypeValue: emptyMap, pathParams: pathParams }, nil
+                }
+            }
+            klog.Infof("changed files of mediation %v: %v", mediationImpl.Name, changedFiles)
+        }
+
         if repositoryType == nil {
-            return nil, true, false, emptyMap
+            return &mediationMatch{ matches: true, hasRepoType: false, repoTypeValue: emptyMap, pathParams: pathParams, command: command, changedFiles: changedFiles }, nil
         }
 
         if repositoryType.NewVariable == "" {
-            return fmt.Errorf("newVariable not specified for Selector.RepositoryType of Mediation %v", mediationImpl.Name), false, false, emptyMap
+            return nil, fmt.Errorf("newVariable not specified for Selector.RepositoryType of Mediation %v", mediationImpl.Name)
         }
 
         /* Only works with GitHub */
@@ -643,44 +875,327 @@ func mediationMatches(mediator *eventsv1alpha1.EventMediator, mediationImpl *eve
                  Message: fmt.Sprintf("repositoryType not supported for non-github repository"),
             }
             eventenv.GetEventEnv().StatusMgr.AddEventSummary(summary)
-            return fmt.Errorf("unable to process non-GitHub message for mediation %v", mediationImpl.Name), false, false, emptyMap
+            return nil, fmt.Errorf("unable to process non-GitHub message for mediation %v", mediationImpl.Name)
         }
 
-        var secretName string = ""
-        if mediator.Spec.Repositories != nil {
-            for _, repo := range *mediator.Spec.Repositories {
-                if repo.Github != nil {
-                     secretName = repo.Github.Secret
-                     break
-                }
+        githubRepo, err := matchGithubRepository(mediator, mediationImpl, body)
+        if err != nil {
+            return nil, err
+        }
+        /* the files of a command are read at the head of its pull request, as the comment event has no commit */
+        ref := ""
+        if command != nil && command.PullRequest != nil {
+            ref = command.PullRequest.HeadSHA
+        }
+        if discover {
+            projects, err := discoverProjects(ctx, mediationImpl, githubRepo, header, body, kubeClient, namespace, changedFiles, ref)
+            if err != nil {
+                return nil, err
+            }
+            if len(projects) == 0 {
+                klog.Infof("mediation %v does not match: no %v found for the changed files", mediationImpl.Name, repositoryType.File)
+                return &mediationMatch{ matches: false, hasRepoType: true, repoTypeValue: emptyMap, pathParams: pathParams }, nil
             }
+            return &mediationMatch{ matches: true, hasRepoType: true, repoTypeValue: emptyMap, pathParams: pathParams, command: command, changedFiles: changedFiles, projects: projects }, nil
         }
-        yaml, exists, err := utils.DownloadYAML(kubeClient, namespace, secretName, header, body, repositoryType.File)
+
+        yaml, exists, err := downloadRepositoryFile(ctx, mediationImpl, githubRepo, header, body, kubeClient, namespace, repositoryType.File, ref)
         if err != nil {
-            // error reading the yaml
-            summary := &eventsv1alpha1.EventStatusSummary  {
-                 Operation: status.OPERATION_FIND_MEDIATION,
-                 Input: []eventsv1alpha1.EventStatusParameter { 
-                            { Name: status.PARAM_MEDIATION,
-                              Value: mediationImpl.Name,
-                            },
-                            { Name: status.PARAM_FILE,
-                              Value: repositoryType.File,
-                            },
-                        },
-                 Result: status.RESULT_FAILED,
-                 Message: fmt.Sprintf("Unable to download file. Error: %v", err),
-            }
-            eventenv.GetEventEnv().StatusMgr.AddEventSummary(summary)
-            return  err, false, true, emptyMap
+            return nil, err
         }
         if !exists{
             // file does not exist
-            return nil, false, true, emptyMap
+            return &mediationMatch{ matches: false, hasRepoType: true, repoTypeValue: emptyMap, pathParams: pathParams }, nil
+        }
+        return &mediationMatch{ matches: true, hasRepoType: true, repoTypeValue: yaml, pathParams: pathParams, command: command, changedFiles: changedFiles }, nil
+    }
+    return &mediationMatch{ matches: false, hasRepoType: false, repoTypeValue: emptyMap, pathParams: nil }, nil
+}
+
+/* Download a YAML file from the repository of the event, at commit ref, or at the commit of the event if ref is "". Return the file, and whether it exists */
+func downloadRepositoryFile(ctx context.Context, mediationImpl *eventsv1alpha1.EventMediationImpl, githubRepo *eventsv1alpha1.EventGithubRepository, header map[string][]string,
+    body map[string]interface{}, kubeClient client.Client, namespace string, fileName string, ref string) (map[string]interface{}, bool, error) {
+    yaml, exists, err := eventenv.GetEventEnv().DownloadYAML(ctx, kubeClient, namespace, githubRepo, header, body, fileName, ref)
+    if err != nil {
+        // error reading the yaml
+        summary := &eventsv1alpha1.EventStatusSummary  {
+             Operation: status.OPERATION_FIND_MEDIATION,
+             Input: []eventsv1alpha1.EventStatusParameter { 
+                        { Name: status.PARAM_MEDIATION,
+                          Value: mediationImpl.Name,
+                        },
+                        { Name: status.PARAM_FILE,
+                          Value: fileName,
+                        },
+                    },
+             Result: status.RESULT_FAILED,
+             Message: fmt.Sprintf("Unable to download file. Error: %v", err),
+        }
+        eventenv.GetEventEnv().StatusMgr.AddEventSummary(summary)
+        return nil, false, err
+    }
+    return yaml, exists, nil
+}
+
+/* Return the files changed by a push or pull request event, or by the pull request of a ChatOps command.
+   The files of a push are listed by its commits, unless its payload does not list all of them.
+   Otherwise, they are read through the compare API of GitHub.
+   Return false if the event changes no files, such as a ping, a non-GitHub event, or the push that deletes a branch.
+*/
+func getChangedFiles(ctx context.Context, mediator *eventsv1alpha1.EventMediator, mediationImpl *eventsv1alpha1.EventMediationImpl, header map[string][]string,
+    body map[string]interface{}, command *utils.GithubCommand, kubeClient client.Client, namespace string) ([]string, bool, error) {
+    failed := func(err error) error {
+        summary := &eventsv1alpha1.EventStatusSummary  {
+             Operation: status.OPERATION_FIND_MEDIATION,
+             Input: []eventsv1alpha1.EventStatusParameter { 
+                        { Name: status.PARAM_MEDIATION,
+                          Value: mediationImpl.Name,
+                        },
+                    },
+             Result: status.RESULT_FAILED,
+             Message: fmt.Sprintf("Unable to get the changed files: %v", err),
+        }
+        eventenv.GetEventEnv().StatusMgr.AddEventSummary(summary)
+        return fmt.Errorf("unable to get the changed files for mediation %v: %v", mediationImpl.Name, err)
+    }
+    if !utils.IsHeaderGithub(header) {
+        return nil, false, nil
+    }
+
+    var refs *utils.PullRequestRefs
+    if command != nil {
+        refs = command.PullRequest
+    } else if files, complete := utils.GetPushChangedFiles(header, body); complete {
+        return files, true, nil
+    }
+    base, head, hasChanges, err := utils.GetChangeRefs(header, body, refs)
+    if err != nil {
+        return nil, false, failed(err)
+    }
+    if !hasChanges {
+        return nil, false, nil
+    }
+    if base == "" {
+        /* the push of a new branch has no base to compare to */
+        files, _ := utils.GetPushChangedFiles(header, body)
+        return files, true, nil
+    }
+
+    githubRepo, err := matchGithubRepository(mediator, mediationImpl, body)
+    if err != nil {
+        return nil, false, err
+    }
+    files, err := eventenv.GetEventEnv().CompareCommits(ctx, kubeClient, namespace, githubRepo, header, body, base, head)
+    if err != nil {
+        return nil, false, failed(err)
+    }
+    if len(files) >= utils.GITHUB_COMPARE_FILES_MAX {
+        /* GitHub does not list the files after the first 300: the mediation may miss them */
+        summary := &eventsv1alpha1.EventStatusSummary  {
+             Operation: status.OPERATION_FIND_MEDIATION,
+             Input: []eventsv1alpha1.EventStatusParameter { 
+                        { Name: status.PARAM_MEDIATION,
+                          Value: mediationImpl.Name,
+                        },
+                    },
+             Result: status.RESULT_WARNING,
+             Message: fmt.Sprintf("GitHub lists only the first %v files changed between %v and %v. The other changed files are not selected by paths, and their projects are not discovered", utils.GITHUB_COMPARE_FILES_MAX, base, head),
         }
-        return nil, true, true, yaml
+        eventenv.GetEventEnv().StatusMgr.AddEventSummary(summary)
+        klog.Warningf("mediation %v: the changed files between %v and %v may be truncated to %v", mediationImpl.Name, base, head, utils.GITHUB_COMPARE_FILES_MAX)
     }
-    return nil, false, false, emptyMap
+    return files, true, nil
+}
+
+/* Discover the projects of the changed files of a monorepo. The project of a file is the nearest directory that contains
+   the repository marker file: its directory, or one of the parents. The directories that contain a marker file are found in the
+   files of the repository, listed once, and the marker files are read at commit ref, or at the commit of the event if ref is "".
+   If GitHub truncates the list of files, the marker file of each directory is read instead.
+   Return the projects in the order they are found.
+*/
+func discoverProjects(ctx context.Context, mediationImpl *eventsv1alpha1.EventMediationImpl, githubRepo *eventsv1alpha1.EventGithubRepository, header map[string][]string,
+    body map[string]interface{}, kubeClient client.Client, namespace string, changedFiles []string, ref string) ([]mediationProject, error) {
+    markerFile := mediationImpl.Selector.RepositoryType.File
+    repoFiles, complete, err := eventenv.GetEventEnv().ListFiles(ctx, kubeClient, namespace, githubRepo, header, body, ref)
+    if err != nil {
+        summary := &eventsv1alpha1.EventStatusSummary  {
+             Operation: status.OPERATION_FIND_MEDIATION,
+             Input: []eventsv1alpha1.EventStatusParameter { 
+                        { Name: status.PARAM_MEDIATION,
+                          Value: mediationImpl.Name,
+                        },
+                    },
+             Result: status.RESULT_FAILED,
+             Message: fmt.Sprintf("Unable to list the files of the repository. Error: %v", err),
+        }
+        eventenv.GetEventEnv().StatusMgr.AddEventSummary(summary)
+        return nil, err
+    }
+    /* the directories that contain the marker file, or nil to check each directory if the list is truncated */
+    var markerDirs map[string]bool
+    if complete {
+        markerDirs = make(map[string]bool)
+        for _, file := range repoFiles {
+            if file == markerFile {
+                markerDirs[utils.ROOT_DIRECTORY] = true
+            } else if strings.HasSuffix(file, "/" + markerFile) {
+                markerDirs[strings.TrimSuffix(file, "/" + markerFile)] = true
+            }
+        }
+    } else {
+        klog.Infof("mediation %v: the files of the repository are truncated, reading the %v of each directory", mediationImpl.Name, markerFile)
+    }
+    /* content of the marker file of each directory checked, or nil if it does not exist */
+    markers := make(map[string]map[string]interface{})
+    projects := make([]mediationProject, 0)
+    found := make(map[string]bool)
+    for _, file := range changedFiles {
+        for _, dir := range utils.ParentDirectories(file) {
+            yaml, checked := markers[dir]
+            if !checked && markerDirs != nil && !markerDirs[dir] {
+                markers[dir] = nil
+                continue
+            }
+            if !checked {
+                fileName := markerFile
+                if dir != utils.ROOT_DIRECTORY {
+                    fileName = dir + "/" + markerFile
+                }
+                var exists bool
+                yaml, exists, err = downloadRepositoryFile(ctx, mediationImpl, githubRepo, header, body, kubeClient, namespace, fileName, ref)
+                if err != nil {
+                    return nil, err
+                }
+                if !exists {
+                    yaml = nil
+                }
+                markers[dir] = yaml
+            }
+            if yaml == nil {
+                continue
+            }
+            if !found[dir] {
+                found[dir] = true
+                projects = append(projects, mediationProject{ dir: dir, repoTypeValue: yaml })
+                klog.Infof("mediation %v discovered project %v", mediationImpl.Name, dir)
+            }
+            break
+        }
+    }
+    return projects, nil
+}
+
+/* Return the GitHub configuration of the first repository entry of the mediator that matches the repository of the event.
+   Its API token is used to access the repository. Return nil if the mediator has no repositories, and an error if none matches.
+*/
+func matchGithubRepository(mediator *eventsv1alpha1.EventMediator, mediationImpl *eventsv1alpha1.EventMediationImpl, body map[string]interface{}) (*eventsv1alpha1.EventGithubRepository, error) {
+    if mediator.Spec.Repositories == nil || len(*mediator.Spec.Repositories) == 0 {
+        return nil, nil
+    }
+    htmlURL := utils.GetRepositoryURL(body)
+    githubRepos := utils.MatchGithubRepositories(mediator.Spec.Repositories, htmlURL)
+    if len(githubRepos) == 0 {
+        summary := &eventsv1alpha1.EventStatusSummary  {
+             Operation: status.OPERATION_FIND_MEDIATION,
+             Input: []eventsv1alpha1.EventStatusParameter { 
+                        { Name: status.PARAM_MEDIATION,
+                          Value: mediationImpl.Name,
+                        },
+                        { Name: status.PARAM_REPOSITORY,
+                          Value: htmlURL,
+                        },
+                    },
+             Result: status.RESULT_FAILED,
+             Message: fmt.Sprintf("No repository of the mediator matches repository %v. Check the urlPattern and org of the repositories", htmlURL),
+        }
+        eventenv.GetEventEnv().StatusMgr.AddEventSummary(summary)
+        return nil, fmt.Errorf("no repository of mediator %v matches repository %v", mediator.Name, htmlURL)
+    }
+    return githubRepos[0], nil
+}
+
+/* Match the command selector of a mediation to the ChatOps command of a comment on a pull request.
+   Return the command if the comment is a command of the selector, and its author has the required permission on the repository.
+   The head and base of the pull request are read from GitHub, as the issue_comment event does not contain them.
+   Return nil if the event is not such a command, or the author does not have the permission.
+*/
+func matchCommand(ctx context.Context, mediator *eventsv1alpha1.EventMediator, mediationImpl *eventsv1alpha1.EventMediationImpl, header map[string][]string,
+    body map[string]interface{}, kubeClient client.Client, namespace string) (*utils.GithubCommand, error) {
+    selector := mediationImpl.Selector.Command
+    command := utils.GetGithubCommand(header, body)
+    if command == nil {
+        return nil, nil
+    }
+    if len(selector.Names) > 0 {
+        found := false
+        for _, name := range selector.Names {
+            if name == command.Name {
+                found = true
+                break
+            }
+        }
+        if !found {
+            klog.Infof("command /%v is not a command of mediation %v", command.Name, mediationImpl.Name)
+            return nil, nil
+        }
+    }
+
+    permission := selector.Permission
+    if permission == "" {
+        permission = utils.GITHUB_PERMISSION_WRITE
+    }
+    htmlURL := utils.GetRepositoryURL(body)
+    params := []eventsv1alpha1.EventStatusParameter {
+                  { Name: status.PARAM_MEDIATION,
+                    Value: mediationImpl.Name,
+                  },
+                  { Name: status.PARAM_REPOSITORY,
+                    Value: htmlURL,
+                  },
+                  { Name: status.PARAM_COMMAND,
+                    Value: command.Name,
+                  },
+                  { Name: status.PARAM_USER,
+                    Value: command.User,
+                  },
+              }
+    failed := func(message string) {
+        summary := &eventsv1alpha1.EventStatusSummary  {
+             Operation: status.OPERATION_CHECK_COMMAND_PERMISSION,
+             Input: params,
+             Result: status.RESULT_FAILED,
+             Message: message,
+        }
+        eventenv.GetEventEnv().StatusMgr.AddEventSummary(summary)
+    }
+    if !utils.IsValidGithubPermission(permission) {
+        failed(fmt.Sprintf("Invalid permission %v of command selector. Use read, triage, write, maintain, or admin", permission))
+        return nil, fmt.Errorf("invalid permission %v of the command selector of mediation %v", permission, mediationImpl.Name)
+    }
+
+    githubRepo, err := matchGithubRepository(mediator, mediationImpl, body)
+    if err != nil {
+        return nil, err
+    }
+    userPermission, err := eventenv.GetEventEnv().GetPermission(ctx, kubeClient, namespace, githubRepo, header, body, command.User)
+    if err != nil {
+        failed(fmt.Sprintf("Unable to read the permission of user %v on repository %v: %v", command.User, htmlURL, err))
+        return nil, err
+    }
+    if !utils.HasGithubPermission(userPermission, permission) {
+        failed(fmt.Sprintf("User %v has %v permission on repository %v, but command /%v requires %v permission", command.User, userPermission, htmlURL, command.Name, permission))
+        klog.Infof("mediation %v does not match: user %v has %v permission, command /%v requires %v", mediationImpl.Name, command.User, userPermission, command.Name, permission)
+        return nil, nil
+    }
+
+    refs, err := eventenv.GetEventEnv().GetPullRequest(ctx, kubeClient, namespace, githubRepo, header, body, command.PullRequest.Number)
+    if err != nil {
+        failed(fmt.Sprintf("Unable to read pull request %v of repository %v: %v", command.PullRequest.Number, htmlURL, err))
+        return nil, err
+    }
+    command.PullRequest = refs
+    klog.Infof("command /%v %v of user %v on pull request %v, head %v at %v", command.Name, command.Args, command.User, refs.Number, refs.HeadRef, refs.HeadSHA)
+    return command, nil
 }
 
 func validateMessageHandler(mediatorKey string, nextHandler http.Handler) (http.Handler, error) {
@@ -737,22 +1252,45 @@ func validateMessageHandler(mediatorKey string, nextHandler http.Handler) (http.
                 return
             }
 
-            for _, repo := range *mediator.Spec.Repositories {
-                if repo.Github != nil {
-                    webhookSecret, err := utils.GetWebhookSecret(env.Client, env.Namespace, repo.Github.WebhookSecret)
-                    if err != nil {
-                         klog.Errorf("found X-Hub-Signature but unable to get webhook secret. Error: %v", err)
-                         break
-                    }
+            /* Only the webhook secrets of the repository entries matching the repository of the event are tried */
+            bodyMap := make(map[string]interface{})
+            if jsonErr := json.Unmarshal(body, &bodyMap); jsonErr != nil {
+                klog.Infof("unable to parse body of the event to find its repository: %v", jsonErr)
+            }
+            htmlURL := utils.GetRepositoryURL(bodyMap)
+            githubRepos := utils.MatchGithubRepositories(mediator.Spec.Repositories, htmlURL)
+            if len(githubRepos) == 0 {
+                klog.Errorf("found X-Hub-Signature header but no repository of the mediator matches repository %v -- ignoring request", htmlURL)
+                w.WriteHeader(http.StatusBadRequest)
+                summary := &eventsv1alpha1.EventStatusSummary  {
+                     Operation: status.OPERATION_VALIDATE_WEBHOOK_SECRET,
+                     Input: []eventsv1alpha1.EventStatusParameter { 
+                                { Name: status.PARAM_REPOSITORY,
+                                  Value: htmlURL,
+                                },
+                            },
+                     Result: status.RESULT_FAILED,
+                     Message: fmt.Sprintf("No repository of the mediator matches repository %v. Check the urlPattern and org of the repositories", htmlURL),
+                }
+                eventenv.GetEventEnv().StatusMgr.AddEventSummary(summary)
+                return
+            }
 
-                    err = utils.ValidatePayload(sigType, sig, webhookSecret, body)
-                    // Found a secret that validates the payload
-                    if err == nil {
-                        // XXX: Need to set a new body so the next handler can read the body too
-                        r.Body = ioutil.NopCloser(bytes.NewBuffer(body))
-                        nextHandler.ServeHTTP(w, r)
-                        return
-                    }
+            for _, githubRepo := range githubRepos {
+                webhookSecret, err := utils.GetWebhookSecret(r.Context(), env.Client, env.Namespace, githubRepo.WebhookSecret)
+                if err != nil {
+                     /* another matching repository may have a webhook secret that validates the payload */
+                     klog.Errorf("found X-Hub-Signature but unable to get webhook secret %v. Error: %v", githubRepo.WebhookSecret, err)
+                     continue
+                }
+
+                err = utils.ValidatePayload(sigType, sig, webhookSecret, body)
+                // Found a secret that validates the payload
+                if err == nil {
+                    // XXX: Need to set a new body so the next handler can read the body too
+                    r.Body = ioutil.NopCloser(bytes.NewBuffer(body))
+                    nextHandler.ServeHTTP(w, r)
+                    return
                 }
             }
 
@@ -780,16 +1318,11 @@ func validateMessageHandler(mediatorKey string, nextHandler http.Handler) (http.
 }
 
 func generateMessageHandler(env *eventenv.EventEnv, key string) event.Handler {
-	return func(event *event.Event) error {
+	return func(ctx context.Context, event *event.Event) error {
         // last thing to do in event processing is to update status of the CRD
         defer env.StatusMgr.SendStatus(env.StatusUpdater)
 
-	    path := event.URL.Path
-        klog.Infof("In message handler: header: %v, body: %v, key: %v, url: %v, url path %v", event.Header, event.Body, key, event.URL, path)
-
-        if strings.HasPrefix(path, "/") {
-            path = path[1:]
-        }
+        klog.Infof("In message handler: header: %v, body: %v, key: %v, url: %v", event.Header, event.Body, key, event.URL)
 
         mediator := env.EventMgr.GetMediator(key)
         if mediator == nil {
@@ -797,29 +1330,171 @@ func generateMessageHandler(env *eventenv.EventEnv, key string) event.Handler {
             // not for us
             return nil
         }
+        _, err := ProcessEvent(ctx, env, mediator, event)
+        return err
+    }
+}
+
+/* Result of processing an event with one mediation */
+type MediationResult struct {
+    Mediation string // name of the mediation
+    Project string // directory of the project processed by the mediation, if it discovers projects
+    Processor *eventcel.Processor // processor of the mediation, or nil if matching the mediation failed
+    Error error // error matching or processing the mediation
+}
+
+/* Return the mediations of the mediator in the order they are matched: by decreasing priority, then in the order declared */
+func orderedMediations(mediator *eventsv1alpha1.EventMediator) []*eventsv1alpha1.EventMediationImpl {
+    ret := make([]*eventsv1alpha1.EventMediationImpl, 0)
+    if mediator.Spec.Mediations == nil {
+        return ret
+    }
+    for index := range *mediator.Spec.Mediations {
+        ret = append(ret, &(*mediator.Spec.Mediations)[index])
+    }
+    sort.SliceStable(ret, func(i, j int) bool {
+        return ret[i].Priority > ret[j].Priority
+    })
+    return ret
+}
+
+/* Return a deep copy of the header of an event */
+func copyHeader(header map[string][]string) map[string][]string {
+    ret := make(map[string][]string, len(header))
+    for key, values := range header {
+        ret[key] = append([]string(nil), values...)
+    }
+    return ret
+}
+
+/* Return a deep copy of a value decoded from JSON: the maps and lists are copied */
+func copyJSONValue(value interface{}) interface{} {
+    switch typed := value.(type) {
+    case map[string]interface{}:
+        ret := make(map[string]interface{}, len(typed))
+        for key, elem := range typed {
+            ret[key] = copyJSONValue(elem)
+        }
+        return ret
+    case []interface{}:
+        ret := make([]interface{}, len(typed))
+        for index, elem := range typed {
+            ret[index] = copyJSONValue(elem)
+        }
+        return ret
+    }
+    return value
+}
+
+/* Process an event with the mediations of the mediator that match it. Depending on the match policy of the mediator,
+   the event is processed by the first matching mediation, or by every matching mediation, each with its own processor.
+   Downloads, lookups, and deliveries made while processing the event are cancelled when ctx is done.
+   Return the result of each mediation that matched or failed to match, in the order processed, and the first error.
+*/
+func ProcessEvent(ctx context.Context, env *eventenv.EventEnv, mediator *eventsv1alpha1.EventMediator, event *event.Event) ([]MediationResult, error) {
+	    path := event.URL.Path
+        if strings.HasPrefix(path, "/") {
+            path = path[1:]
+        }
+
+        results := make([]MediationResult, 0)
         if mediator.Spec.Mediations == nil {
             klog.Info("No mediation within mediator")
-            return nil
+            return results, nil
         }
 
-        for _, mediationsImpl := range *mediator.Spec.Mediations {
-            eventMediationImpl := &mediationsImpl
-            err, matches, hasRepoType, repoTypeValue := mediationMatches(mediator, eventMediationImpl, event.Header , event.Body, path, env.Client, env.Namespace, event.RemoteAddr )
+        matchAll := false
+        switch mediator.Spec.MatchPolicy {
+        case "", MATCH_POLICY_FIRST:
+        case MATCH_POLICY_ALL:
+            matchAll = true
+        default:
+            err := fmt.Errorf("invalid matchPolicy %v for mediator %v. Valid values are %v and %v", mediator.Spec.MatchPolicy, mediator.Name, MATCH_POLICY_FIRST, MATCH_POLICY_ALL)
+            summary := &eventsv1alpha1.EventStatusSummary  {
+                 Operation: status.OPERATION_FIND_MEDIATION,
+                 Input: []eventsv1alpha1.EventStatusParameter { },
+                 Result: status.RESULT_FAILED,
+                 Message: err.Error(),
+            }
+            env.StatusMgr.AddEventSummary(summary)
+            return results, err
+        }
+
+        /* compiled when the mediator is loaded, unless the mediator was not loaded by the event manager */
+        urlPatterns := env.EventMgr.GetURLPatterns(mediator)
+        if urlPatterns == nil {
+            var err error
+            urlPatterns, err = utils.CompileURLPatterns(mediator)
+            if err != nil {
+                summary := &eventsv1alpha1.EventStatusSummary  {
+                     Operation: status.OPERATION_FIND_MEDIATION,
+                     Input: []eventsv1alpha1.EventStatusParameter { },
+                     Result: status.RESULT_FAILED,
+                     Message: err.Error(),
+                }
+                env.StatusMgr.AddEventSummary(summary)
+                return results, err
+            }
+        }
+
+        var firstErr error
+        /* shared by the mediations and projects of the event, which may report on the same commit */
+        reports := make(statusReports)
+        for _, eventMediationImpl := range orderedMediations(mediator) {
+            match, err := mediationMatches(ctx, mediator, eventMediationImpl, urlPatterns[eventMediationImpl.Name], event.Header , event.Body, path, env.Client, env.Namespace, event.RemoteAddr )
             if err != nil {
                 klog.Infof("Error from mediationMatches for %v, error: %v", eventMediationImpl.Name, err)
-                return err
+                results = append(results, MediationResult{ Mediation: eventMediationImpl.Name, Error: err })
+                if !matchAll {
+                    return results, err
+                }
+                if firstErr == nil {
+                    firstErr = err
+                }
+                continue
             }
-            if matches {
-                /* process the message */
-                klog.Infof("Processing mediation %v hasRepoType: %v, repoTypeValue: %v", path, hasRepoType, repoTypeValue)
-                processor := eventcel.NewProcessor(generateEventFunctionLookupHandler(mediator),generateSendEventHandler(env, mediator, eventMediationImpl.Name) )
-                err := processor.ProcessMessage(event.Header, event.Body, mediator, eventMediationImpl, hasRepoType, repoTypeValue, env.Namespace, env.Client, env.KabaneroIntegration, event.RemoteAddr)
-                if err != nil {
-                    klog.Errorf("Error processing mediation %v, error: %v", path, err)
+            if match.matches {
+                /* process the message, once per project when discovering projects */
+                projects := match.projects
+                if projects == nil {
+                    projects = []mediationProject{ { dir: "", repoTypeValue: match.repoTypeValue } }
+                }
+                var mediationErr error
+                for _, project := range projects {
+                    klog.Infof("Processing mediation %v for path %v hasRepoType: %v, repoTypeValue: %v, path parameters: %v, project: %v", eventMediationImpl.Name, path, match.hasRepoType, project.repoTypeValue, match.pathParams, project.dir)
+                    processor := eventcel.NewProcessor(generateEventFunctionLookupHandler(mediator),generateSendEventHandler(env, mediator, eventMediationImpl, reports) )
+                    /* each processor gets its own copy, as assignments modify the header and body */
+                    err := processor.ProcessMessage(ctx, copyHeader(event.Header), copyJSONValue(event.Body).(map[string]interface{}), mediator, eventMediationImpl, eventcel.ProcessOptions {
+                        HasRepoType: match.hasRepoType,
+                        RepoTypeValue: project.repoTypeValue,
+                        Namespace: env.Namespace,
+                        Client: env.Client,
+                        KabaneroIntegration: env.KabaneroIntegration,
+                        RemoteAddr: event.RemoteAddr,
+                        Command: match.command,
+                        ChangedFiles: match.changedFiles,
+                        ProjectDir: project.dir,
+                        PathParams: match.pathParams,
+                    })
+                    if err != nil {
+                        klog.Errorf("Error processing mediation %v for path %v, project %v, error: %v", eventMediationImpl.Name, path, project.dir, err)
+                        if firstErr == nil {
+                            firstErr = err
+                        }
+                        if mediationErr == nil {
+                            mediationErr = err
+                        }
+                    }
+                    results = append(results, MediationResult{ Mediation: eventMediationImpl.Name, Project: project.dir, Processor: processor, Error: err })
+                }
+                if !matchAll {
+                    return results, mediationErr
                 }
-                return err
             }
         }
+        if len(results) > 0 {
+            return results, firstErr
+        }
 
         summary := &eventsv1alpha1.EventStatusSummary  {
              Operation: status.OPERATION_FIND_MEDIATION,
@@ -833,8 +1508,7 @@ func generateMessageHandler(env *eventenv.EventEnv, key string) event.Handler {
         }
         eventenv.GetEventEnv().StatusMgr.AddEventSummary(summary)
         klog.Info("No matching mediation")
-        return nil
-    }
+        return results, nil
 }
 
 
@@ -858,8 +1532,16 @@ func  generateEventFunctionLookupHandler (mediator *eventsv1alpha1.EventMediator
     }
 }
 
-func generateSendEventHandler(env *eventenv.EventEnv, mediator *eventsv1alpha1.EventMediator, mediationName string) func(processor *eventcel.Processor, dest string, buf []byte, header map[string][]string) error {
+func generateSendEventHandler(env *eventenv.EventEnv, mediator *eventsv1alpha1.EventMediator, mediation *eventsv1alpha1.EventMediationImpl, reports statusReports) func(processor *eventcel.Processor, dest string, buf []byte, header map[string][]string) error {
 
+    mediationName := mediation.Name
+    /* Only a sendEvent within a try fails, so that onError can react to it. Otherwise the failure is recorded in the status, and the mediation continues */
+    failed := func(processor *eventcel.Processor, err error) error {
+        if processor.InTry() {
+            return err
+        }
+        return nil
+    }
     return func(processor *eventcel.Processor, destination string, buf[]byte, header map[string][]string) error {
         connectionsMgr  := env.ConnectionsMgr
         endpoint := &eventsv1alpha1.EventSourceEndpoint {
@@ -884,7 +1566,11 @@ func generateSendEventHandler(env *eventenv.EventEnv, mediator *eventsv1alpha1.E
              }
              eventenv.GetEventEnv().StatusMgr.AddEventSummary(summary)
              klog.Errorf("No destination for meidation %v, destination %v", mediationName, destination)
+             reportStatus(env, processor, reports, mediator, mediation, utils.GITHUB_STATE_ERROR, fmt.Sprintf("No listener: destination %v not found in any EventConnection", destination))
+             return failed(processor, fmt.Errorf("destination %v not found in any EventConnection", destination))
          }
+         /* the last delivery error, if any */
+         var sendErr error
          for _, dest := range destinations {
              /* TODO: add configurable timeout */
              if dest.Https != nil {
@@ -909,12 +1595,33 @@ func generateSendEventHandler(env *eventenv.EventEnv, mediator *eventsv1alpha1.E
                                   Message: fmt.Sprintf("Unable to evaluate urlExpression %v, error: %v", *https.UrlExpression, err),
                              }
                              eventenv.GetEventEnv().StatusMgr.AddEventSummary(summary)
+                             sendErr = err
                              continue
                          }
                      }
 
+                     if processor.IsDryRun() {
+                         klog.Infof("generateSendEventHandler: dry run, not sending message to %v", url)
+                         summary := &eventsv1alpha1.EventStatusSummary  {
+                              Operation: status.OPERATION_SEND_EVENT,
+                              Input: append(tempEventParams, eventsv1alpha1.EventStatusParameter { Name: status.PARAM_DESTINATION, Value: destination}),
+                              Result: status.RESULT_DRY_RUN,
+                              Message: fmt.Sprintf("Dry run: event not sent to %v", url),
+                         }
+                         eventenv.GetEventEnv().StatusMgr.AddEventSummary(summary)
+                         eventenv.GetEventEnv().DebugMgr.AddDryRun(&debug.DryRunRecord {
+                              Mediator: mediator.ObjectMeta.Name,
+                              Mediation: mediationName,
+                              Destination: destination,
+                              URL: url,
+                              Header: header,
+                              Payload: json.RawMessage(buf),
+                         })
+                         continue
+                     }
+
                      klog.Infof("generateSendEventHandler: sending message to %v", url)
-                     err = sendMessage(url, https.Insecure, timeout,  buf, header)
+                     err = sendMessage(processor.Context(), url, https.Insecure, timeout,  buf, header)
                      if err != nil  {
                         summary := &eventsv1alpha1.EventStatusSummary  {
                               Operation: status.OPERATION_SEND_EVENT,
@@ -924,17 +1631,110 @@ func generateSendEventHandler(env *eventenv.EventEnv, mediator *eventsv1alpha1.E
                          }
                          eventenv.GetEventEnv().StatusMgr.AddEventSummary(summary)
                          klog.Errorf("generateSendEventHandler: error sending message: %v", err)
+                         sendErr = err
                          continue
                      }
                 }
              }
          }
-         return nil
+         if sendErr != nil {
+             reportStatus(env, processor, reports, mediator, mediation, utils.GITHUB_STATE_ERROR, fmt.Sprintf("Unable to send event to %v: %v", destination, sendErr))
+         } else {
+             reportStatus(env, processor, reports, mediator, mediation, utils.GITHUB_STATE_PENDING, fmt.Sprintf("Event sent to %v", destination))
+             return nil
+         }
+         return failed(processor, sendErr)
+    }
+}
+
+/* The states of the commit statuses and check runs reported for an event, by type, context, and commit */
+type statusReports map[string]string
+
+/* Report the delivery of an event as a commit status or check run on the head commit of the GitHub event, if the mediation has reportStatus.
+   An event sent without a listener for the stack of the repository is reported as an error, and a pending state does not replace
+   an error already reported for the event on the same commit and context.
+   Failures to report are recorded in the status, but do not fail the mediation.
+*/
+func reportStatus(env *eventenv.EventEnv, processor *eventcel.Processor, reports statusReports, mediator *eventsv1alpha1.EventMediator, mediation *eventsv1alpha1.EventMediationImpl, state string, description string) {
+    settings := mediation.ReportStatus
+    if settings == nil {
+        return
+    }
+    header, body := processor.GetMessage()
+    if !utils.IsHeaderGithub(header) {
+        return
+    }
+    /* the head commit of a pull request or check, the commit after a push, or the commit of a tag */
+    sha, _ := body["webhooks-tekton-head-sha"].(string)
+    if sha == "" {
+        sha, _ = body["webhooks-tekton-sha"].(string)
+    }
+    if sha == "" {
+        klog.Infof("mediation %v: status %v not reported, the event has no head commit", mediation.Name, state)
+        return
+    }
+    branch, _ := body["webhooks-tekton-git-branch"].(string)
+    if listener, _ := body["webhooks-kabanero-tekton-listener"].(string); state == utils.GITHUB_STATE_PENDING && listener == eventcel.UNKNOWN_LISTENER {
+        state = utils.GITHUB_STATE_ERROR
+        description = "No event listener found for the stack of the repository. " + description
+    }
+
+    report := &utils.GithubStatusReport{
+        Type: settings.Type,
+        SHA: sha,
+        Branch: branch,
+        State: state,
+        Context: settings.Context,
+        Description: description,
+        TargetURL: settings.TargetURL,
+    }
+    if report.Type == "" {
+        report.Type = utils.REPORT_STATUS_TYPE_STATUS
+    }
+    if report.Context == "" {
+        report.Context = mediator.Name + "/" + mediation.Name
+    }
+
+    params := append(processor.GetStatusParameters(), eventsv1alpha1.EventStatusParameter { Name: status.PARAM_COMMIT, Value: sha })
+    addSummary := func(result string, message string) {
+        summary := &eventsv1alpha1.EventStatusSummary  {
+             Operation: status.OPERATION_REPORT_STATUS,
+             Input: params,
+             Result: result,
+             Message: message,
+        }
+        env.StatusMgr.AddEventSummary(summary)
+    }
+    if report.Type != utils.REPORT_STATUS_TYPE_STATUS && report.Type != utils.REPORT_STATUS_TYPE_CHECK_RUN {
+        addSummary(status.RESULT_FAILED, fmt.Sprintf("Invalid reportStatus type %v. Use %v or %v", report.Type, utils.REPORT_STATUS_TYPE_STATUS, utils.REPORT_STATUS_TYPE_CHECK_RUN))
+        return
+    }
+    key := report.Type + "/" + report.Context + "@" + sha
+    if state == utils.GITHUB_STATE_PENDING && reports[key] == utils.GITHUB_STATE_ERROR {
+        klog.Infof("mediation %v: %v %v pending not reported on commit %v, which has an error", mediation.Name, report.Type, report.Context, sha)
+        return
+    }
+    if processor.IsDryRun() {
+        addSummary(status.RESULT_DRY_RUN, fmt.Sprintf("Dry run: %v %v %v not reported: %v", report.Type, report.Context, state, description))
+        reports[key] = state
+        return
+    }
+
+    githubRepo, err := matchGithubRepository(mediator, mediation, body)
+    if err == nil {
+        err = env.ReportStatus(processor.Context(), env.Client, env.Namespace, githubRepo, header, body, report)
+    }
+    if err != nil {
+        klog.Errorf("mediation %v: unable to report status: %v", mediation.Name, err)
+        addSummary(status.RESULT_FAILED, fmt.Sprintf("Unable to report %v %v %v: %v", report.Type, report.Context, state, err))
+        return
     }
+    reports[key] = state
+    addSummary(status.RESULT_COMPLETED, fmt.Sprintf("Reported %v %v %v: %v", report.Type, report.Context, state, description))
 }
 
-func sendMessage(url string, insecure bool, timeout time.Duration, payload []byte, header map[string][]string) error {
-   req, err := http.NewRequest("POST", url, bytes.NewBuffer(payload))
+func sendMessage(ctx context.Context, url string, insecure bool, timeout time.Duration, payload []byte, header map[string][]string) error {
+   req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(payload))
     if err != nil {
         return err
     }
@@ -0,0 +1,262 @@
+/*
+Copyright 2020 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package debug
+
+import (
+	"container/list"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"k8s.io/klog"
+)
+
+const (
+	DEFAULT_DEBUG_HOST       = "127.0.0.1" // the debug endpoint is only reachable from within the pod, such as through kubectl port-forward
+	DEFAULT_DEBUG_PORT int32 = 9090        // port of the debug endpoint of a mediator worker
+	MAX_RETAINED_RECORDS     = 100  // maximum number of records of each type to retain
+
+	DRY_RUNS_PATH = "/debug/dryruns"
+	TRACES_PATH   = "/debug/traces"
+	STATS_PATH    = "/debug/stats"
+
+	TRACE_HEADER = "X-Events-Trace" // request header to trace the processing of an event
+
+	REDACTED = "<redacted>" // value of sensitive headers in debugging records
+)
+
+/* Headers with credentials or signatures, not retained in debugging records. Canonical keys */
+var sensitiveHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	"Set-Cookie":          true,
+	"X-Hub-Signature":     true,
+	"X-Hub-Signature-256": true,
+	"X-Gitlab-Token":      true,
+}
+
+func isSensitiveHeader(name string) bool {
+	return sensitiveHeaders[http.CanonicalHeaderKey(name)]
+}
+
+/* RedactHeader returns a copy of header with the values of sensitive headers replaced */
+func RedactHeader(header map[string][]string) map[string][]string {
+	if header == nil {
+		return nil
+	}
+	ret := make(map[string][]string, len(header))
+	for name, values := range header {
+		if isSensitiveHeader(name) {
+			values = []string{REDACTED}
+		}
+		ret[name] = values
+	}
+	return ret
+}
+
+/* Return a copy of a value of a variable with the values of sensitive headers replaced, at any level of maps and lists */
+func redactValue(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string][]string:
+		return RedactHeader(value)
+	case map[string]interface{}:
+		ret := make(map[string]interface{}, len(value))
+		for key, elem := range value {
+			if isSensitiveHeader(key) {
+				ret[key] = REDACTED
+			} else {
+				ret[key] = redactValue(elem)
+			}
+		}
+		return ret
+	case []interface{}:
+		ret := make([]interface{}, len(value))
+		for index, elem := range value {
+			ret[index] = redactValue(elem)
+		}
+		return ret
+	default:
+		return value
+	}
+}
+
+/* An event that was not sent because of dry-run mode */
+type DryRunRecord struct {
+	Time        time.Time           `json:"time"`
+	Mediator    string              `json:"mediator"`
+	Mediation   string              `json:"mediation"`
+	Destination string              `json:"destination"`
+	URL         string              `json:"url"`
+	Header      map[string][]string `json:"header,omitempty"`
+	Payload     json.RawMessage     `json:"payload,omitempty"`
+}
+
+/* One step in the evaluation of a mediation */
+type TraceStep struct {
+	Depth       int         `json:"depth"`
+	Statement   string      `json:"statement"` // =, if, switch, default, body, try, onError, fail, or sendEvent
+	Expression  string      `json:"expression,omitempty"`
+	Result      *bool       `json:"result,omitempty"` // result of the condition of an if
+	Variable    string      `json:"variable,omitempty"`
+	OldValue    interface{} `json:"oldValue,omitempty"`
+	NewValue    interface{} `json:"newValue,omitempty"`
+	Destination string      `json:"destination,omitempty"`
+	Error       string      `json:"error,omitempty"`
+}
+
+/* The trace of the evaluation of a mediation for one event */
+type Trace struct {
+	Time       time.Time   `json:"time"`
+	Mediator   string      `json:"mediator"`
+	Mediation  string      `json:"mediation"`
+	DeliveryID string      `json:"deliveryID,omitempty"` // delivery ID of the webhook event, if any
+	Steps      []TraceStep `json:"steps"`
+	Error      string      `json:"error,omitempty"`
+}
+
+/* Add a step to the trace */
+func (trace *Trace) AddStep(step TraceStep) {
+	trace.Steps = append(trace.Steps, step)
+}
+
+/* DebugManager retains recent debugging records, and serves them over HTTP */
+type DebugManager struct {
+	dryRuns *list.List
+	traces  *list.List
+	stats   map[string]func() interface{} // functions returning statistics, by name
+	mutex   sync.Mutex
+}
+
+func NewDebugManager() *DebugManager {
+	return &DebugManager{
+		dryRuns: list.New(),
+		traces:  list.New(),
+		stats:   make(map[string]func() interface{}),
+	}
+}
+
+/* Append a record to the list, dropping the oldest if there are too many */
+func addRecord(records *list.List, record interface{}) {
+	records.PushBack(record)
+	for records.Len() > MAX_RETAINED_RECORDS {
+		records.Remove(records.Front())
+	}
+}
+
+/* Add a dry run record, dropping the oldest if there are too many. Sensitive headers are redacted */
+func (debugMgr *DebugManager) AddDryRun(record *DryRunRecord) {
+	debugMgr.mutex.Lock()
+	defer debugMgr.mutex.Unlock()
+
+	if record.Time.IsZero() {
+		record.Time = time.Now().UTC()
+	}
+	record.Header = RedactHeader(record.Header)
+	addRecord(debugMgr.dryRuns, record)
+}
+
+/* Return the dry run records, newest first */
+func (debugMgr *DebugManager) GetDryRuns() []DryRunRecord {
+	debugMgr.mutex.Lock()
+	defer debugMgr.mutex.Unlock()
+
+	ret := make([]DryRunRecord, 0, debugMgr.dryRuns.Len())
+	for elem := debugMgr.dryRuns.Back(); elem != nil; elem = elem.Prev() {
+		ret = append(ret, *elem.Value.(*DryRunRecord))
+	}
+	return ret
+}
+
+/* Add the trace of a processed event, dropping the oldest if there are too many. Sensitive headers in the values of variables are redacted */
+func (debugMgr *DebugManager) AddTrace(trace *Trace) {
+	debugMgr.mutex.Lock()
+	defer debugMgr.mutex.Unlock()
+
+	if trace.Time.IsZero() {
+		trace.Time = time.Now().UTC()
+	}
+	for index := range trace.Steps {
+		step := &trace.Steps[index]
+		step.OldValue = redactValue(step.OldValue)
+		step.NewValue = redactValue(step.NewValue)
+	}
+	addRecord(debugMgr.traces, trace)
+}
+
+/* Return the traces, newest first */
+func (debugMgr *DebugManager) GetTraces() []Trace {
+	debugMgr.mutex.Lock()
+	defer debugMgr.mutex.Unlock()
+
+	ret := make([]Trace, 0, debugMgr.traces.Len())
+	for elem := debugMgr.traces.Back(); elem != nil; elem = elem.Prev() {
+		ret = append(ret, *elem.Value.(*Trace))
+	}
+	return ret
+}
+
+/* Add a function returning statistics to be served under name */
+func (debugMgr *DebugManager) AddStats(name string, stats func() interface{}) {
+	debugMgr.mutex.Lock()
+	defer debugMgr.mutex.Unlock()
+
+	debugMgr.stats[name] = stats
+}
+
+/* Return the current statistics, by name */
+func (debugMgr *DebugManager) GetStats() map[string]interface{} {
+	debugMgr.mutex.Lock()
+	statsFuncs := make(map[string]func() interface{}, len(debugMgr.stats))
+	for name, stats := range debugMgr.stats {
+		statsFuncs[name] = stats
+	}
+	debugMgr.mutex.Unlock()
+
+	ret := make(map[string]interface{}, len(statsFuncs))
+	for name, stats := range statsFuncs {
+		ret[name] = stats()
+	}
+	return ret
+}
+
+func writeJSON(writer http.ResponseWriter, value interface{}) {
+	buf, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		klog.Errorf("Unable to marshal debug information: %v", err)
+		http.Error(writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writer.Header().Set("Content-Type", "application/json")
+	writer.Write(buf)
+}
+
+/* Return the handler for the debug endpoint */
+func (debugMgr *DebugManager) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(DRY_RUNS_PATH, func(writer http.ResponseWriter, req *http.Request) {
+		writeJSON(writer, debugMgr.GetDryRuns())
+	})
+	mux.HandleFunc(TRACES_PATH, func(writer http.ResponseWriter, req *http.Request) {
+		writeJSON(writer, debugMgr.GetTraces())
+	})
+	mux.HandleFunc(STATS_PATH, func(writer http.ResponseWriter, req *http.Request) {
+		writeJSON(writer, debugMgr.GetStats())
+	})
+	return mux
+}
@@ -0,0 +1,123 @@
+package debug_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kabanero-io/events-operator/pkg/debug"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestDebug(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Debug Suite")
+}
+
+var _ = Describe("TestDebugManager", func() {
+	It("should retain the most recent dry runs, newest first", func() {
+		debugMgr := debug.NewDebugManager()
+		for i := 0; i < debug.MAX_RETAINED_RECORDS+5; i++ {
+			debugMgr.AddDryRun(&debug.DryRunRecord{Destination: fmt.Sprintf("dest%d", i)})
+		}
+		dryRuns := debugMgr.GetDryRuns()
+		Expect(dryRuns).To(HaveLen(debug.MAX_RETAINED_RECORDS))
+		Expect(dryRuns[0].Destination).To(Equal(fmt.Sprintf("dest%d", debug.MAX_RETAINED_RECORDS+4)))
+		Expect(dryRuns[len(dryRuns)-1].Destination).To(Equal("dest5"))
+		Expect(dryRuns[0].Time.IsZero()).To(BeFalse())
+	})
+
+	It("should serve dry runs as JSON", func() {
+		debugMgr := debug.NewDebugManager()
+		debugMgr.AddDryRun(&debug.DryRunRecord{
+			Mediator:    "webhook",
+			Mediation:   "webhook",
+			Destination: "dest",
+			URL:         "https://listener",
+			Header:      map[string][]string{"X-Github-Event": {"push"}},
+			Payload:     json.RawMessage(`{"ref":"refs/heads/master"}`),
+		})
+		server := httptest.NewServer(debugMgr.Handler())
+		defer server.Close()
+
+		resp, err := http.Get(server.URL + debug.DRY_RUNS_PATH)
+		Expect(err).ToNot(HaveOccurred())
+		defer resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+		var dryRuns []map[string]interface{}
+		Expect(json.NewDecoder(resp.Body).Decode(&dryRuns)).To(Succeed())
+		Expect(dryRuns).To(HaveLen(1))
+		Expect(dryRuns[0]["url"]).To(Equal("https://listener"))
+		Expect(dryRuns[0]["payload"]).To(HaveKeyWithValue("ref", "refs/heads/master"))
+	})
+
+	It("should serve traces as JSON", func() {
+		debugMgr := debug.NewDebugManager()
+		result := true
+		trace := &debug.Trace{Mediator: "webhook", Mediation: "webhook"}
+		trace.AddStep(debug.TraceStep{Depth: 1, Statement: "if", Expression: "has(body.ref)", Result: &result})
+		trace.AddStep(debug.TraceStep{Depth: 2, Statement: "=", Expression: "branch = body.ref", Variable: "branch", NewValue: "refs/heads/master"})
+		debugMgr.AddTrace(trace)
+		server := httptest.NewServer(debugMgr.Handler())
+		defer server.Close()
+
+		resp, err := http.Get(server.URL + debug.TRACES_PATH)
+		Expect(err).ToNot(HaveOccurred())
+		defer resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+		var traces []debug.Trace
+		Expect(json.NewDecoder(resp.Body).Decode(&traces)).To(Succeed())
+		Expect(traces).To(HaveLen(1))
+		Expect(traces[0].Steps).To(HaveLen(2))
+		Expect(*traces[0].Steps[0].Result).To(BeTrue())
+		Expect(traces[0].Steps[1].NewValue).To(Equal("refs/heads/master"))
+	})
+
+	It("should serve statistics as JSON", func() {
+		debugMgr := debug.NewDebugManager()
+		hits := 0
+		debugMgr.AddStats("cache", func() interface{} {
+			hits++
+			return map[string]int{"hits": hits}
+		})
+		server := httptest.NewServer(debugMgr.Handler())
+		defer server.Close()
+
+		resp, err := http.Get(server.URL + debug.STATS_PATH)
+		Expect(err).ToNot(HaveOccurred())
+		defer resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+		var stats map[string]map[string]int
+		Expect(json.NewDecoder(resp.Body).Decode(&stats)).To(Succeed())
+		Expect(stats).To(HaveKeyWithValue("cache", map[string]int{"hits": 1}))
+	})
+
+	It("should redact sensitive headers", func() {
+		debugMgr := debug.NewDebugManager()
+		debugMgr.AddDryRun(&debug.DryRunRecord{
+			Destination: "dest",
+			Header:      map[string][]string{"X-Github-Event": {"push"}, "X-Hub-Signature": {"sha1=1234"}, "authorization": {"token secret"}},
+		})
+		dryRuns := debugMgr.GetDryRuns()
+		Expect(dryRuns[0].Header).To(Equal(map[string][]string{"X-Github-Event": {"push"}, "X-Hub-Signature": {debug.REDACTED}, "authorization": {debug.REDACTED}}))
+
+		trace := &debug.Trace{Mediator: "webhook", Mediation: "webhook"}
+		trace.AddStep(debug.TraceStep{Depth: 1, Statement: "=", Variable: "newHeader", NewValue: map[string]interface{}{
+			"X-Github-Event":      []interface{}{"push"},
+			"X-Hub-Signature-256": []interface{}{"sha256=1234"},
+			"nested":              map[string]interface{}{"Authorization": "token secret"},
+		}})
+		debugMgr.AddTrace(trace)
+		Expect(debugMgr.GetTraces()[0].Steps[0].NewValue).To(Equal(map[string]interface{}{
+			"X-Github-Event":      []interface{}{"push"},
+			"X-Hub-Signature-256": debug.REDACTED,
+			"nested":              map[string]interface{}{"Authorization": debug.REDACTED},
+		}))
+	})
+})
@@ -1,6 +1,7 @@
 package event
 
 import (
+	"context"
 	"io/ioutil"
 	"k8s.io/klog"
 	"net/http"
@@ -14,6 +15,8 @@ const (
 	MessageHeader = "header"
 	// MessageBody is the message key containing the request's payload
 	MessageBody = "body"
+	// DeliveryHeader is the header containing the unique ID of a GitHub webhook delivery
+	DeliveryHeader = "X-Github-Delivery"
 )
 
 // Event contains the destination URL, headers, and a body
@@ -22,6 +25,40 @@ type Event struct {
     RemoteAddr string
 	Header map[string][]string
 	Body   map[string]interface{}
+
+	/* The context of the request that created the event. Unlike the context of the http.Request, it
+	   is not cancelled when the response is sent, as events are processed after the response. */
+	ctx context.Context
+}
+
+// Context returns the context of the event, or the background context if none was set
+func (event *Event) Context() context.Context {
+	if event.ctx != nil {
+		return event.ctx
+	}
+	return context.Background()
+}
+
+// WithContext returns a shallow copy of the event with its context changed to ctx
+func (event *Event) WithContext(ctx context.Context) *Event {
+	copy := *event
+	copy.ctx = ctx
+	return &copy
+}
+
+type contextKey int
+
+const deliveryIDKey contextKey = iota
+
+// WithDeliveryID returns a context carrying the ID of the delivery being processed
+func WithDeliveryID(ctx context.Context, deliveryID string) context.Context {
+	return context.WithValue(ctx, deliveryIDKey, deliveryID)
+}
+
+// DeliveryID returns the ID of the delivery being processed, or "" if not known
+func DeliveryID(ctx context.Context) string {
+	deliveryID, _ := ctx.Value(deliveryIDKey).(string)
+	return deliveryID
 }
 
 // Types of events
@@ -33,10 +70,12 @@ const (
 )
 
 // A handler that responds to an event
-type Handler func(event *Event) error
+type Handler func(ctx context.Context, event *Event) error
 
-/* Event listener listens for REST requests and enqueues a message consisting of the request's headers and payloads. */
-func EnqueueHandler(queue Queue) http.HandlerFunc {
+/* Event listener listens for REST requests and enqueues a message consisting of the request's headers and payloads.
+   The context of each event is derived from ctx, and carries the delivery ID of the request.
+*/
+func EnqueueHandler(ctx context.Context, queue Queue) http.HandlerFunc {
 	return func(writer http.ResponseWriter, r *http.Request) {
 		klog.Infof("Received request. Header: %v", r.Header)
 
@@ -66,24 +105,39 @@ func EnqueueHandler(queue Queue) http.HandlerFunc {
             RemoteAddr: r.RemoteAddr,
 			Header: r.Header,
 			Body:   bodyMap,
+			ctx:    WithDeliveryID(ctx, r.Header.Get(DeliveryHeader)),
 		})
 
 		writer.WriteHeader(http.StatusOK)
 	}
 }
 
-/* ProcessQueueWorker processes events on the Queue */
-func ProcessQueueWorker(queue Queue, handler Handler) {
+/* ProcessQueueWorker processes events on the Queue until ctx is cancelled */
+func ProcessQueueWorker(ctx context.Context, queue Queue, handler Handler) {
 	klog.Info("Worker thread started to process messages.")
+	go func() {
+		/* wake up the worker */
+		<-ctx.Done()
+		queue.Enqueue(nil)
+	}()
 	for {
-		event := queue.Dequeue().(*Event)
+		event, ok := queue.Dequeue().(*Event)
+		if ctx.Err() != nil {
+			klog.Infof("Worker thread stopped: %v", ctx.Err())
+			return
+		}
+		if !ok || event == nil {
+			continue
+		}
+		eventCtx := event.Context()
+		deliveryID := DeliveryID(eventCtx)
 		// TODO: Remove this later or only include when very verbose logging is enabled
-		klog.Infof("Worker thread processing url: %s, header: %v, body: %v", event.URL, event.Header, event.Body)
-		err := handler(event)
+		klog.Infof("Worker thread processing url: %s, delivery: %v, header: %v, body: %v", event.URL, deliveryID, event.Header, event.Body)
+		err := handler(eventCtx, event)
 		if err != nil {
-			klog.Errorf("Worker thread error: url: %s, error: %v", event.URL, err)
+			klog.Errorf("Worker thread error: url: %s, delivery: %v, error: %v", event.URL, deliveryID, err)
 			continue
 		}
-		klog.Infof("Worker thread completed processing url: %s", event.URL)
+		klog.Infof("Worker thread completed processing url: %s, delivery: %v", event.URL, deliveryID)
 	}
 }
@@ -17,6 +17,7 @@ limitations under the License.
 package event_test
 
 import (
+	"context"
 	"github.com/kabanero-io/events-operator/pkg/event"
 	"net/http"
 	"net/http/httptest"
@@ -35,7 +36,7 @@ func TestEvent(t *testing.T) {
 var _ = Describe("TestEvent", func() {
 	Context("TestEnqueueHandler", func() {
 		queue := event.NewQueue()
-		handler := event.EnqueueHandler(queue)
+		handler := event.EnqueueHandler(context.Background(), queue)
 
 		It("should receive an OK status for a request with no body", func() {
 			req, err := http.NewRequest("GET", "https://localhost/test-url", nil)
@@ -64,7 +65,42 @@ var _ = Describe("TestEvent", func() {
 		})
 
 	})
-})
 
+	Context("TestContext", func() {
+		It("should carry the delivery ID of the request in the context of the event", func() {
+			queue := event.NewQueue()
+			handler := event.EnqueueHandler(context.Background(), queue)
+			req, err := http.NewRequest("POST", "https://localhost/test-url", strings.NewReader(`{}`))
+			Expect(err).Should(BeNil())
+			req.Header.Set(event.DeliveryHeader, "72d3162e-cc78-11e3-81ab-4c9367dc0958")
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+			Expect(rec.Result().StatusCode).Should(Equal(http.StatusOK))
 
+			evt, ok := queue.Dequeue().(*event.Event)
+			Expect(ok).Should(BeTrue())
+			Expect(event.DeliveryID(evt.Context())).Should(Equal("72d3162e-cc78-11e3-81ab-4c9367dc0958"))
+		})
 
+		It("should process events with their context, and stop when cancelled", func() {
+			queue := event.NewQueue()
+			ctx, cancel := context.WithCancel(context.Background())
+			deliveries := make(chan string, 1)
+			done := make(chan struct{})
+			go func() {
+				event.ProcessQueueWorker(ctx, queue, func(eventCtx context.Context, evt *event.Event) error {
+					deliveries <- event.DeliveryID(eventCtx)
+					return nil
+				})
+				close(done)
+			}()
+
+			evt := &event.Event{Header: map[string][]string{}, Body: map[string]interface{}{}}
+			queue.Enqueue(evt.WithContext(event.WithDeliveryID(ctx, "1")))
+			Eventually(deliveries).Should(Receive(Equal("1")))
+
+			cancel()
+			Eventually(done).Should(BeClosed())
+		})
+	})
+})
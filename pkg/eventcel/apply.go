@@ -0,0 +1,211 @@
+/*
+Copyright 2020 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package eventcel
+
+/* Implementation of applyResources, which server-side applies resources created by a mediation */
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	eventsv1alpha1 "github.com/kabanero-io/events-operator/pkg/apis/events/v1alpha1"
+	"github.com/kabanero-io/events-operator/pkg/eventenv"
+	"github.com/kabanero-io/events-operator/pkg/status"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	k8syaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	LABEL_MEDIATOR  = "events.kabanero.io/mediator"
+	LABEL_MEDIATION = "events.kabanero.io/mediation"
+
+	GC_POLICY_NONE     = "none"
+	GC_POLICY_MEDIATOR = "mediator"
+
+	FIELD_MANAGER = "events-operator"
+)
+
+/* Convert the first parameter of applyResources to objects. It may be a string containing one or more
+   YAML or JSON documents, a map, or a list of maps.
+*/
+func toUnstructuredObjects(value interface{}) ([]*unstructured.Unstructured, error) {
+	ret := make([]*unstructured.Unstructured, 0)
+	switch value.(type) {
+	case string:
+		decoder := k8syaml.NewYAMLOrJSONDecoder(bytes.NewBufferString(value.(string)), 4096)
+		for {
+			obj := make(map[string]interface{})
+			err := decoder.Decode(&obj)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("unable to parse resource: %v", err)
+			}
+			if len(obj) == 0 {
+				/* empty document */
+				continue
+			}
+			ret = append(ret, &unstructured.Unstructured{Object: obj})
+		}
+	case map[string]interface{}:
+		ret = append(ret, &unstructured.Unstructured{Object: value.(map[string]interface{})})
+	case []interface{}:
+		for _, elem := range value.([]interface{}) {
+			objs, err := toUnstructuredObjects(elem)
+			if err != nil {
+				return nil, err
+			}
+			ret = append(ret, objs...)
+		}
+	default:
+		return nil, fmt.Errorf("resource of type %T is not a string, map, or list", value)
+	}
+	return ret, nil
+}
+
+/* Set the namespace, name, labels, and owner of an object to be applied. An object with only a generateName is named
+   after the job ID and the number of names generated for the job so far, so that objects of a job do not overwrite each other
+*/
+func prepareObject(obj *unstructured.Unstructured, namespace string, jobID string, generated int, mediator *eventsv1alpha1.EventMediator, mediationName string) error {
+	if obj.GetAPIVersion() == "" || obj.GetKind() == "" {
+		return fmt.Errorf("resource has no apiVersion or kind: %v", obj.Object)
+	}
+	if namespace != "" {
+		obj.SetNamespace(namespace)
+	} else if obj.GetNamespace() == "" {
+		obj.SetNamespace(mediator.Namespace)
+	}
+	if obj.GetName() == "" {
+		/* server-side apply requires a name */
+		if obj.GetGenerateName() == "" {
+			return fmt.Errorf("resource %v has neither name nor generateName", obj.GetKind())
+		}
+		obj.SetName(fmt.Sprintf("%v%v-%v", obj.GetGenerateName(), jobID, generated))
+	}
+
+	if _, exists := obj.Object[METADATA]; !exists {
+		obj.Object[METADATA] = make(map[string]interface{})
+	}
+	err := setJobID(obj, jobID)
+	if err != nil {
+		return err
+	}
+	labels := obj.GetLabels()
+	labels[LABEL_MEDIATOR] = mediator.Name
+	labels[LABEL_MEDIATION] = mediationName
+	obj.SetLabels(labels)
+
+	switch mediator.Spec.GarbageCollectionPolicy {
+	case "", GC_POLICY_NONE:
+	case GC_POLICY_MEDIATOR:
+		/* owner references may not cross namespaces */
+		if obj.GetNamespace() != mediator.Namespace {
+			return fmt.Errorf("resource %v/%v is not in the namespace of the mediator, and can not be garbage collected with the mediator", obj.GetNamespace(), obj.GetName())
+		}
+		isController := false
+		obj.SetOwnerReferences([]metav1.OwnerReference{{
+			APIVersion: eventsv1alpha1.SchemeGroupVersion.String(),
+			Kind:       "EventMediator",
+			Name:       mediator.Name,
+			UID:        mediator.UID,
+			Controller: &isController,
+		}})
+	default:
+		return fmt.Errorf("unsupported garbageCollectionPolicy %v", mediator.Spec.GarbageCollectionPolicy)
+	}
+	return nil
+}
+
+/* Apply resources, recording one status summary per resource.
+   Return the kind/namespace/name of each applied resource
+*/
+func (p *Processor) applyResources(value interface{}, namespace string) ([]string, error) {
+	if p.mediator == nil || p.client == nil {
+		return nil, fmt.Errorf("applyResources is not available")
+	}
+	objs, err := toUnstructuredObjects(value)
+	if err != nil {
+		return nil, err
+	}
+	if p.jobID == "" {
+		p.jobID = GetTimestamp()
+	}
+
+	applied := make([]string, 0, len(objs))
+	for _, obj := range objs {
+		err = prepareObject(obj, namespace, p.jobID, p.generatedNames, p.mediator, p.mediationName)
+		if obj.GetGenerateName() != "" {
+			p.generatedNames++
+		}
+		resource := obj.GetKind() + "/" + obj.GetNamespace() + "/" + obj.GetName()
+		if err == nil {
+			klog.Infof("applyResources: applying %v, dry run: %v", resource, p.dryRun)
+			opts := []client.PatchOption{client.FieldOwner(FIELD_MANAGER), client.ForceOwnership}
+			if p.dryRun {
+				/* the API server validates the resource without persisting it */
+				opts = append(opts, client.DryRunAll)
+			}
+			err = p.client.Patch(p.Context(), obj, client.Apply, opts...)
+		}
+
+		params := append(p.statusParams.GetStatusParameters(), eventsv1alpha1.EventStatusParameter{Name: status.PARAM_RESOURCE, Value: resource})
+		summary := &eventsv1alpha1.EventStatusSummary{
+			Operation: status.OPERATION_APPLY_RESOURCE,
+			Input:     params,
+			Result:    status.RESULT_COMPLETED,
+		}
+		if err != nil {
+			summary.Result = status.RESULT_FAILED
+			summary.Message = fmt.Sprintf("Unable to apply %v: %v", resource, err)
+		} else if p.dryRun {
+			summary.Result = status.RESULT_DRY_RUN
+		}
+		eventenv.GetEventEnv().StatusMgr.AddEventSummary(summary)
+		if err != nil {
+			klog.Errorf("applyResources: unable to apply %v: %v", resource, err)
+			return applied, err
+		}
+		applied = append(applied, resource)
+	}
+	return applied, nil
+}
+
+/* implementation of applyResources(templateOrObject, namespace) for CEL.
+   Return the list of applied resources, each of the form kind/namespace/name
+*/
+func (p *Processor) applyResourcesCEL(resources ref.Val, namespaceVal ref.Val) ref.Val {
+	namespace, err := valToString(namespaceVal)
+	if err != nil {
+		return types.ValOrErr(namespaceVal, err.Error())
+	}
+	value, err := toJSONCompatible(resources)
+	if err != nil {
+		return types.ValOrErr(resources, "applyResources: %v", err)
+	}
+	applied, err := p.applyResources(value, namespace)
+	if err != nil {
+		return types.ValOrErr(resources, "applyResources: %v", err)
+	}
+	return types.NewStringList(types.DefaultTypeAdapter, applied)
+}
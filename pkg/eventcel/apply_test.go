@@ -0,0 +1,105 @@
+package eventcel
+
+import (
+	eventsv1alpha1 "github.com/kabanero-io/events-operator/pkg/apis/events/v1alpha1"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("TestApplyResources", func() {
+	mediator := &eventsv1alpha1.EventMediator{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "webhook",
+			Namespace: "kabanero",
+			UID:       "1234",
+		},
+	}
+
+	It("should parse multiple YAML documents", func() {
+		objs, err := toUnstructuredObjects(`
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm1
+---
+---
+apiVersion: tekton.dev/v1beta1
+kind: PipelineRun
+metadata:
+  generateName: build-
+`)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(objs).To(HaveLen(2))
+		Expect(objs[0].GetName()).To(Equal("cm1"))
+		Expect(objs[1].GetKind()).To(Equal("PipelineRun"))
+	})
+
+	It("should convert maps and lists of maps", func() {
+		cm := map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata":   map[string]interface{}{"name": "cm1"},
+		}
+		objs, err := toUnstructuredObjects(cm)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(objs).To(HaveLen(1))
+		objs, err = toUnstructuredObjects([]interface{}{cm, cm})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(objs).To(HaveLen(2))
+		_, err = toUnstructuredObjects(int64(1))
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should set namespace, name, and labels", func() {
+		objs, err := toUnstructuredObjects("apiVersion: tekton.dev/v1beta1\nkind: PipelineRun\nmetadata:\n  generateName: build-\n")
+		Expect(err).ToNot(HaveOccurred())
+		obj := objs[0]
+		Expect(prepareObject(obj, "", "20200101000000", 0, mediator, "mediation1")).To(Succeed())
+		Expect(obj.GetNamespace()).To(Equal("kabanero"))
+		Expect(obj.GetName()).To(Equal("build-20200101000000-0"))
+		Expect(obj.GetLabels()).To(HaveKeyWithValue("kabanero.io/jobid", "20200101000000"))
+		Expect(obj.GetLabels()).To(HaveKeyWithValue(LABEL_MEDIATOR, "webhook"))
+		Expect(obj.GetLabels()).To(HaveKeyWithValue(LABEL_MEDIATION, "mediation1"))
+		Expect(obj.GetOwnerReferences()).To(BeEmpty())
+	})
+
+	It("should name objects of the same generateName after the job ID of jobID", func() {
+		processor := NewProcessor(nil, nil)
+		jobID := processor.jobIDCEL().Value().(string)
+		Expect(processor.jobID).To(Equal(jobID))
+
+		objs, err := toUnstructuredObjects("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  generateName: cm-\n---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  generateName: cm-\n")
+		Expect(err).ToNot(HaveOccurred())
+		for index, obj := range objs {
+			Expect(prepareObject(obj, "", processor.jobID, index, mediator, "mediation1")).To(Succeed())
+			Expect(obj.GetLabels()).To(HaveKeyWithValue("kabanero.io/jobid", jobID))
+		}
+		Expect(objs[0].GetName()).To(Equal("cm-" + jobID + "-0"))
+		Expect(objs[1].GetName()).To(Equal("cm-" + jobID + "-1"))
+	})
+
+	It("should set the owner when garbage collected with the mediator", func() {
+		gcMediator := mediator.DeepCopy()
+		gcMediator.Spec.GarbageCollectionPolicy = GC_POLICY_MEDIATOR
+
+		objs, err := toUnstructuredObjects("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cm1\n")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(prepareObject(objs[0], "", "1", 0, gcMediator, "mediation1")).To(Succeed())
+		Expect(objs[0].GetOwnerReferences()).To(HaveLen(1))
+		Expect(objs[0].GetOwnerReferences()[0].Name).To(Equal("webhook"))
+
+		objs, err = toUnstructuredObjects("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cm1\n")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(prepareObject(objs[0], "other", "1", 0, gcMediator, "mediation1")).ToNot(Succeed())
+	})
+
+	It("should reject resources without kind or name", func() {
+		objs, err := toUnstructuredObjects("apiVersion: v1\nmetadata:\n  name: cm1\n")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(prepareObject(objs[0], "", "1", 0, mediator, "mediation1")).ToNot(Succeed())
+		objs, err = toUnstructuredObjects("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  labels:\n    a: b\n")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(prepareObject(objs[0], "", "1", 0, mediator, "mediation1")).ToNot(Succeed())
+	})
+})
@@ -0,0 +1,418 @@
+/*
+Copyright 2020 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package eventcel
+
+/* Implementation of the string, regex, JSON, encoding, crypto, time, semver, and template CEL functions */
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/common/types/traits"
+	"github.com/kabanero-io/events-operator/pkg/semverimage"
+	"github.com/kabanero-io/events-operator/pkg/utils"
+)
+
+/* implementation of regexMatch for CEL: true if the string contains a match of the regular expression */
+func (p *Processor) regexMatchCEL(strVal ref.Val, patternVal ref.Val) ref.Val {
+	str, err := valToString(strVal)
+	if err != nil {
+		return types.ValOrErr(strVal, err.Error())
+	}
+	pattern, err := valToString(patternVal)
+	if err != nil {
+		return types.ValOrErr(patternVal, err.Error())
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return types.ValOrErr(patternVal, "regexMatch: invalid regular expression %v: %v", pattern, err)
+	}
+	return types.Bool(re.MatchString(str))
+}
+
+/* implementation of regexExtract for CEL: returns the first match followed by its capture groups, or an empty list if no match */
+func (p *Processor) regexExtractCEL(strVal ref.Val, patternVal ref.Val) ref.Val {
+	str, err := valToString(strVal)
+	if err != nil {
+		return types.ValOrErr(strVal, err.Error())
+	}
+	pattern, err := valToString(patternVal)
+	if err != nil {
+		return types.ValOrErr(patternVal, err.Error())
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return types.ValOrErr(patternVal, "regexExtract: invalid regular expression %v: %v", pattern, err)
+	}
+	matches := re.FindStringSubmatch(str)
+	if matches == nil {
+		matches = []string{}
+	}
+	return types.NewStringList(types.DefaultTypeAdapter, matches)
+}
+
+/* implementation of regexReplace for CEL: replaces all matches of the regular expression. $1 etc. in the replacement refer to capture groups */
+func (p *Processor) regexReplaceCEL(values ...ref.Val) ref.Val {
+	if len(values) != 3 {
+		return types.ValOrErr(nil, "regexReplace: expecting 3 parameters but got %v", len(values))
+	}
+	str, err := valToString(values[0])
+	if err != nil {
+		return types.ValOrErr(values[0], err.Error())
+	}
+	pattern, err := valToString(values[1])
+	if err != nil {
+		return types.ValOrErr(values[1], err.Error())
+	}
+	replacement, err := valToString(values[2])
+	if err != nil {
+		return types.ValOrErr(values[2], err.Error())
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return types.ValOrErr(values[1], "regexReplace: invalid regular expression %v: %v", pattern, err)
+	}
+	return types.String(re.ReplaceAllString(str, replacement))
+}
+
+/* implementation of lower for CEL */
+func (p *Processor) lowerCEL(strVal ref.Val) ref.Val {
+	str, err := valToString(strVal)
+	if err != nil {
+		return types.ValOrErr(strVal, err.Error())
+	}
+	return types.String(strings.ToLower(str))
+}
+
+/* implementation of upper for CEL */
+func (p *Processor) upperCEL(strVal ref.Val) ref.Val {
+	str, err := valToString(strVal)
+	if err != nil {
+		return types.ValOrErr(strVal, err.Error())
+	}
+	return types.String(strings.ToUpper(str))
+}
+
+/* implementation of trim for CEL: removes leading and trailing white space */
+func (p *Processor) trimCEL(strVal ref.Val) ref.Val {
+	str, err := valToString(strVal)
+	if err != nil {
+		return types.ValOrErr(strVal, err.Error())
+	}
+	return types.String(strings.TrimSpace(str))
+}
+
+/* implementation of join for CEL: joins a list of strings with a separator */
+func (p *Processor) joinCEL(listVal ref.Val, sepVal ref.Val) ref.Val {
+	lister, ok := listVal.(traits.Lister)
+	if !ok {
+		return types.ValOrErr(listVal, "unexpected type '%v' passed as first parameter to function join", listVal.Type())
+	}
+	sep, err := valToString(sepVal)
+	if err != nil {
+		return types.ValOrErr(sepVal, err.Error())
+	}
+
+	elements := make([]string, 0)
+	it := lister.Iterator()
+	for it.HasNext() == types.True {
+		elem := it.Next()
+		str, ok := elem.(types.String)
+		if !ok {
+			return types.ValOrErr(elem, "unexpected type '%v' in list passed to function join. It should be string", elem.Type())
+		}
+		elements = append(elements, string(str))
+	}
+	return types.String(strings.Join(elements, sep))
+}
+
+/* Convert a CEL value to a value that can be marshalled to JSON */
+func toJSONCompatible(val ref.Val) (interface{}, error) {
+	return toJSONCompatibleValue(val.Value())
+}
+
+/* Convert a value that may contain CEL values to a value that can be marshalled to JSON */
+func toJSONCompatibleValue(value interface{}) (interface{}, error) {
+	switch value.(type) {
+	case ref.Val:
+		return toJSONCompatibleValue(value.(ref.Val).Value())
+	case map[ref.Val]ref.Val:
+		/* map literal created within CEL */
+		ret := make(map[string]interface{})
+		for key, elem := range value.(map[ref.Val]ref.Val) {
+			keyStr, ok := key.Value().(string)
+			if !ok {
+				return nil, fmt.Errorf("key %v of map is not a string", key)
+			}
+			converted, err := toJSONCompatibleValue(elem)
+			if err != nil {
+				return nil, err
+			}
+			ret[keyStr] = converted
+		}
+		return ret, nil
+	case []ref.Val:
+		/* list literal created within CEL */
+		listVal := value.([]ref.Val)
+		ret := make([]interface{}, 0, len(listVal))
+		for _, elem := range listVal {
+			converted, err := toJSONCompatibleValue(elem)
+			if err != nil {
+				return nil, err
+			}
+			ret = append(ret, converted)
+		}
+		return ret, nil
+	case map[string]interface{}:
+		ret := make(map[string]interface{})
+		for key, elem := range value.(map[string]interface{}) {
+			converted, err := toJSONCompatibleValue(elem)
+			if err != nil {
+				return nil, err
+			}
+			ret[key] = converted
+		}
+		return ret, nil
+	case []interface{}:
+		listVal := value.([]interface{})
+		ret := make([]interface{}, 0, len(listVal))
+		for _, elem := range listVal {
+			converted, err := toJSONCompatibleValue(elem)
+			if err != nil {
+				return nil, err
+			}
+			ret = append(ret, converted)
+		}
+		return ret, nil
+	}
+	return value, nil
+}
+
+/* implementation of jsonEncode for CEL: returns the JSON encoding of the value as a string */
+func (p *Processor) jsonEncodeCEL(val ref.Val) ref.Val {
+	value, err := toJSONCompatible(val)
+	if err != nil {
+		return types.ValOrErr(val, "jsonEncode: %v", err)
+	}
+	buf, err := json.Marshal(value)
+	if err != nil {
+		return types.ValOrErr(val, "jsonEncode: unable to marshal value of type %T to JSON: %v", value, err)
+	}
+	return types.String(string(buf))
+}
+
+/* implementation of jsonDecode for CEL: parses a JSON string */
+func (p *Processor) jsonDecodeCEL(strVal ref.Val) ref.Val {
+	str, err := valToString(strVal)
+	if err != nil {
+		return types.ValOrErr(strVal, err.Error())
+	}
+	var value interface{}
+	err = json.Unmarshal([]byte(str), &value)
+	if err != nil {
+		return types.ValOrErr(strVal, "jsonDecode: unable to parse JSON: %v", err)
+	}
+	if value == nil {
+		return types.NullValue
+	}
+	ret, err := convertToRefVal(value)
+	if err != nil {
+		return types.ValOrErr(strVal, "jsonDecode: %v", err)
+	}
+	return ret
+}
+
+/* implementation of base64Encode for CEL */
+func (p *Processor) base64EncodeCEL(strVal ref.Val) ref.Val {
+	str, err := valToString(strVal)
+	if err != nil {
+		return types.ValOrErr(strVal, err.Error())
+	}
+	return types.String(base64.StdEncoding.EncodeToString([]byte(str)))
+}
+
+/* implementation of base64Decode for CEL */
+func (p *Processor) base64DecodeCEL(strVal ref.Val) ref.Val {
+	str, err := valToString(strVal)
+	if err != nil {
+		return types.ValOrErr(strVal, err.Error())
+	}
+	decoded, err := base64.StdEncoding.DecodeString(str)
+	if err != nil {
+		return types.ValOrErr(strVal, "base64Decode: %v", err)
+	}
+	return types.String(string(decoded))
+}
+
+/* implementation of sha256 for CEL: returns the hex encoded SHA-256 hash of the string */
+func (p *Processor) sha256CEL(strVal ref.Val) ref.Val {
+	str, err := valToString(strVal)
+	if err != nil {
+		return types.ValOrErr(strVal, err.Error())
+	}
+	sum := sha256.Sum256([]byte(str))
+	return types.String(hex.EncodeToString(sum[:]))
+}
+
+/* implementation of hmac for CEL: hmac(algorithm, key, message) returns the hex encoded HMAC of the message.
+   Supported algorithms are sha1 and sha256.
+*/
+func (p *Processor) hmacCEL(values ...ref.Val) ref.Val {
+	if len(values) != 3 {
+		return types.ValOrErr(nil, "hmac: expecting 3 parameters but got %v", len(values))
+	}
+	algorithm, err := valToString(values[0])
+	if err != nil {
+		return types.ValOrErr(values[0], err.Error())
+	}
+	key, err := valToString(values[1])
+	if err != nil {
+		return types.ValOrErr(values[1], err.Error())
+	}
+	message, err := valToString(values[2])
+	if err != nil {
+		return types.ValOrErr(values[2], err.Error())
+	}
+	hash, err := utils.HashPayload(algorithm, key, []byte(message))
+	if err != nil {
+		return types.ValOrErr(values[0], "hmac: %v", err)
+	}
+	return types.String(hash)
+}
+
+/* implementation of now for CEL: returns the current time as a timestamp */
+func (p *Processor) nowCEL(values ...ref.Val) ref.Val {
+	ts, err := ptypes.TimestampProto(time.Now().UTC())
+	if err != nil {
+		return types.ValOrErr(nil, "now: %v", err)
+	}
+	return types.Timestamp{Timestamp: ts}
+}
+
+/* implementation of formatTime for CEL: formats a timestamp in UTC with a Go time layout, e.g. "2006-01-02T15:04:05Z07:00" */
+func (p *Processor) formatTimeCEL(tsVal ref.Val, layoutVal ref.Val) ref.Val {
+	ts, ok := tsVal.(types.Timestamp)
+	if !ok {
+		return types.ValOrErr(tsVal, "unexpected type '%v' passed as first parameter to function formatTime. It should be timestamp", tsVal.Type())
+	}
+	layout, err := valToString(layoutVal)
+	if err != nil {
+		return types.ValOrErr(layoutVal, err.Error())
+	}
+	t, err := ptypes.Timestamp(ts.Timestamp)
+	if err != nil {
+		return types.ValOrErr(tsVal, "formatTime: %v", err)
+	}
+	return types.String(t.UTC().Format(layout))
+}
+
+/* implementation of parseTime for CEL: parses a string with a Go time layout into a timestamp */
+func (p *Processor) parseTimeCEL(strVal ref.Val, layoutVal ref.Val) ref.Val {
+	str, err := valToString(strVal)
+	if err != nil {
+		return types.ValOrErr(strVal, err.Error())
+	}
+	layout, err := valToString(layoutVal)
+	if err != nil {
+		return types.ValOrErr(layoutVal, err.Error())
+	}
+	t, err := time.Parse(layout, str)
+	if err != nil {
+		return types.ValOrErr(strVal, "parseTime: %v", err)
+	}
+	ts, err := ptypes.TimestampProto(t)
+	if err != nil {
+		return types.ValOrErr(strVal, "parseTime: %v", err)
+	}
+	return types.Timestamp{Timestamp: ts}
+}
+
+/* Compare two semantic versions. Missing minor or patch components are treated as 0.
+   Return -1 if ver1 < ver2, 0 if equal, and 1 if ver1 > ver2
+*/
+func semverCompare(ver1 string, ver2 string) (int, error) {
+	v1, err := semverimage.NewVersion(ver1)
+	if err != nil {
+		return 0, err
+	}
+	v2, err := semverimage.NewVersion(ver2)
+	if err != nil {
+		return 0, err
+	}
+	for _, v := range []*semverimage.Version{v1, v2} {
+		if v.Minor < 0 {
+			v.Minor = 0
+		}
+		if v.Patch < 0 {
+			v.Patch = 0
+		}
+	}
+	if v1.GreaterThan(v2) {
+		return 1, nil
+	}
+	if v2.GreaterThan(v1) {
+		return -1, nil
+	}
+	return 0, nil
+}
+
+/* implementation of semverCompare for CEL */
+func (p *Processor) semverCompareCEL(ver1Val ref.Val, ver2Val ref.Val) ref.Val {
+	ver1, err := valToString(ver1Val)
+	if err != nil {
+		return types.ValOrErr(ver1Val, err.Error())
+	}
+	ver2, err := valToString(ver2Val)
+	if err != nil {
+		return types.ValOrErr(ver2Val, err.Error())
+	}
+	ret, err := semverCompare(ver1, ver2)
+	if err != nil {
+		return types.ValOrErr(ver1Val, "semverCompare: %v", err)
+	}
+	return types.Int(ret)
+}
+
+/* implementation of template for CEL: renders the named template of the mediator with the variables */
+func (p *Processor) templateCEL(nameVal ref.Val, vars ref.Val) ref.Val {
+	name, err := valToString(nameVal)
+	if err != nil {
+		return types.ValOrErr(nameVal, err.Error())
+	}
+	variables, err := toJSONCompatible(vars)
+	if err != nil {
+		return types.ValOrErr(vars, "template: %v", err)
+	}
+	rendered, err := p.renderTemplate(name, variables)
+	if err != nil {
+		return types.ValOrErr(nameVal, "template: %v", err)
+	}
+	ret, err := convertToRefVal(rendered)
+	if err != nil {
+		return types.ValOrErr(nameVal, "template: %v", err)
+	}
+	return ret
+}
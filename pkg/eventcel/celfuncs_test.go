@@ -0,0 +1,145 @@
+package eventcel
+
+import (
+	"testing"
+
+	eventsv1alpha1 "github.com/kabanero-io/events-operator/pkg/apis/events/v1alpha1"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestEventCEL(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "EventCEL Suite")
+}
+
+/* Evaluate an expression in an empty CEL environment, and return the native result */
+func evalExpression(expression string) (interface{}, error) {
+	return evalExpressionWithProcessor(NewProcessor(nil, nil), expression)
+}
+
+func evalExpressionWithProcessor(p *Processor, expression string) (interface{}, error) {
+	env, err := p.initializeEmptyCELEnv()
+	if err != nil {
+		return nil, err
+	}
+	parsed, issues := env.Parse(expression)
+	if issues != nil && issues.Err() != nil {
+		return nil, issues.Err()
+	}
+	checked, issues := env.Check(parsed)
+	if issues != nil && issues.Err() != nil {
+		return nil, issues.Err()
+	}
+	prg, err := env.Program(checked, p.getAdditionalCELFuncs())
+	if err != nil {
+		return nil, err
+	}
+	out, _, err := prg.Eval(map[string]interface{}{})
+	if err != nil {
+		return nil, err
+	}
+	return out.Value(), nil
+}
+
+type ExpressionResult struct {
+	Expression string
+	Result     interface{}
+}
+
+var _ = Describe("TestCELFunctions", func() {
+	expressions := []ExpressionResult{
+		{`regexMatch("refs/heads/master", "^refs/heads/")`, true},
+		{`regexMatch("refs/tags/v1.0", "^refs/heads/")`, false},
+		{`regexExtract("refs/tags/v1.2", "^refs/tags/v([0-9]+)\\.([0-9]+)$")[2]`, "2"},
+		{`size(regexExtract("master", "^refs/"))`, int64(0)},
+		{`regexReplace("my_repo_name", "_", "-")`, "my-repo-name"},
+		{`regexReplace("v1.2.3", "^v(.*)$", "$1")`, "1.2.3"},
+		{`lower("MyRepo")`, "myrepo"},
+		{`upper("MyRepo")`, "MYREPO"},
+		{`trim("  abc \n")`, "abc"},
+		{`join(["a", "b", "c"], "/")`, "a/b/c"},
+		{`join(split("a.b", "."), "-")`, "a-b"},
+		{`jsonEncode({"a": "b"})`, `{"a":"b"}`},
+		{`jsonEncode(["a", "b"])`, `["a","b"]`},
+		{`jsonDecode("{\"a\": {\"b\": \"c\"}}").a.b`, "c"},
+		{`jsonDecode("[1, 2]")[1]`, float64(2)},
+		{`base64Encode("hello")`, "aGVsbG8="},
+		{`base64Decode("aGVsbG8=")`, "hello"},
+		{`sha256("abc")`, "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+		{`hmac("sha256", "my-super-secret-secret", "{\"msg: \"Hello, world!\"}")`, "efa0d498cbfa1396d97d149ab64a3a9ced7922e828bc7cb0e72a564bec3fffb2"},
+		{`formatTime(timestamp("2020-03-04T05:06:07Z"), "2006-01-02 15:04")`, "2020-03-04 05:06"},
+		{`formatTime(timestamp("2020-03-04T05:06:07Z") + duration("2h"), "15:04:05")`, "07:06:07"},
+		{`formatTime(parseTime("04/03/2020", "02/01/2006"), "2006-01-02")`, "2020-03-04"},
+		{`now() > timestamp("2020-01-01T00:00:00Z")`, true},
+		{`now() - duration("1h") < now()`, true},
+		{`semverCompare("1.2.3", "1.2.4")`, int64(-1)},
+		{`semverCompare("1.10.0", "1.9.9")`, int64(1)},
+		{`semverCompare("1.2", "1.2.0")`, int64(0)},
+	}
+
+	for _, expr := range expressions {
+		expr := expr
+		It("should evaluate "+expr.Expression, func() {
+			result, err := evalExpression(expr.Expression)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result).To(Equal(expr.Result))
+		})
+	}
+
+	errorExpressions := []string{
+		`regexMatch("abc", "(")`,
+		`jsonDecode("{")`,
+		`base64Decode("!!")`,
+		`hmac("md5", "key", "message")`,
+		`semverCompare("abc", "1.0.0")`,
+	}
+
+	for _, expr := range errorExpressions {
+		expr := expr
+		It("should fail to evaluate "+expr, func() {
+			_, err := evalExpression(expr)
+			Expect(err).To(HaveOccurred())
+		})
+	}
+})
+
+var _ = Describe("TestTemplateFunction", func() {
+	textTemplate := "{{.org}}/{{.repo}}"
+	yamlTemplate := "params:\n  url: https://github.com/{{.org}}/{{.repo}}\n  names: [ {{.repo}} ]\n"
+	badTemplate := "{{.org}"
+	p := NewProcessor(nil, nil)
+	p.mediator = &eventsv1alpha1.EventMediator{
+		Spec: eventsv1alpha1.EventMediatorSpec{
+			Templates: &[]eventsv1alpha1.EventMediationTemplate{
+				{Name: "text", Template: &textTemplate},
+				{Name: "yaml", Template: &yamlTemplate, Format: "yaml"},
+				{Name: "bad", Template: &badTemplate},
+			},
+		},
+	}
+
+	It("should render a text template", func() {
+		result, err := evalExpressionWithProcessor(p, `template("text", {"org": "kabanero-io", "repo": "events-operator"})`)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result).To(Equal("kabanero-io/events-operator"))
+	})
+
+	It("should render a yaml template into a map", func() {
+		result, err := evalExpressionWithProcessor(p, `template("yaml", {"org": "kabanero-io", "repo": "events-operator"}).params.url`)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result).To(Equal("https://github.com/kabanero-io/events-operator"))
+		result, err = evalExpressionWithProcessor(p, `template("yaml", {"org": "kabanero-io", "repo": "events-operator"}).params.names[0]`)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result).To(Equal("events-operator"))
+	})
+
+	It("should fail for missing variables, unknown or invalid templates", func() {
+		_, err := evalExpressionWithProcessor(p, `template("text", {"org": "kabanero-io"})`)
+		Expect(err).To(HaveOccurred())
+		_, err = evalExpressionWithProcessor(p, `template("unknown", {})`)
+		Expect(err).To(HaveOccurred())
+		_, err = evalExpressionWithProcessor(p, `template("bad", {})`)
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -18,9 +18,12 @@ package eventcel
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
     eventsv1alpha1 "github.com/kabanero-io/events-operator/pkg/apis/events/v1alpha1"
+    "github.com/kabanero-io/events-operator/pkg/debug"
+    "github.com/kabanero-io/events-operator/pkg/event"
     "github.com/kabanero-io/events-operator/pkg/status"
     "github.com/kabanero-io/events-operator/pkg/eventenv"
     // "github.com/kabanero-io/events-operator/pkg/managers"
@@ -32,6 +35,7 @@ import (
 	//	"os"
 	"path/filepath"
 	"reflect"
+	"strconv"
 	"strings"
 	"sync"
 	"text/template"
@@ -43,6 +47,7 @@ import (
 	"github.com/google/cel-go/common/types/ref"
 	"github.com/google/cel-go/interpreter/functions"
 	exprpb "google.golang.org/genproto/googleapis/api/expr/v1alpha1"
+	"github.com/kabanero-io/events-operator/pkg/templates"
 	// "k8s.io/apimachinery/pkg/runtime/schema"
 	// k8syaml "k8s.io/apimachinery/pkg/util/yaml"
 
@@ -51,6 +56,7 @@ import (
 	// "k8s.io/client-go/dynamic"
 	"k8s.io/klog"
     "sigs.k8s.io/controller-runtime/pkg/client"
+	sigsyaml "sigs.k8s.io/yaml"
 )
 
 /* Trigger file syntax
@@ -130,6 +136,9 @@ const (
 	EVENT         = "event" // TODO: remove
 	MESSAGE       = "message"
 	HEADER        = "header"
+	PATH          = "path" // parameters captured by the urlPattern of the selector
+	COMMAND       = "command" // ChatOps command of a comment on a pull request
+	CHANGED_FILES = "changedFiles" // files changed by a push or pull request
 	JOBID         = "jobid"
 	TYPEINT       = "int"
 	TYPEDOUBLE    = "double"
@@ -142,6 +151,18 @@ const (
 	IF            = "if"
 	SWITCH        = "switch"
 	DEFAULT       = "default"
+	TEMPLATE_FORMAT_TEXT = "text"
+	TEMPLATE_FORMAT_YAML = "yaml"
+	TEMPLATE_FORMAT_JSON = "json"
+	TRY           = "try"
+	ONERROR       = "onError"
+	FAIL          = "fail"
+	ASSIGN        = "="
+	VARIABLES     = "variables"
+	SENDEVENT     = "sendEvent"
+	ERROR         = "error"
+	DESTINATION   = "destination"
+	STATEMENT     = "statement"
 	EVENTSOURCE   = "eventSource"
 	INPUT         = "input"
 	OUTPUT        = "output"
@@ -163,11 +184,27 @@ const (
     WEBHOOKS_TEKTON_EVENT_TYPE_VARIABLE = "body.webhooks-tekton-event-type"
     WEBHOOKS_TEKTON_TAG_SHA = "body.webhooks-tekton-sha"
     WEBHOOKS_TEKTON_TAG_VERSION = "body.webhooks-tekton-tag-version"
+    WEBHOOKS_TEKTON_ACTION = "body.webhooks-tekton-action"
+    WEBHOOKS_TEKTON_SENDER = "body.webhooks-tekton-sender"
+    WEBHOOKS_TEKTON_PULL_REQUEST_NUMBER = "body.webhooks-tekton-pull-request-number"
+    WEBHOOKS_TEKTON_HEAD_SHA = "body.webhooks-tekton-head-sha"
+    WEBHOOKS_TEKTON_BASE_SHA = "body.webhooks-tekton-base-sha"
+    WEBHOOKS_TEKTON_GIT_BASE_BRANCH = "body.webhooks-tekton-git-base-branch"
+    WEBHOOKS_TEKTON_MERGED = "body.webhooks-tekton-merged"
+    WEBHOOKS_TEKTON_REF_TYPE = "body.webhooks-tekton-ref-type"
+    WEBHOOKS_TEKTON_COMMENT = "body.webhooks-tekton-comment"
+    WEBHOOKS_TEKTON_HOOK_ID = "body.webhooks-tekton-hook-id"
+    WEBHOOKS_TEKTON_PROJECT_DIR = "body.webhooks-tekton-project-dir"
 //    WEBHOOKS_TEKTON_MONITOR_VARIABLE = "body.webhooks-tekton-monitor"
     WEBHOOKS_KABANERO_TEKTON_LISTENER = "body.webhooks-kabanero-tekton-listener"
     WEBHOOKS_TEKTON_GITHUB_SECRET_NAME = "body.webhooks-tekton-github-secret-name"
     WEBHOOKS_TEKTON_GITHUB_SECRET_KEY_NAME = "body.webhooks-tekton-github-secret-key-name"
     WEBHOOKS_TEKTON_TARGET_NAMESPACE = "body.webhooks-tekton-target-namespace"
+    WEBHOOKS_DEVFILE_NAME = "body.webhooks-devfile-name"
+    WEBHOOKS_DEVFILE_VERSION = "body.webhooks-devfile-version"
+    WEBHOOKS_DEVFILE_STACK = "body.webhooks-devfile-stack"
+    WEBHOOKS_DEVFILE_STACK_VERSION = "body.webhooks-devfile-stack-version"
+    WEBHOOKS_DEVFILE_ATTRIBUTES = "body.webhooks-devfile-attributes"
     UNKNOWN_LISTENER = "http://UNKNOWN_KABAKERO_TEKTON_LISTENER"
     HEADS = "heads"
     HEAD = "head"
@@ -176,6 +213,19 @@ const (
     PULL_REQUEST = "pull_request"
     PUSH  = "push"
     AFTER = "after"
+    BEFORE = "before"
+    BASE = "base"
+    ACTION = "action"
+    SENDER = "sender"
+    REF_TYPE = "ref_type"
+    PULL_REQUEST_REVIEW = "pull_request_review"
+    CREATE = "create"
+    DELETE = "delete"
+    RELEASE = "release"
+    ISSUE_COMMENT = "issue_comment"
+    CHECK_SUITE = "check_suite"
+    CHECK_RUN = "check_run"
+    PING = "ping"
 
 )
 
@@ -188,6 +238,12 @@ const (
 	DefaultFlag
 	// BodyFlag is flag for body statement
 	BodyFlag
+	// TryFlag is flag for try statement
+	TryFlag
+	// OnErrorFlag is flag for onError statement
+	OnErrorFlag
+	// FailFlag is flag for fail statement
+	FailFlag
 )
 
 var keywords = map[string]uint{
@@ -195,6 +251,9 @@ var keywords = map[string]uint{
 	SWITCH:  SwitchFlag,
 	DEFAULT: DefaultFlag,
 	BODY:    BodyFlag,
+	TRY:     TryFlag,
+	ONERROR: OnErrorFlag,
+	FAIL:    FailFlag,
 }
 
 
@@ -217,9 +276,41 @@ func countKeywords(statement *eventsv1alpha1.EventStatement) (int, uint) {
         count++
         flag  |= keywords[BODY]
     }
+    if  statement.Try != nil {
+        count++
+        flag  |= keywords[TRY]
+    }
+    if  statement.OnError != nil {
+        count++
+        flag  |= keywords[ONERROR]
+    }
+    if  statement.Fail != nil {
+        count++
+        flag  |= keywords[FAIL]
+    }
     return count, flag
 }
 
+/* Error returned when a statement fails to evaluate. It remembers the statement, and the destination of a failed sendEvent */
+type statementError struct {
+    statement string
+    destination string
+    err error
+}
+
+func (se *statementError) Error() string {
+    return se.err.Error()
+}
+
+/* Error returned by a fail statement to end the mediation with a user supplied message */
+type failError struct {
+    message string
+}
+
+func (fe *failError) Error() string {
+    return fmt.Sprintf("mediation failed: %v", fe.message)
+}
+
 /*
 Return
 - number of the keywords in the map
@@ -268,6 +359,20 @@ type GetEventFunctionHandler func(name string) *eventsv1alpha1.EventFunctionImpl
 
 type SendEventHandler func(processor *Processor, dest string, buf []byte, header map[string][]string) error
 
+/* How to process a message, besides the message and mediation */
+type ProcessOptions struct {
+    HasRepoType bool // true if RepositoryType is specified for the mediation
+    RepoTypeValue map[string]interface{} // the value of the yaml file specified by the RepositoryType
+    Namespace string // namespace we're running in
+    Client client.Client // controller client
+    KabaneroIntegration bool // true to generate kabanero integration attributes when processing appsody config builds
+    RemoteAddr string // remote address of incoming request. Currently not used as in OCP it is an internal IP:port that changes
+    Command *utils.GithubCommand // the ChatOps command matched by the command selector, bound to command. nil if the selector has no command
+    ChangedFiles []string // files changed by the event, bound to changedFiles. nil unless the selector has paths or discovers projects
+    ProjectDir string // directory of the project discovered through the repositoryType, bound to body.webhooks-tekton-project-dir, or ""
+    PathParams map[string]string // parameters captured by the urlPattern, bound to path. nil if urlPattern is not a pattern
+}
+
 // Processor contains the event trigger definition and the file it was loaded from
 type Processor struct {
     getFunctionHandler GetEventFunctionHandler
@@ -279,6 +384,24 @@ type Processor struct {
     variables map[string]interface{}
     env cel.Env
     statusParams *status.StatusParameters
+    failedDestination string // destination of the last sendEvent that failed
+    tryDepth int // number of try statements being evaluated
+    mediator *eventsv1alpha1.EventMediator // mediator of the message being processed
+    mediationName string
+    jobID string // job ID of resources created by applyResources: the last ID returned by jobID, if any
+    generatedNames int // number of names generated for resources of the job
+    dryRun bool // true to not send events or create resources
+    trace *debug.Trace // trace of the evaluation, or nil if not tracing
+    traceDepth int // depth of the statement being evaluated, for tracing
+    limits evalLimits // limits on the evaluation of the message being processed
+    statements int // number of statements executed
+    cost int64 // cost of the expressions evaluated
+    limitErr error // set once a limit is exceeded
+    secretVariables map[string]bool // variables holding values read from Secrets, or computed from them
+    ctx context.Context // cancelled when the evaluation times out, or the event is cancelled
+    cancel context.CancelFunc
+    namespace string
+    client client.Client
 }
 
 // NewProcessor creates a new trigger processor.
@@ -288,6 +411,7 @@ func NewProcessor( functionHandler GetEventFunctionHandler, sendHandler SendEven
         sendEventHandler: sendHandler,
         variables: make(map[string]interface{}),
         statusParams: status.NewStatusParameters(),
+        secretVariables: make(map[string]bool),
 	}
 	p.initCELFuncs()
     return p
@@ -297,6 +421,27 @@ func (processor *Processor) GetStatusParameters()  []eventsv1alpha1.EventStatusP
     return processor.statusParams.GetStatusParameters()
 }
 
+/* Return a copy of the variables of the message being processed, converted to values that can be marshalled to JSON */
+func (processor *Processor) GetVariables() (map[string]interface{}, error) {
+    ret, err := toJSONCompatibleValue(processor.variables)
+    if err != nil {
+        return nil, err
+    }
+    return ret.(map[string]interface{}), nil
+}
+
+/* Return the header and body of the message being processed. The body includes the predefined variables */
+func (processor *Processor) GetMessage() (map[string][]string, map[string]interface{}) {
+    header, _ := processor.variables[HEADER].(map[string][]string)
+    body, _ := processor.variables[BODY].(map[string]interface{})
+    return header, body
+}
+
+/* Return true if events should not be sent, and resources not created, for the message being processed */
+func (processor *Processor) IsDryRun() bool {
+    return processor.dryRun
+}
+
 // Initialize initializes a Processor with the specified trigger directory
 /*
 func (p *Processor) Initialize(dir string) error {
@@ -431,23 +576,50 @@ func (p *Processor) parseTrigger(trigger map[interface{}]interface{}) ([]string,
 
 /* ProcessMessage processes an event message.
 Input:
+    ctx: context of the event. Evaluation stops, and lookups and deliveries are cancelled, when it is done
     header: header of message
     body: body of message
     mediation: mediation to process the message
-    hasRepoType: true if RepositoryType is specified for the mediation
-    repoTypeValue: the value of the yaml file specified by the RepositoryType
-    namespace: namepsace we're running
-    client: controller client
-    kabaneroIntegration: true to generate kabanero integration attributes when processing appsody config builds
-    remoteAddr: remote address of incoming request. Currently not used as in OCP it is an internal IP:port that changes 
+    opts: repository type, namespace, client, and values of the selector of the mediation
 */
-func (p *Processor) ProcessMessage(header map[string][]string, body map[string]interface{}, mediator *eventsv1alpha1.EventMediator, mediation *eventsv1alpha1.EventMediationImpl,
-    hasRepoType bool, repoTypeValue map[string]interface{}, namespace string, client client.Client, kabaneroIntegration bool, remoteAddr string ) error {
+func (p *Processor) ProcessMessage(ctx context.Context, header map[string][]string, body map[string]interface{}, mediator *eventsv1alpha1.EventMediator, mediation *eventsv1alpha1.EventMediationImpl, opts ProcessOptions) error {
     klog.Infof("Entering Processor.ProcessMessage for mediation %v,message: %v", mediation.Name, mediation)
 	defer klog.Infof("Leaving Processor.ProcessMessage for mediation %v", mediation.Name)
 
+    p.mediator = mediator
+    p.mediationName = mediation.Name
+    p.jobID = ""
+    p.generatedNames = 0
+    p.secretVariables = make(map[string]bool)
+    p.dryRun = mediator.Spec.DryRun || mediation.DryRun
+    p.namespace = opts.Namespace
+    p.client = opts.Client
     var err error
-    p.env, err = p.initializeCELEnv(header, body, mediator, mediation, hasRepoType, repoTypeValue, namespace, client, kabaneroIntegration, remoteAddr)
+    p.trace = nil
+    if mediation.Trace || traceRequested(header) {
+        p.trace = &debug.Trace {
+            Mediator: mediator.Name,
+            Mediation: mediation.Name,
+            DeliveryID: event.DeliveryID(ctx),
+            Steps: make([]debug.TraceStep, 0),
+        }
+        defer func() { p.saveTrace(err) }()
+    }
+
+    limits, err := resolveLimits(mediator.Spec.Limits, mediation.Limits)
+    if err != nil {
+        summary := &eventsv1alpha1.EventStatusSummary  {
+             Operation: status.OPERATION_EVALUATE_MEDIATION,
+             Input: p.statusParams.GetStatusParameters(),
+             Result: status.RESULT_FAILED,
+             Message: fmt.Sprintf("Invalid limits: %v", err),
+        }
+        eventenv.GetEventEnv().StatusMgr.AddEventSummary(summary)
+        return err
+    }
+    defer p.startLimits(ctx, limits)()
+
+    p.env, err = p.initializeCELEnv(header, body, mediator, mediation, opts)
 	if err != nil {
         summary := &eventsv1alpha1.EventStatusSummary  {
              Operation: status.OPERATION_INITIALIZE_VARIABLES,
@@ -460,7 +632,7 @@ func (p *Processor) ProcessMessage(header map[string][]string, body map[string]i
 		return err
 	}
 	if klog.V(5) {
-		klog.Infof("ProcessMessage after initializeCELEnv. variables: %v", p.variables)
+		klog.Infof("ProcessMessage after initializeCELEnv. variables: %v", p.redactedVariables())
 	}
 
     klog.Infof("ProcessMessage evaluating mediation %v", mediation.Body)
@@ -473,6 +645,14 @@ func (p *Processor) ProcessMessage(header map[string][]string, body map[string]i
              Result: status.RESULT_FAILED,
              Message: fmt.Sprintf("Mediation Evaluation Error: %v", err),
         }
+        if failure, ok := err.(*failError); ok {
+             /* ended by a fail statement */
+             summary.Message = failure.message
+        } else if p.limitErr != nil {
+             /* the error may have been wrapped by a function */
+             err = p.limitErr
+             summary.Message = fmt.Sprintf("Mediation Evaluation Error: %v", err)
+        }
         eventenv.GetEventEnv().StatusMgr.AddEventSummary(summary)
 		klog.Errorf("Error evaluating mediation %v: ERROR MESSAGE: %v", mediation, err)
 		return err
@@ -505,6 +685,10 @@ func (p *Processor) evalEventStatementArray(env cel.Env, variables map[string]in
 
 	var err error
 	for _, object := range bodyArray {
+		err = p.checkStatement(depth)
+		if err != nil {
+			return env, err
+		}
 		numKeywords, flags := countKeywords(&object)
 		switch {
 		case (flags & IfFlag) != 0:
@@ -523,6 +707,7 @@ func (p *Processor) evalEventStatementArray(env cel.Env, variables map[string]in
 				err = fmt.Errorf("switch also contains assignment: %v", object)
 				return env, err
 			}
+			p.traceStatement(depth, SWITCH, "")
 			env, err := p.evalSwitch(env, variables, &object, numKeywords, flags, depth)
 			if err != nil {
 				return env, err
@@ -538,6 +723,7 @@ func (p *Processor) evalEventStatementArray(env cel.Env, variables map[string]in
 				err = fmt.Errorf("body also contains assignment: %v", object)
 				return env, err
 			}
+			p.traceStatement(depth, BODY, "")
 			env, err := p.evalBody(env, variables, &object, numKeywords, flags, depth)
 			if err != nil {
 				return env, err
@@ -546,6 +732,32 @@ func (p *Processor) evalEventStatementArray(env cel.Env, variables map[string]in
 			continue
 		case (flags & DefaultFlag) != 0:
 			return env, fmt.Errorf("unexpected keyword default outside of a swtich: %v", object)
+		case (flags & TryFlag) != 0:
+			if numKeywords > 2 || (numKeywords == 2 && (flags&OnErrorFlag) == 0) {
+				err = fmt.Errorf("try may only be combined with onError: %v", object)
+				return env, err
+			}
+			if object.Assign != nil {
+				err = fmt.Errorf("try also contains assignment: %v", object)
+				return env, err
+			}
+			p.traceStatement(depth, TRY, "")
+			env, err = p.evalTry(env, variables, &object, numKeywords, flags, depth)
+			if err != nil {
+				return env, err
+			}
+		case (flags & OnErrorFlag) != 0:
+			return env, fmt.Errorf("unexpected keyword onError without try: %v", object)
+		case (flags & FailFlag) != 0:
+			if numKeywords > 1 {
+				err = fmt.Errorf("fail contains more than one keyword: %v", object)
+				return env, err
+			}
+			if object.Assign != nil {
+				err = fmt.Errorf("fail also contains assignment: %v", object)
+				return env, err
+			}
+			return env, p.evalFail(env, variables, &object, depth)
 		default:
 			/* just plain assignment */
 			env, err = p.evalAssignment(env, variables, &object, numKeywords, flags, depth)
@@ -588,14 +800,89 @@ func (p *Processor) evalAssignment(env cel.Env, variables map[string]interface{}
         return env, err
     }
 
-	env, err = p.setOneVariable(env, variableName, val, variables)
+	p.failedDestination = ""
+	env, err = p.setOneVariableTraced(env, variableName, val, "", variables, depth, ASSIGN, *object.Assign)
 	if err != nil {
-		return env, err
+		return env, &statementError{statement: *object.Assign, destination: p.failedDestination, err: err}
 	}
 
 	return env, nil
 }
 
+/*
+ * Evaluate try. If any statement in the try fails, the error variable is set, and the onError body, if any, is evaluated.
+ * A fail statement within the try is not caught.
+ */
+func (p *Processor) evalTry(env cel.Env, variables map[string]interface{}, object *eventsv1alpha1.EventStatement, numKeywords int, flags uint, depth int) (cel.Env, error) {
+	p.tryDepth++
+	tryEnv, err := p.evalEventStatementArray(env, variables, *object.Try, depth+1)
+	p.tryDepth--
+	if err == nil {
+		return tryEnv, nil
+	}
+	if _, ok := err.(*failError); ok {
+		return tryEnv, err
+	}
+	if p.limitErr != nil {
+		/* limits can not be caught */
+		return tryEnv, p.limitErr
+	}
+
+	klog.Infof("evalTry caught error: %v", err)
+	errorValue := map[string]interface{}{
+		MESSAGE:     err.Error(),
+		STATEMENT:   "",
+		DESTINATION: "",
+	}
+	if stmtErr, ok := err.(*statementError); ok {
+		errorValue[STATEMENT] = stmtErr.statement
+		errorValue[DESTINATION] = stmtErr.destination
+	}
+
+	/* carry on with the variables declared in the try before the error, as their values remain set */
+	if object.OnError == nil {
+		/* errors are ignored */
+		p.traceError(depth, TRY, err)
+		return tryEnv, nil
+	}
+	p.traceError(depth, ONERROR, err)
+
+	/* the error may be set, but not declared, if an inner onError declared it in an env that is no longer used */
+	if !isDeclared(tryEnv, ERROR) {
+		ident := decls.NewIdent(ERROR, decls.NewMapType(decls.String, decls.Any), nil)
+		tryEnv, err = tryEnv.Extend(cel.Declarations(ident))
+		if err != nil {
+			return tryEnv, err
+		}
+	}
+	variables[ERROR] = errorValue
+
+	return p.evalEventStatementArray(tryEnv, variables, *object.OnError, depth+1)
+}
+
+/* Return true if the identifier is declared in env */
+func isDeclared(env cel.Env, name string) bool {
+	parsed, issues := env.Parse(name)
+	if issues != nil && issues.Err() != nil {
+		return false
+	}
+	_, issues = env.Check(parsed)
+	return issues == nil || issues.Err() == nil
+}
+
+/*
+ * Evaluate fail. The expression is evaluated as the message to end the mediation with.
+ */
+func (p *Processor) evalFail(env cel.Env, variables map[string]interface{}, object *eventsv1alpha1.EventStatement, depth int) error {
+	message, err := p.evaluateStringWithEnv(env, *object.Fail, variables)
+	if err != nil {
+		p.traceError(depth, FAIL, err)
+		return &statementError{statement: *object.Fail, err: err}
+	}
+	p.traceError(depth, FAIL, fmt.Errorf("%v", message))
+	return &failError{message: message}
+}
+
 /*
  * Evaluate body
  */
@@ -603,7 +890,7 @@ func (p *Processor) evalBody(env cel.Env, variables map[string]interface{}, obje
 	/* check if recursive body exists */
 	nestedBody := object.Body
     if nestedBody != nil  {
-		return  p.evalEventStatementArray(env, variables, *nestedBody, depth)
+		return  p.evalEventStatementArray(env, variables, *nestedBody, depth+1)
 	}
 	return env, nil
 }
@@ -616,9 +903,9 @@ func (p *Processor) evalIfWithSyntaxCheck(env cel.Env, variables map[string]inte
 		err := fmt.Errorf("body of if %v contains more than two keyword", object)
 		return env, false, err
 	}
-	if numKeywords == 2 && (flags&BodyFlag) == 0 && (flags&SwitchFlag) == 0 {
-		/* second keyword is not body or switch */
-		err := fmt.Errorf("if object also contains keywords other than body or switch: %v", object)
+	if numKeywords == 2 && (flags&BodyFlag) == 0 && (flags&SwitchFlag) == 0 && (flags&FailFlag) == 0 {
+		/* second keyword is not body, switch, or fail */
+		err := fmt.Errorf("if object also contains keywords other than body, switch, or fail: %v", object)
 		return env, false, err
 	}
 	if numKeywords == 2 && object.Assign != nil {
@@ -631,8 +918,9 @@ func (p *Processor) evalIfWithSyntaxCheck(env cel.Env, variables map[string]inte
 		return env, false, fmt.Errorf("condition of if statement is nil: %v", object)
 	}
 	boolVal, err := p.evalCondition(env, *condition, variables)
+	p.traceCondition(depth, *condition, boolVal, err)
 	if err != nil {
-		return env, false, err
+		return env, false, &statementError{statement: *condition, err: err}
 	}
 
 	if !boolVal {
@@ -658,6 +946,11 @@ func (p *Processor) evalIfWithSyntaxCheck(env cel.Env, variables map[string]inte
 		return env, true, err
 	}
 
+	if object.Fail != nil {
+		/* if statement also contains fail */
+		return env, true, p.evalFail(env, variables, object, depth)
+	}
+
 	/* perform assignments */
 	env, err = p.evalAssignment(env, variables, object, numKeywords, flags, depth)
 	return env, true, err
@@ -676,7 +969,7 @@ func (p *Processor) evalSwitch(env cel.Env, variables map[string]interface{}, ob
 		ifOK := (arrayElement.If != nil)
 		if ifOK {
 			/* evaluate the if statement */
-			env, conditionTrue, err := p.evalIfWithSyntaxCheck(env, variables, &arrayElement, switchCaseNumKeywords, switchCaseFlags, depth)
+			env, conditionTrue, err := p.evalIfWithSyntaxCheck(env, variables, &arrayElement, switchCaseNumKeywords, switchCaseFlags, depth+1)
 			if err != nil || conditionTrue {
 				return env, err
 			}
@@ -701,7 +994,8 @@ func (p *Processor) evalSwitch(env cel.Env, variables map[string]interface{}, ob
 
 	/* evaluate defaults */
 	if defaultArray != nil {
-		env, err = p.evalEventStatementArray(env, variables, *defaultArray, depth)
+		p.traceStatement(depth+1, DEFAULT, "")
+		env, err = p.evalEventStatementArray(env, variables, *defaultArray, depth+2)
 		if err != nil {
 			return env, err
 		}
@@ -733,12 +1027,7 @@ func (p *Processor) initializeEmptyCELEnv() (cel.Env, error) {
 setting github and Tekton listener related variables.
   header, body: incoming message
   mediationImpl: the mediation to process the message
-  hasRepoType: true if RepositoryType specified
-  repoTypeValue: value of the repository type 
-  namespace: namespace we're running in
-  client: controller client
-  kabaneroIntegration: true to generate Kabanero integration attributes
-  remoteAddr: remote address of incoming message
+  opts: repository type, namespace, client, and values derived from matching the selector of the mediation
 Return: cel.Env: the CEL environment
 	map[string]interface{}: variables used during substitution
     inputVariableName name of input variable, to be bound to message
@@ -746,7 +1035,7 @@ Return: cel.Env: the CEL environment
     []EventStatusParameter: collected status parameters 
 	error: any error encountered
 */
-func (p *Processor) initializeCELEnv(header map[string][]string, body map[string]interface{}, mediator *eventsv1alpha1.EventMediator, mediationImpl *eventsv1alpha1.EventMediationImpl, hasRepoType bool, repoTypeValue map[string]interface{}, namespace string, client client.Client, kabaneroIntegration bool, remoteAddr string) (cel.Env, error) {
+func (p *Processor) initializeCELEnv(header map[string][]string, body map[string]interface{}, mediator *eventsv1alpha1.EventMediator, mediationImpl *eventsv1alpha1.EventMediationImpl, opts ProcessOptions) (cel.Env, error) {
 	if klog.V(5) {
 		klog.Infof("entering initializeCELEnv")
 		defer klog.Infof("Leaving initializeCELEnv")
@@ -782,6 +1071,40 @@ func (p *Processor) initializeCELEnv(header map[string][]string, body map[string
 	/* Add header as a new variable */
 	variables[HEADER] = header
 
+	if opts.PathParams != nil {
+		/* Add parameters captured from the url path as a new variable */
+		ident = decls.NewIdent(PATH, decls.NewMapType(decls.String, decls.String), nil)
+		env, err = env.Extend(cel.Declarations(ident))
+		if err != nil {
+			return nil, err
+		}
+		variables[PATH] = opts.PathParams
+	}
+
+	if opts.Command != nil {
+		/* Add the ChatOps command as a new variable */
+		ident = decls.NewIdent(COMMAND, decls.NewMapType(decls.String, decls.Dyn), nil)
+		env, err = env.Extend(cel.Declarations(ident))
+		if err != nil {
+			return nil, err
+		}
+		variables[COMMAND] = map[string]interface{}{
+			"name": opts.Command.Name,
+			"args": opts.Command.Args,
+			"user": opts.Command.User,
+		}
+	}
+
+	if opts.ChangedFiles != nil {
+		/* Add the files changed by the event as a new variable */
+		ident = decls.NewIdent(CHANGED_FILES, decls.NewListType(decls.String), nil)
+		env, err = env.Extend(cel.Declarations(ident))
+		if err != nil {
+			return nil, err
+		}
+		variables[CHANGED_FILES] = opts.ChangedFiles
+	}
+
     /* set the destination variables */
     for _, dest := range sendTo {
 	    destIdent := decls.NewIdent(dest, decls.NewPrimitiveType(exprpb.Type_STRING), nil)
@@ -792,9 +1115,9 @@ func (p *Processor) initializeCELEnv(header map[string][]string, body map[string
 	   variables[dest] = dest
     }
 
-    if hasRepoType {
+    if opts.HasRepoType {
        /* set the value of repository type variable */
-       data, err := json.Marshal(repoTypeValue)
+       data, err := json.Marshal(opts.RepoTypeValue)
        if err != nil {
            return nil, err
        }
@@ -854,76 +1177,31 @@ func (p *Processor) initializeCELEnv(header map[string][]string, body map[string
        if len(tempEvent) == 0 {
            return nil, fmt.Errorf("HTTP header X-Github-Event is empty")
        }
-       githubEvent := tempEvent[0]
-       if githubEvent == PULL_REQUEST {
-           pr, ok := body[PULL_REQUEST]
-           if !ok {
-              return nil, fmt.Errorf("pull request message does not contain pull_request attribute")
-           }
-           prMap, ok := pr.(map[string]interface{})
-           if !ok {
-              return nil, fmt.Errorf("pull request not a map")
-           }
-           head, ok := prMap[HEAD]
-           if !ok {
-              return nil, fmt.Errorf("pull request message does not contain pull_request.head attribute")
-           }
-           headMap, ok := head.(map[string]interface{})
-           if !ok {
-              return nil, fmt.Errorf("pull request pull_request.head not a map")
-           }
-
-           ref, ok := headMap[REF]
-           if !ok {
-              return nil, fmt.Errorf("pull request message does not contain pull_request.head.ref attribute")
-           }
-           branch, ok := ref.(string)
-           if !ok {
-              return nil, fmt.Errorf("pull request.head.ref %v is not a string, but of type %t", ref, ref)
-           }
-           env, err = p.setOneVariable(env, WEBHOOKS_TEKTON_GIT_BRANCH_VARIABLE,  "\"" + branch  + "\"", variables)
-       }  else if githubEvent == PUSH {
-           ref, exists := body[REF]
-           if exists {
-               refStr, ok := ref.(string)
-               if !ok {
-                   return nil, fmt.Errorf("body.ref is not a string. type: %T, value: %v", ref, ref)
-               }
-               components := strings.Split(refStr, "/")
-               if len(components) != 3 {
-                   return nil, fmt.Errorf("body.ref does not contain 3 components", ref)
-               }
-               if components[1] == HEADS {
-                   branch := components[2]
-                   env, err = p.setOneVariable(env, WEBHOOKS_TEKTON_GIT_BRANCH_VARIABLE,  "\"" + branch  + "\"", variables)
-                   if  err != nil {
-                      return nil, err
-                   }
-                   p.statusParams.AddParameter(status.PARAM_BRANCH, branch)
-               } else if components[1] == TAGS {
-                   githubEvent = TAG
-                   tagVersion := components[2]
-                   env, err = p.setOneVariable(env, WEBHOOKS_TEKTON_TAG_VERSION, "\""+ tagVersion + "\"", variables)
-                   if  err != nil {
-                      return nil, err
-                   }
-                   sha, ok := body[AFTER]
-                   if !ok {
-                      return nil, fmt.Errorf("tag does not contain after attribute")
-                   }
-                   shaStr, ok := sha.(string)
-                   if !ok {
-                      return nil, fmt.Errorf("tag after attribute not a string")
-                   }
-                   env, err = p.setOneVariable(env, WEBHOOKS_TEKTON_TAG_SHA, "\""+ shaStr + "\"", variables)
-                   if  err != nil {
-                      return nil, err
-                   }
-               }
-           } else {
-                  return nil, fmt.Errorf("push event does not contain ref attribute")
+       attrs, err := getGithubEventAttributes(tempEvent[0], body)
+       if err != nil {
+           return nil, err
+       }
+       if opts.Command != nil && opts.Command.PullRequest != nil {
+           attrs.setPullRequestRefs(opts.Command.PullRequest)
+       }
+       if opts.ProjectDir != "" {
+           attrs.setString(WEBHOOKS_TEKTON_PROJECT_DIR, opts.ProjectDir)
+       }
+       for _, variable := range attrs.variables {
+           env, err = p.setOneVariable(env, variable.name, variable.value, variables)
+           if  err != nil {
+              return nil, err
            }
        }
+       if attrs.branch != "" {
+           p.statusParams.AddParameter(status.PARAM_BRANCH, attrs.branch)
+       }
+       githubEvent := attrs.eventType
+       /* a ChatOps command, such as /retest, builds the head of its pull request: select the listener of a pull_request */
+       selectionEvent := githubEvent
+       if opts.Command != nil && opts.Command.PullRequest != nil {
+           selectionEvent = PULL_REQUEST
+       }
 
        p.statusParams.AddParameter(status.PARAM_GITHUB_EVENT, githubEvent)
 
@@ -944,45 +1222,48 @@ func (p *Processor) initializeCELEnv(header map[string][]string, body map[string
        }
        */
 
-       if mediator.Spec.Repositories != nil {
-           for _, repo := range *mediator.Spec.Repositories {
-               if repo.Github != nil {
-                   if repo.Github.Secret != "" {
-                       /* Set up API token secret for monitor task */
-                       env, err = p.setOneVariable(env, WEBHOOKS_TEKTON_GITHUB_SECRET_NAME,  "\"" + repo.Github.Secret +"\"", variables)
-                       if  err != nil {
-                          return nil, err
-                       }
-                       env, err = p.setOneVariable(env, WEBHOOKS_TEKTON_GITHUB_SECRET_KEY_NAME,  "\"password\"", variables)
-                       if  err != nil {
-                          return nil, err
-                       }
-                       break
-                   }
+       /* the API token secret of the first repository entry matching the repository of the event */
+       for _, githubRepo := range utils.MatchGithubRepositories(mediator.Spec.Repositories, utils.GetRepositoryURL(body)) {
+           if githubRepo.Secret != "" {
+               /* Set up API token secret for monitor task */
+               env, err = p.setOneVariable(env, WEBHOOKS_TEKTON_GITHUB_SECRET_NAME,  "\"" + githubRepo.Secret +"\"", variables)
+               if  err != nil {
+                  return nil, err
+               }
+               env, err = p.setOneVariable(env, WEBHOOKS_TEKTON_GITHUB_SECRET_KEY_NAME,  "\"password\"", variables)
+               if  err != nil {
+                  return nil, err
                }
+               break
            }
        }
 
        if mediationImpl.Selector!= nil && mediationImpl.Selector.RepositoryType != nil {
            if mediationImpl.Selector.RepositoryType.File ==  APPSODY_CONFIG_YAML {
-               stack, ok := repoTypeValue[STACK]
+               stack, ok := opts.RepoTypeValue[STACK]
                if !ok {
-                   return  nil, fmt.Errorf("Unable to find stack in appsody-configy.yaml: %v", repoTypeValue)
+                   return  nil, fmt.Errorf("Unable to find stack in appsody-configy.yaml: %v", opts.RepoTypeValue)
                }
                stackStr, ok := stack.(string)
                if !ok {
-                   return  nil, fmt.Errorf("stack %v not string in appsody-configy.yaml: %v", stack, repoTypeValue)
+                   return  nil, fmt.Errorf("stack %v not string in appsody-configy.yaml: %v", stack, opts.RepoTypeValue)
                }
                p.statusParams.AddParameter(status.PARAM_STACK, stackStr)
-               components := strings.Split(stackStr, ":")
-               if len(components) != 2 {
+               /* the tag is a version constraint, such as 0.3 or ^0.3. The image may contain the port of its registry */
+               index := strings.LastIndex(stackStr, ":")
+               if index < 0 || strings.Contains(stackStr[index:], "/") {
                    return  nil, fmt.Errorf("invalid stack value in appsody-configy.yaml:%v  ", stackStr)
                }
+               components := []string{ stackStr[:index], utils.StackVersionConstraint(mediator.Spec.StackVersions, stackStr[:index], stackStr[index+1:]) }
 
                listener := ""
                version := "unknown"
-               if kabaneroIntegration {
-                   listener, version, err = utils.FindEventListenerForStack(client, namespace, components[0], components[1])
+               if opts.KabaneroIntegration {
+                   if stackIndex := eventenv.GetEventEnv().StackIndex; stackIndex != nil && stackIndex.Synced() {
+                       listener, version, err = stackIndex.FindEventListenerForStack(opts.Namespace, components[0], components[1], p.listenerSelection(selectionEvent))
+                   } else {
+                       listener, version, err = utils.FindEventListenerForStack(p.Context(), opts.Client, opts.Namespace, components[0], components[1], p.listenerSelection(selectionEvent))
+                   }
                    if err != nil {
                        return nil, err
                    }
@@ -996,43 +1277,29 @@ func (p *Processor) initializeCELEnv(header map[string][]string, body map[string
                    return nil, err
                 }
             } else if mediationImpl.Selector.RepositoryType.File ==  DEVFILE {
-               //metadata, ok := repoTypeValue[METADATA]
-               //if !ok {
-               //    return  nil, fmt.Errorf("Unable to find metadata in devfile: %v", repoTypeValue)
-               //}
+               env, err = p.setDevfileVariables(env, opts.RepoTypeValue, opts.Namespace, opts.Client, opts.KabaneroIntegration, selectionEvent, variables)
+               if  err != nil {
+                   return nil, err
+               }
             }
         }
     }
 
     if mediator.Spec.Variables != nil {
        /* Set global variables */
-       for _, variable := range *mediator.Spec.Variables  {
-           if variable.ValueExpression != nil {
-               env, err = p.setOneVariable(env, variable.Name, *variable.ValueExpression, variables)
-               if  err != nil {
-                   return nil, err
-               }
-           } else if variable.Value != nil {
-               env, err = p.setOneVariable(env, variable.Name, "\""+ *variable.Value + "\"", variables)
-               if  err != nil {
-                   return nil, err
-               }
+       for index := range *mediator.Spec.Variables  {
+           env, err = p.setMediationVariable(env, &(*mediator.Spec.Variables)[index], variables)
+           if  err != nil {
+               return nil, err
            }
        }
     }
     if mediationImpl.Variables != nil {
        /* Set mediation variables */
-       for _, variable := range *mediationImpl.Variables  {
-           if variable.ValueExpression != nil {
-               env, err = p.setOneVariable(env, variable.Name, *variable.ValueExpression, variables)
-               if  err != nil {
-                   return nil, err
-               }
-           } else if variable.Value != nil {
-               env, err = p.setOneVariable(env, variable.Name, "\""+ *variable.Value + "\"", variables)
-               if  err != nil {
-                   return nil, err
-               }
+       for index := range *mediationImpl.Variables  {
+           env, err = p.setMediationVariable(env, &(*mediationImpl.Variables)[index], variables)
+           if  err != nil {
+               return nil, err
            }
        }
     }
@@ -1040,25 +1307,41 @@ func (p *Processor) initializeCELEnv(header map[string][]string, body map[string
 	return env, nil
 }
 
+/* Return true if a try statement is being evaluated, so that errors of sendEvent can be caught
+*/
+func (p *Processor) InTry() bool {
+	return p.tryDepth > 0
+}
+
 /* Evaluate an expressions that should result in a string
 */
 func (p *Processor) EvaluateString(val string) (string, error) {
+	return p.evaluateStringWithEnv(p.env, val, p.variables)
+}
+
+/* Evaluate an expression that should result in a string, using the given environment and variables
+*/
+func (p *Processor) evaluateStringWithEnv(env cel.Env, val string, variables map[string]interface{}) (string, error) {
 
 	val = strings.Trim(val, " ")
-	parsed, issues := p.env.Parse(val)
+	parsed, issues := env.Parse(val)
 	if issues != nil && issues.Err() != nil {
 		return "", fmt.Errorf("EvaluteString: parsing error for expression %s, error: %v", val, issues.Err())
 	}
-	checked, issues := p.env.Check(parsed)
+	checked, issues := env.Check(parsed)
 	if issues != nil && issues.Err() != nil {
 		return "", fmt.Errorf("EvaluteString: CEL check error for expresion %s, error: %v", val, issues.Err())
 	}
-	prg, err := p.env.Program(checked, p.getAdditionalCELFuncs())
+	err := p.chargeCost(checked)
+	if err != nil {
+		return "", err
+	}
+	prg, err := p.program(env, checked)
 	if err != nil {
 		return "", fmt.Errorf("EvaluteString: CEL program error for expression %s, error: %v", val, err)
 	}
 	// out, details, err := prg.Eval(variables)
-	out, _, err := prg.Eval(p.variables)
+	out, err := p.evalProgram(prg, variables)
 	if err != nil {
 		return "", fmt.Errorf("EvaluteString: CEL Eval error for expression %s, error: %v", val, err)
 	}
@@ -1076,6 +1359,11 @@ func (p *Processor) EvaluateString(val string) (string, error) {
 }
 
 func (p *Processor) setOneVariable(env cel.Env, name string, val string, variables map[string]interface{}) (cel.Env, error) {
+	return p.setOneTypedVariable(env, name, val, "", variables)
+}
+
+/* Set a variable to the value of an expression. If varType is not "", the value must be of the type */
+func (p *Processor) setOneTypedVariable(env cel.Env, name string, val string, varType string, variables map[string]interface{}) (cel.Env, error) {
 
 	val = strings.Trim(val, " ")
 
@@ -1085,22 +1373,38 @@ func (p *Processor) setOneVariable(env cel.Env, name string, val string, variabl
 	}
 	checked, issues := env.Check(parsed)
 	if issues != nil && issues.Err() != nil {
-		return env, fmt.Errorf("CEL check error when setting variable %s to %s, error: %v, existing variables: %v", name, val, issues.Err(), variables)
+		return env, fmt.Errorf("CEL check error when setting variable %s to %s, error: %v", name, val, issues.Err())
+	}
+	secret := p.referencesSecret(checked.Expr())
+	err := p.chargeCost(checked)
+	if err != nil {
+		return env, err
 	}
-	prg, err := env.Program(checked, p.getAdditionalCELFuncs())
+	prg, err := p.program(env, checked)
 	if err != nil {
 		return env, fmt.Errorf("CEL program error when setting variable %s to %s, error: %v", name, val, err)
 	}
 	// out, details, err := prg.Eval(variables)
-	out, _, err := prg.Eval(variables)
+	out, err := p.evalProgram(prg, variables)
 	if err != nil {
 		return env, fmt.Errorf("CEL Eval error when setting variable %s to %s, error: %v", name, val, err)
 	}
 
-    klog.Infof("When setting variable %s to %s, eval of value results in typename: %s, value type: %T, value: %s\n", name, val, out.Type().TypeName(), out.Value(), out.Value())
+    var value interface{} = out.Value()
+    if secret {
+        value = REDACTED
+    }
+    klog.Infof("When setting variable %s to %s, eval of value results in typename: %s, value type: %T, value: %s\n", name, val, out.Type().TypeName(), out.Value(), value)
+    err = checkVariableType(name, varType, out)
+    if err != nil {
+        return env, err
+    }
 
     if name != "" {
         env, err = createOneVariable(env, name, val, out, variables)
+        if err == nil {
+            p.setSecretVariable(name, secret)
+        }
 	    return env, err
     } else {
          /* no variable to assign */
@@ -1108,6 +1412,66 @@ func (p *Processor) setOneVariable(env cel.Env, name string, val string, variabl
     }
 }
 
+/* Return how to select the event listener of a stack for an event of the type: push, pull_request, tag, or another GitHub event */
+func (p *Processor) listenerSelection(eventType string) *utils.ListenerSelection {
+    selection := &utils.ListenerSelection{ EventType: eventType }
+    if p.mediator.Spec.ListenerSelectors != nil {
+        selection.Selectors = *p.mediator.Spec.ListenerSelectors
+    }
+    return selection
+}
+
+/* Set the predefined variables for a devfile.yaml of schema version 2, and find the event listener of the stack of its parent */
+func (p *Processor) setDevfileVariables(env cel.Env, devfileMap map[string]interface{}, namespace string, client client.Client, kabaneroIntegration bool, eventType string, variables map[string]interface{}) (cel.Env, error) {
+    devfile, err := utils.ParseDevfile(devfileMap)
+    if err != nil {
+        return nil, err
+    }
+
+    stringVariables := []struct{ name string; value string } {
+        { WEBHOOKS_DEVFILE_NAME, devfile.Name },
+        { WEBHOOKS_DEVFILE_VERSION, devfile.Version },
+        { WEBHOOKS_DEVFILE_STACK, devfile.Stack },
+        { WEBHOOKS_DEVFILE_STACK_VERSION, devfile.StackVersion },
+    }
+    for _, variable := range stringVariables {
+        env, err = p.setOneVariable(env, variable.name, strconv.Quote(variable.value), variables)
+        if  err != nil {
+            return nil, err
+        }
+    }
+    data, err := json.Marshal(devfile.Attributes)
+    if err != nil {
+        return nil, err
+    }
+    env, err = p.setOneVariable(env, WEBHOOKS_DEVFILE_ATTRIBUTES, string(data), variables)
+    if  err != nil {
+        return nil, err
+    }
+
+    listener := ""
+    version := "unknown"
+    if devfile.Stack != "" {
+        p.statusParams.AddParameter(status.PARAM_STACK, devfile.Stack)
+        if kabaneroIntegration {
+            stackVersion := utils.StackVersionConstraint(p.mediator.Spec.StackVersions, devfile.Stack, devfile.StackVersion)
+            if stackIndex := eventenv.GetEventEnv().StackIndex; stackIndex != nil && stackIndex.Synced() {
+                listener, version, err = stackIndex.FindEventListenerForStackName(namespace, devfile.Stack, stackVersion, p.listenerSelection(eventType))
+            } else {
+                listener, version, err = utils.FindEventListenerForStackName(p.Context(), client, namespace, devfile.Stack, stackVersion, p.listenerSelection(eventType))
+            }
+            if err != nil {
+                return nil, err
+            }
+        }
+    }
+    if listener == "" {
+        listener = UNKNOWN_LISTENER
+    }
+    klog.Infof("For devfile stack %s, version %s, found event listener %s, version: %v", devfile.Stack, devfile.StackVersion, listener, version)
+    return p.setOneVariable(env, WEBHOOKS_KABANERO_TEKTON_LISTENER,  "\"" + listener + "\"", variables)
+}
+
 func createOneVariable(env cel.Env, entireName string, val string, out ref.Val, variables map[string]interface{}) (cel.Env, error) {
 	if klog.V(6) {
 		klog.Infof("Entering createOneVariables: setting %v to %v", entireName, val)
@@ -1507,12 +1871,16 @@ func (p *Processor) evalCondition(env cel.Env, when string, variables map[string
 	if issues != nil && issues.Err() != nil {
 		return false, fmt.Errorf("error parsing condition %s, error: %v", when, issues.Err())
 	}
-	prg, err := env.Program(checked, p.getAdditionalCELFuncs())
+	err := p.chargeCost(checked)
+	if err != nil {
+		return false, err
+	}
+	prg, err := p.program(env, checked)
 	if err != nil {
 		return false, fmt.Errorf("error creating CEL program for condition %s, error: %v", when, err)
 	}
 	// out, details, err := prg.Eval(variables)
-	out, _, err := prg.Eval(variables)
+	out, err := p.evalProgram(prg, variables)
 	if err != nil {
 		return false, fmt.Errorf("error evaluating condition %s, error: %v", when, err)
 	}
@@ -1588,6 +1956,59 @@ func SubstituteTemplate(templateStr string, variables interface{}) (string, erro
 	return buffer.String(), nil
 }
 
+/* Find the template with the given name in the mediator, and render it with the variables.
+   Return the rendered string if the template format is text, or the map parsed from the rendered yaml or json
+*/
+func (p *Processor) renderTemplate(name string, variables interface{}) (interface{}, error) {
+	if p.mediator == nil || p.mediator.Spec.Templates == nil {
+		return nil, fmt.Errorf("template %v not found", name)
+	}
+	var templateDef *eventsv1alpha1.EventMediationTemplate
+	for index := range *p.mediator.Spec.Templates {
+		if (*p.mediator.Spec.Templates)[index].Name == name {
+			templateDef = &(*p.mediator.Spec.Templates)[index]
+			break
+		}
+	}
+	if templateDef == nil {
+		return nil, fmt.Errorf("template %v not found", name)
+	}
+
+	var tmpl *template.Template
+	var err error
+	if templateDef.Template != nil {
+		tmpl, err = templates.ParseTemplate(name, *templateDef.Template)
+	} else if templateDef.ConfigMap != nil {
+		tmpl, err = eventenv.GetEventEnv().TemplateMgr.GetConfigMapTemplate(p.Context(), p.client, p.namespace, templateDef.ConfigMap.Name, templateDef.ConfigMap.Key)
+	} else {
+		err = fmt.Errorf("template %v contains neither template nor configMap", name)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	buffer := new(bytes.Buffer)
+	err = tmpl.Execute(buffer, variables)
+	if err != nil {
+		return nil, fmt.Errorf("unable to render template %v: %v", name, err)
+	}
+
+	switch templateDef.Format {
+	case "", TEMPLATE_FORMAT_TEXT:
+		return buffer.String(), nil
+	case TEMPLATE_FORMAT_YAML, TEMPLATE_FORMAT_JSON:
+		/* sigs.k8s.io/yaml converts through JSON, so nested maps are map[string]interface{} */
+		ret := make(map[string]interface{})
+		err = sigsyaml.Unmarshal(buffer.Bytes(), &ret)
+		if err != nil {
+			return nil, fmt.Errorf("template %v did not render valid %v: %v", name, templateDef.Format, err)
+		}
+		return ret, nil
+	default:
+		return nil, fmt.Errorf("template %v has unsupported format %v", name, templateDef.Format)
+	}
+}
+
 /* Create resource. Assume it does not already exist */
 //func (p *Processor) createResource(resourceStr string) error {
 //	if klog.V(4) {
@@ -1811,7 +2232,7 @@ func (p *Processor) eventListenerURL(param ref.Val) ref.Val {
 	}
      /* TODO: avoid using global */
     eventEnv := eventenv.GetEventEnv()
-	url, err := utils.EventListenerURL(eventEnv.Client, eventEnv.Namespace, string(str))
+	url, err := utils.EventListenerURL(p.Context(), eventEnv.Client, eventEnv.Namespace, string(str))
     if err != nil {
         return types.String("https://" + fmt.Sprintf("%v", err))
     }
@@ -1890,9 +2311,11 @@ func valToInt(intVal ref.Val) (int, error) {
 	return int(i), nil
 }
 
-/* Return next job ID */
+/* Return next job ID. Resources applied afterwards are labeled with it */
 func (p *Processor) jobIDCEL(values ...ref.Val) ref.Val {
-	return types.String(GetTimestamp())
+	p.jobID = GetTimestamp()
+	p.generatedNames = 0
+	return types.String(p.jobID)
 }
 
 /* Return kabanero config */
@@ -2103,41 +2526,6 @@ func convertToRefVal(outValueObj interface{}) (ref.Val, error) {
 	return ret, err
 }
 
-/* implementation of call for applyResources.
-   dir string: directory
-   variable Any: variable to pass to go template
-   Return string : empty if OK, otherwise, error message
-*/
-//func (p *Processor) applyResourcesCEL(dir ref.Val, variables ref.Val) ref.Val {
-//	klog.Infof("applyResourcesCEL first param: %v, second param: %v", dir, variables)
-//
-//	if variables.Value() == nil {
-//		klog.Infof("applyResourcesCEL variables is nil")
-//		return types.ValOrErr(variables, "unexpected null second parameter passed to function applyResources.")
-//	}
-//
-//	if dir.Value() == nil {
-//		klog.Infof("applyResourcesCEL directory is nil")
-//		return types.ValOrErr(dir, "unexpected null first parameter passed to function applyResources.")
-//	}
-//	klog.Infof("applyResources first param type: %v, second param type: %v", dir.Type(), variables.Type())
-//
-//	dirStr, ok := dir.Value().(string)
-//	if !ok {
-//		klog.Infof("applyResources dir is not string")
-//		return types.ValOrErr(dir, "unexpected type '%v' passed as first parameter to function applyResources. It should be string", dir.Type())
-//	}
-//
-//	err := p.applyResourcesHelper(p.triggerDir, dirStr, variables.Value(), p.triggerDef.isDryRun())
-//	var ret ref.Val
-//	if err != nil {
-//		ret = types.String(fmt.Sprintf("applyResources error  applying template %v", err))
-//	} else {
-//		ret = types.String("")
-//	}
-//	return ret
-//}
-
 /* Find files with given suffixes */
 func findFiles(resourceDir string, suffixes []string) ([]string, error) {
 
@@ -2316,15 +2704,13 @@ func (p *Processor) sendEventCEL(refs ...ref.Val) ref.Val {
 		klog.Infof("Sending buffer: %v, header: %v", buf, headerValue)
     }
 
-/*
-	if p.triggerDef.isDryRun() {
-		klog.Infof("sendEvent: dry run is set. Event was not sent to destination '%s'", dest)
-		return types.String("")
+	err = p.checkDeadline()
+	if err == nil {
+		err = p.sendEventHandler(p, dest, buf, headerValue)
 	}
-*/
-
-	err = p.sendEventHandler(p, dest, buf, headerValue)
+	p.traceSend(dest, err)
 	if err != nil {
+		p.failedDestination = dest
 		klog.Errorf("sendEvent unable to send event to destination %v: '%v'", dest, err)
 		return types.ValOrErr(nil, "sendEventCEL: unable to send event: %v", err)
 	}
@@ -2516,10 +2902,6 @@ func (p *Processor) initCELFuncs() {
 			decls.NewOverload("call_string_any_string", []*exprpb.Type{decls.String, decls.Any}, decls.Any)),
 		decls.NewFunction("sendEvent",
 			decls.NewOverload("sendEvent_string_any_any", []*exprpb.Type{decls.String, decls.Any, decls.Any}, decls.String)),
-/*
-		decls.NewFunction("applyResources",
-			decls.NewOverload("applyResources_string_any", []*exprpb.Type{decls.String, decls.Any}, decls.String)),
-*/
 //		decls.NewFunction("kabaneroConfig",
 //			decls.NewOverload("kabaneroConfig", []*exprpb.Type{}, decls.NewMapType(decls.String, decls.Any))),
 		decls.NewFunction("jobID",
@@ -2533,7 +2915,49 @@ func (p *Processor) initCELFuncs() {
 		decls.NewFunction("split",
 			decls.NewOverload("split_string", []*exprpb.Type{decls.String, decls.String}, decls.NewListType(decls.String))),
 		decls.NewFunction("substring",
-			decls.NewOverload("substring", []*exprpb.Type{decls.String, decls.Int}, decls.String)))
+			decls.NewOverload("substring", []*exprpb.Type{decls.String, decls.Int}, decls.String)),
+		decls.NewFunction("template",
+			decls.NewOverload("template_string_any", []*exprpb.Type{decls.String, decls.Any}, decls.Any)),
+		decls.NewFunction("applyResources",
+			decls.NewOverload("applyResources_any_string", []*exprpb.Type{decls.Any, decls.String}, decls.NewListType(decls.String))),
+		decls.NewFunction("getResource",
+			decls.NewOverload("getResource_string_string_string_string", []*exprpb.Type{decls.String, decls.String, decls.String, decls.String}, decls.Any)),
+		decls.NewFunction("listResources",
+			decls.NewOverload("listResources_string_string_string_string", []*exprpb.Type{decls.String, decls.String, decls.String, decls.String}, decls.NewListType(decls.Any))),
+		decls.NewFunction("regexMatch",
+			decls.NewOverload("regexMatch_string_string", []*exprpb.Type{decls.String, decls.String}, decls.Bool)),
+		decls.NewFunction("regexExtract",
+			decls.NewOverload("regexExtract_string_string", []*exprpb.Type{decls.String, decls.String}, decls.NewListType(decls.String))),
+		decls.NewFunction("regexReplace",
+			decls.NewOverload("regexReplace_string_string_string", []*exprpb.Type{decls.String, decls.String, decls.String}, decls.String)),
+		decls.NewFunction("lower",
+			decls.NewOverload("lower_string", []*exprpb.Type{decls.String}, decls.String)),
+		decls.NewFunction("upper",
+			decls.NewOverload("upper_string", []*exprpb.Type{decls.String}, decls.String)),
+		decls.NewFunction("trim",
+			decls.NewOverload("trim_string", []*exprpb.Type{decls.String}, decls.String)),
+		decls.NewFunction("join",
+			decls.NewOverload("join_list_string", []*exprpb.Type{decls.NewListType(decls.String), decls.String}, decls.String)),
+		decls.NewFunction("jsonEncode",
+			decls.NewOverload("jsonEncode_any", []*exprpb.Type{decls.Any}, decls.String)),
+		decls.NewFunction("jsonDecode",
+			decls.NewOverload("jsonDecode_string", []*exprpb.Type{decls.String}, decls.Any)),
+		decls.NewFunction("base64Encode",
+			decls.NewOverload("base64Encode_string", []*exprpb.Type{decls.String}, decls.String)),
+		decls.NewFunction("base64Decode",
+			decls.NewOverload("base64Decode_string", []*exprpb.Type{decls.String}, decls.String)),
+		decls.NewFunction("sha256",
+			decls.NewOverload("sha256_string", []*exprpb.Type{decls.String}, decls.String)),
+		decls.NewFunction("hmac",
+			decls.NewOverload("hmac_string_string_string", []*exprpb.Type{decls.String, decls.String, decls.String}, decls.String)),
+		decls.NewFunction("now",
+			decls.NewOverload("now", []*exprpb.Type{}, decls.Timestamp)),
+		decls.NewFunction("formatTime",
+			decls.NewOverload("formatTime_timestamp_string", []*exprpb.Type{decls.Timestamp, decls.String}, decls.String)),
+		decls.NewFunction("parseTime",
+			decls.NewOverload("parseTime_string_string", []*exprpb.Type{decls.String, decls.String}, decls.Timestamp)),
+		decls.NewFunction("semverCompare",
+			decls.NewOverload("semverCompare_string_string", []*exprpb.Type{decls.String, decls.String}, decls.Int)))
 
 	p.additionalFuncs = cel.Functions(
 		&functions.Overload{
@@ -2551,11 +2975,6 @@ func (p *Processor) initCELFuncs() {
 		&functions.Overload{
 			Operator: "sendEvent",
 			Function: p.sendEventCEL},
-/*
-		&functions.Overload{
-			Operator: "applyResources",
-			Binary:   p.applyResourcesCEL},
-*/
 /*		&functions.Overload{
 			Operator: "kabaneroConfig",
 			Function: p.kabaneroConfigCEL},
@@ -2563,6 +2982,9 @@ func (p *Processor) initCELFuncs() {
 		&functions.Overload{
 			Operator: "jobID",
 			Function: p.jobIDCEL},
+		&functions.Overload{
+			Operator: CHARGE_ITERATION,
+			Binary:   p.chargeIterationCEL},
 		/*&functions.Overload{
 			Operator: "downloadYAML",
 			Binary:   p.downloadYAMLCEL}, */
@@ -2578,5 +3000,68 @@ func (p *Processor) initCELFuncs() {
 		&functions.Overload{
 			Operator: "substring",
 			Binary:   p.substringCEL},
+		&functions.Overload{
+			Operator: "template",
+			Binary:   p.templateCEL},
+		&functions.Overload{
+			Operator: "applyResources",
+			Binary:   p.applyResourcesCEL},
+		&functions.Overload{
+			Operator: "getResource",
+			Function: p.getResourceCEL},
+		&functions.Overload{
+			Operator: "listResources",
+			Function: p.listResourcesCEL},
+		&functions.Overload{
+			Operator: "regexMatch",
+			Binary:   p.regexMatchCEL},
+		&functions.Overload{
+			Operator: "regexExtract",
+			Binary:   p.regexExtractCEL},
+		&functions.Overload{
+			Operator: "regexReplace",
+			Function: p.regexReplaceCEL},
+		&functions.Overload{
+			Operator: "lower",
+			Unary:    p.lowerCEL},
+		&functions.Overload{
+			Operator: "upper",
+			Unary:    p.upperCEL},
+		&functions.Overload{
+			Operator: "trim",
+			Unary:    p.trimCEL},
+		&functions.Overload{
+			Operator: "join",
+			Binary:   p.joinCEL},
+		&functions.Overload{
+			Operator: "jsonEncode",
+			Unary:    p.jsonEncodeCEL},
+		&functions.Overload{
+			Operator: "jsonDecode",
+			Unary:    p.jsonDecodeCEL},
+		&functions.Overload{
+			Operator: "base64Encode",
+			Unary:    p.base64EncodeCEL},
+		&functions.Overload{
+			Operator: "base64Decode",
+			Unary:    p.base64DecodeCEL},
+		&functions.Overload{
+			Operator: "sha256",
+			Unary:    p.sha256CEL},
+		&functions.Overload{
+			Operator: "hmac",
+			Function: p.hmacCEL},
+		&functions.Overload{
+			Operator: "now",
+			Function: p.nowCEL},
+		&functions.Overload{
+			Operator: "formatTime",
+			Binary:   p.formatTimeCEL},
+		&functions.Overload{
+			Operator: "parseTime",
+			Binary:   p.parseTimeCEL},
+		&functions.Overload{
+			Operator: "semverCompare",
+			Binary:   p.semverCompareCEL},
 	)
 }
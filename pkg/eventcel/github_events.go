@@ -0,0 +1,271 @@
+/*
+Copyright 2020 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package eventcel
+
+/* Normalized webhooks-tekton-* variables of GitHub events.
+   Each event is mapped to the same set of variables, so that a mediation does not need to know
+   where in the payload of each event type the branch, SHA, or pull request number is found.
+*/
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/kabanero-io/events-operator/pkg/utils"
+)
+
+/* A variable derived from a GitHub event, and the CEL expression of its value */
+type githubEventVariable struct {
+	name  string
+	value string
+}
+
+/* The normalized attributes of a GitHub event */
+type githubEventAttributes struct {
+	eventType string                // the X-Github-Event header, or tag for a push of a tag
+	branch    string                // the branch of the event, or "" if the event has no branch
+	variables []githubEventVariable // the variables other than the event type, in the order to be set
+}
+
+func (attrs *githubEventAttributes) setString(name string, value string) {
+	attrs.variables = append(attrs.variables, githubEventVariable{name: name, value: strconv.Quote(value)})
+}
+
+func (attrs *githubEventAttributes) setInt(name string, value int64) {
+	attrs.variables = append(attrs.variables, githubEventVariable{name: name, value: strconv.FormatInt(value, 10)})
+}
+
+func (attrs *githubEventAttributes) setBool(name string, value bool) {
+	attrs.variables = append(attrs.variables, githubEventVariable{name: name, value: strconv.FormatBool(value)})
+}
+
+func (attrs *githubEventAttributes) setBranch(branch string) {
+	attrs.branch = branch
+	attrs.setString(WEBHOOKS_TEKTON_GIT_BRANCH_VARIABLE, branch)
+}
+
+/* Return the value at a path of nested maps of an event, or nil if it does not exist */
+func githubValue(body map[string]interface{}, path ...string) interface{} {
+	var value interface{} = body
+	for _, key := range path {
+		valueMap, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = valueMap[key]
+	}
+	return value
+}
+
+/* Return the string at a path of an event, and whether it exists */
+func githubString(body map[string]interface{}, path ...string) (string, bool) {
+	value, ok := githubValue(body, path...).(string)
+	return value, ok
+}
+
+/* Return the number at a path of an event, and whether it exists. JSON numbers are float64, YAML numbers int or int64 */
+func githubInt(body map[string]interface{}, path ...string) (int64, bool) {
+	switch value := githubValue(body, path...).(type) {
+	case float64:
+		return int64(value), true
+	case int:
+		return int64(value), true
+	case int64:
+		return value, true
+	case uint64:
+		return int64(value), true
+	}
+	return 0, false
+}
+
+/* Return the map at a path of an event, or nil if it does not exist */
+func githubMap(body map[string]interface{}, path ...string) map[string]interface{} {
+	value, _ := githubValue(body, path...).(map[string]interface{})
+	return value
+}
+
+/* Set the pull request variables of a pull_request object of pull_request, pull_request_review, check_suite, or check_run events */
+func (attrs *githubEventAttributes) setPullRequest(pr map[string]interface{}) {
+	if number, ok := githubInt(pr, "number"); ok {
+		attrs.setInt(WEBHOOKS_TEKTON_PULL_REQUEST_NUMBER, number)
+	}
+	if sha, ok := githubString(pr, HEAD, "sha"); ok {
+		attrs.setString(WEBHOOKS_TEKTON_HEAD_SHA, sha)
+	}
+	if sha, ok := githubString(pr, BASE, "sha"); ok {
+		attrs.setString(WEBHOOKS_TEKTON_BASE_SHA, sha)
+	}
+	if branch, ok := githubString(pr, BASE, REF); ok {
+		attrs.setString(WEBHOOKS_TEKTON_GIT_BASE_BRANCH, branch)
+	}
+}
+
+/* Set the pull request variables from the pull request of a ChatOps command, read from GitHub */
+func (attrs *githubEventAttributes) setPullRequestRefs(refs *utils.PullRequestRefs) {
+	attrs.setBranch(refs.HeadRef)
+	attrs.setInt(WEBHOOKS_TEKTON_PULL_REQUEST_NUMBER, int64(refs.Number))
+	attrs.setString(WEBHOOKS_TEKTON_HEAD_SHA, refs.HeadSHA)
+	attrs.setString(WEBHOOKS_TEKTON_BASE_SHA, refs.BaseSHA)
+	attrs.setString(WEBHOOKS_TEKTON_GIT_BASE_BRANCH, refs.BaseRef)
+}
+
+/* Set the variables of a check_suite or check_run object. The pull request is the first one of the check, if any */
+func (attrs *githubEventAttributes) setCheck(check map[string]interface{}, headBranch string) {
+	if headBranch != "" {
+		attrs.setBranch(headBranch)
+	}
+	if sha, ok := githubString(check, "head_sha"); ok {
+		attrs.setString(WEBHOOKS_TEKTON_HEAD_SHA, sha)
+	}
+	if prs, ok := check["pull_requests"].([]interface{}); ok && len(prs) > 0 {
+		if pr, ok := prs[0].(map[string]interface{}); ok {
+			if number, ok := githubInt(pr, "number"); ok {
+				attrs.setInt(WEBHOOKS_TEKTON_PULL_REQUEST_NUMBER, number)
+			}
+			if sha, ok := githubString(pr, BASE, "sha"); ok {
+				attrs.setString(WEBHOOKS_TEKTON_BASE_SHA, sha)
+			}
+			if branch, ok := githubString(pr, BASE, REF); ok {
+				attrs.setString(WEBHOOKS_TEKTON_GIT_BASE_BRANCH, branch)
+			}
+		}
+	}
+}
+
+/*
+Return the normalized attributes of a GitHub event.
+Input:
+    githubEvent: value of the X-Github-Event header
+    body: payload of the event
+Output:
+    the attributes of the event. Variables that do not apply to the event are not set.
+    error: if the payload of a push or pull_request event does not contain its branch or tag
+*/
+func getGithubEventAttributes(githubEvent string, body map[string]interface{}) (*githubEventAttributes, error) {
+	attrs := &githubEventAttributes{eventType: githubEvent}
+
+	if action, ok := githubString(body, ACTION); ok {
+		attrs.setString(WEBHOOKS_TEKTON_ACTION, action)
+	}
+	if sender, ok := githubString(body, SENDER, "login"); ok {
+		attrs.setString(WEBHOOKS_TEKTON_SENDER, sender)
+	}
+
+	switch githubEvent {
+	case PULL_REQUEST, PULL_REQUEST_REVIEW:
+		pr, ok := body[PULL_REQUEST]
+		if !ok {
+			return nil, fmt.Errorf("%v message does not contain pull_request attribute", githubEvent)
+		}
+		prMap, ok := pr.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("pull request not a map")
+		}
+		ref, ok := githubValue(prMap, HEAD, REF).(string)
+		if !ok {
+			return nil, fmt.Errorf("%v message does not contain string pull_request.head.ref attribute", githubEvent)
+		}
+		attrs.setBranch(ref)
+		attrs.setPullRequest(prMap)
+		/* pull_request_review events have merged_at, but not merged */
+		merged, ok := prMap["merged"].(bool)
+		if !ok {
+			merged = prMap["merged_at"] != nil
+		}
+		attrs.setBool(WEBHOOKS_TEKTON_MERGED, merged)
+
+	case PUSH:
+		ref, exists := body[REF]
+		if !exists {
+			return nil, fmt.Errorf("push event does not contain ref attribute")
+		}
+		refStr, ok := ref.(string)
+		if !ok {
+			return nil, fmt.Errorf("body.ref is not a string. type: %T, value: %v", ref, ref)
+		}
+		/* branch names may contain /, such as refs/heads/feature/a */
+		components := strings.SplitN(refStr, "/", 3)
+		if len(components) != 3 {
+			return nil, fmt.Errorf("body.ref does not contain 3 components: %v", ref)
+		}
+		if components[1] == HEADS {
+			attrs.setBranch(components[2])
+		} else if components[1] == TAGS {
+			attrs.eventType = TAG
+			attrs.setString(WEBHOOKS_TEKTON_TAG_VERSION, components[2])
+			sha, ok := body[AFTER]
+			if !ok {
+				return nil, fmt.Errorf("tag does not contain after attribute")
+			}
+			shaStr, ok := sha.(string)
+			if !ok {
+				return nil, fmt.Errorf("tag after attribute not a string")
+			}
+			attrs.setString(WEBHOOKS_TEKTON_TAG_SHA, shaStr)
+		}
+		if sha, ok := githubString(body, AFTER); ok {
+			attrs.setString(WEBHOOKS_TEKTON_HEAD_SHA, sha)
+		}
+		if sha, ok := githubString(body, BEFORE); ok {
+			attrs.setString(WEBHOOKS_TEKTON_BASE_SHA, sha)
+		}
+
+	case CREATE, DELETE:
+		/* ref is the short name of the branch or tag */
+		refType, _ := githubString(body, REF_TYPE)
+		ref, _ := githubString(body, REF)
+		attrs.setString(WEBHOOKS_TEKTON_REF_TYPE, refType)
+		if refType == "branch" {
+			attrs.setBranch(ref)
+		} else if refType == TAG {
+			attrs.setString(WEBHOOKS_TEKTON_TAG_VERSION, ref)
+		}
+
+	case RELEASE:
+		if tagName, ok := githubString(body, RELEASE, "tag_name"); ok {
+			attrs.setString(WEBHOOKS_TEKTON_TAG_VERSION, tagName)
+		}
+
+	case ISSUE_COMMENT:
+		if comment, ok := githubString(body, "comment", "body"); ok {
+			attrs.setString(WEBHOOKS_TEKTON_COMMENT, comment)
+		}
+		/* only comments on pull requests have issue.pull_request */
+		if githubMap(body, "issue", PULL_REQUEST) != nil {
+			if number, ok := githubInt(body, "issue", "number"); ok {
+				attrs.setInt(WEBHOOKS_TEKTON_PULL_REQUEST_NUMBER, number)
+			}
+		}
+
+	case CHECK_SUITE:
+		check := githubMap(body, CHECK_SUITE)
+		headBranch, _ := githubString(check, "head_branch")
+		attrs.setCheck(check, headBranch)
+
+	case CHECK_RUN:
+		check := githubMap(body, CHECK_RUN)
+		headBranch, _ := githubString(check, CHECK_SUITE, "head_branch")
+		attrs.setCheck(check, headBranch)
+
+	case PING:
+		if hookID, ok := githubInt(body, "hook_id"); ok {
+			attrs.setInt(WEBHOOKS_TEKTON_HOOK_ID, hookID)
+		}
+	}
+	return attrs, nil
+}
@@ -0,0 +1,192 @@
+package eventcel
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+
+	eventsv1alpha1 "github.com/kabanero-io/events-operator/pkg/apis/events/v1alpha1"
+	"github.com/kabanero-io/events-operator/pkg/debug"
+	"github.com/kabanero-io/events-operator/pkg/eventenv"
+	"github.com/kabanero-io/events-operator/pkg/status"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("TestGithubEvents", func() {
+	mediator := &eventsv1alpha1.EventMediator{
+		ObjectMeta: metav1.ObjectMeta{Name: "webhook", Namespace: "kabanero"},
+	}
+
+	BeforeEach(func() {
+		eventenv.InitEventEnv(&eventenv.EventEnv{
+			Cache:     fake.NewFakeClient(),
+			StatusMgr: status.NewStatusManager(),
+			DebugMgr:  debug.NewDebugManager(),
+			Namespace: "kabanero",
+		})
+	})
+
+	/* Process a recorded payload of testdata/github, and return the webhooks-tekton-* variables of the body */
+	process := func(event string, fileName string) (map[string]interface{}, error) {
+		buf, err := ioutil.ReadFile("testdata/github/" + fileName)
+		Expect(err).ToNot(HaveOccurred())
+		body := make(map[string]interface{})
+		Expect(json.Unmarshal(buf, &body)).To(Succeed())
+
+		processor := NewProcessor(nil, func(p *Processor, destination string, buf []byte, header map[string][]string) error {
+			return nil
+		})
+		header := map[string][]string{"X-Github-Event": {event}}
+		mediation := &eventsv1alpha1.EventMediationImpl{Name: "webhook"}
+		err = processor.ProcessMessage(context.Background(), header, body, mediator, mediation, ProcessOptions{Namespace: "kabanero"})
+		if err != nil {
+			return nil, err
+		}
+		variables, err := processor.GetVariables()
+		Expect(err).ToNot(HaveOccurred())
+		ret := make(map[string]interface{})
+		for key, value := range variables["body"].(map[string]interface{}) {
+			if strings.HasPrefix(key, "webhooks-tekton-") {
+				ret[key] = value
+			}
+		}
+		return ret, nil
+	}
+
+	It("should set the variables of a push to a branch", func() {
+		vars, err := process("push", "push.json")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(vars).To(HaveKeyWithValue("webhooks-tekton-event-type", "push"))
+		Expect(vars).To(HaveKeyWithValue("webhooks-tekton-git-branch", "feature/login"))
+		Expect(vars).To(HaveKeyWithValue("webhooks-tekton-head-sha", "0d1a26e67d8f5eaf1f6ba5c57fc3c7d91ac0fd1c"))
+		Expect(vars).To(HaveKeyWithValue("webhooks-tekton-base-sha", "6113728f27ae82c7b1a177c8d03f9e96e0adf246"))
+		Expect(vars).To(HaveKeyWithValue("webhooks-tekton-sender", "octocat"))
+		Expect(vars).To(HaveKeyWithValue("webhooks-tekton-git-org", "kabanero-io"))
+		Expect(vars).To(HaveKeyWithValue("webhooks-tekton-git-repo", "demo"))
+		Expect(vars).ToNot(HaveKey("webhooks-tekton-action"))
+		Expect(vars).ToNot(HaveKey("webhooks-tekton-tag-version"))
+	})
+
+	It("should set the variables of a push of a tag", func() {
+		vars, err := process("push", "push-tag.json")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(vars).To(HaveKeyWithValue("webhooks-tekton-event-type", "tag"))
+		Expect(vars).To(HaveKeyWithValue("webhooks-tekton-tag-version", "v1.2.0"))
+		Expect(vars).To(HaveKeyWithValue("webhooks-tekton-sha", "4544205a385319fd846d5df4ed2e3b8173529d78"))
+		Expect(vars).ToNot(HaveKey("webhooks-tekton-git-branch"))
+	})
+
+	It("should set the variables of a merged pull request", func() {
+		vars, err := process("pull_request", "pull_request.json")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(vars).To(HaveKeyWithValue("webhooks-tekton-event-type", "pull_request"))
+		Expect(vars).To(HaveKeyWithValue("webhooks-tekton-action", "closed"))
+		Expect(vars).To(HaveKeyWithValue("webhooks-tekton-git-branch", "changes"))
+		Expect(vars).To(HaveKeyWithValue("webhooks-tekton-git-base-branch", "master"))
+		Expect(vars).To(HaveKeyWithValue("webhooks-tekton-pull-request-number", BeEquivalentTo(2)))
+		Expect(vars).To(HaveKeyWithValue("webhooks-tekton-head-sha", "ec26c3e57ca3a959ca5aad62de7213c562f8c821"))
+		Expect(vars).To(HaveKeyWithValue("webhooks-tekton-base-sha", "f95f852bd8fca8fcc58a9a2d6c842781e32a215e"))
+		Expect(vars).To(HaveKeyWithValue("webhooks-tekton-merged", true))
+		Expect(vars).To(HaveKeyWithValue("webhooks-tekton-sender", "octocat"))
+	})
+
+	It("should set the variables of a pull request review", func() {
+		vars, err := process("pull_request_review", "pull_request_review.json")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(vars).To(HaveKeyWithValue("webhooks-tekton-event-type", "pull_request_review"))
+		Expect(vars).To(HaveKeyWithValue("webhooks-tekton-action", "submitted"))
+		Expect(vars).To(HaveKeyWithValue("webhooks-tekton-git-branch", "fix/readme"))
+		Expect(vars).To(HaveKeyWithValue("webhooks-tekton-pull-request-number", BeEquivalentTo(3)))
+		Expect(vars).To(HaveKeyWithValue("webhooks-tekton-head-sha", "ec26c3e57ca3a959ca5aad62de7213c562f8c821"))
+		Expect(vars).To(HaveKeyWithValue("webhooks-tekton-merged", false))
+		Expect(vars).To(HaveKeyWithValue("webhooks-tekton-sender", "hubot"))
+	})
+
+	It("should set the variables of a comment on a pull request", func() {
+		vars, err := process("issue_comment", "issue_comment.json")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(vars).To(HaveKeyWithValue("webhooks-tekton-event-type", "issue_comment"))
+		Expect(vars).To(HaveKeyWithValue("webhooks-tekton-action", "created"))
+		Expect(vars).To(HaveKeyWithValue("webhooks-tekton-pull-request-number", BeEquivalentTo(4)))
+		Expect(vars).To(HaveKeyWithValue("webhooks-tekton-comment", "/retest"))
+		Expect(vars).To(HaveKeyWithValue("webhooks-tekton-sender", "hubot"))
+	})
+
+	It("should not set the pull request number of a comment on an issue", func() {
+		buf, err := ioutil.ReadFile("testdata/github/issue_comment.json")
+		Expect(err).ToNot(HaveOccurred())
+		body := make(map[string]interface{})
+		Expect(json.Unmarshal(buf, &body)).To(Succeed())
+		delete(body["issue"].(map[string]interface{}), "pull_request")
+		attrs, err := getGithubEventAttributes("issue_comment", body)
+		Expect(err).ToNot(HaveOccurred())
+		for _, variable := range attrs.variables {
+			Expect(variable.name).ToNot(Equal(WEBHOOKS_TEKTON_PULL_REQUEST_NUMBER))
+		}
+	})
+
+	It("should set the variables of a release", func() {
+		vars, err := process("release", "release.json")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(vars).To(HaveKeyWithValue("webhooks-tekton-event-type", "release"))
+		Expect(vars).To(HaveKeyWithValue("webhooks-tekton-action", "published"))
+		Expect(vars).To(HaveKeyWithValue("webhooks-tekton-tag-version", "v0.0.1"))
+	})
+
+	It("should set the variables of the creation of a tag", func() {
+		vars, err := process("create", "create.json")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(vars).To(HaveKeyWithValue("webhooks-tekton-event-type", "create"))
+		Expect(vars).To(HaveKeyWithValue("webhooks-tekton-ref-type", "tag"))
+		Expect(vars).To(HaveKeyWithValue("webhooks-tekton-tag-version", "v1.3.0"))
+		Expect(vars).ToNot(HaveKey("webhooks-tekton-git-branch"))
+	})
+
+	It("should set the variables of the deletion of a branch", func() {
+		vars, err := process("delete", "delete.json")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(vars).To(HaveKeyWithValue("webhooks-tekton-event-type", "delete"))
+		Expect(vars).To(HaveKeyWithValue("webhooks-tekton-ref-type", "branch"))
+		Expect(vars).To(HaveKeyWithValue("webhooks-tekton-git-branch", "feature/login"))
+	})
+
+	It("should set the variables of a check suite", func() {
+		vars, err := process("check_suite", "check_suite.json")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(vars).To(HaveKeyWithValue("webhooks-tekton-event-type", "check_suite"))
+		Expect(vars).To(HaveKeyWithValue("webhooks-tekton-action", "completed"))
+		Expect(vars).To(HaveKeyWithValue("webhooks-tekton-git-branch", "changes"))
+		Expect(vars).To(HaveKeyWithValue("webhooks-tekton-head-sha", "ec26c3e57ca3a959ca5aad62de7213c562f8c821"))
+		Expect(vars).To(HaveKeyWithValue("webhooks-tekton-pull-request-number", BeEquivalentTo(2)))
+		Expect(vars).To(HaveKeyWithValue("webhooks-tekton-base-sha", "f95f852bd8fca8fcc58a9a2d6c842781e32a215e"))
+		Expect(vars).To(HaveKeyWithValue("webhooks-tekton-git-base-branch", "master"))
+	})
+
+	It("should set the variables of a check run", func() {
+		vars, err := process("check_run", "check_run.json")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(vars).To(HaveKeyWithValue("webhooks-tekton-event-type", "check_run"))
+		Expect(vars).To(HaveKeyWithValue("webhooks-tekton-action", "rerequested"))
+		Expect(vars).To(HaveKeyWithValue("webhooks-tekton-git-branch", "changes"))
+		Expect(vars).To(HaveKeyWithValue("webhooks-tekton-head-sha", "ec26c3e57ca3a959ca5aad62de7213c562f8c821"))
+		Expect(vars).To(HaveKeyWithValue("webhooks-tekton-pull-request-number", BeEquivalentTo(2)))
+	})
+
+	It("should set the variables of a ping", func() {
+		vars, err := process("ping", "ping.json")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(vars).To(HaveKeyWithValue("webhooks-tekton-event-type", "ping"))
+		Expect(vars).To(HaveKeyWithValue("webhooks-tekton-hook-id", BeEquivalentTo(109948940)))
+		Expect(vars).To(HaveKeyWithValue("webhooks-tekton-sender", "octocat"))
+	})
+
+	It("should reject a pull request without a head branch", func() {
+		_, err := getGithubEventAttributes("pull_request", map[string]interface{}{"pull_request": map[string]interface{}{}})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("pull_request.head.ref"))
+	})
+})
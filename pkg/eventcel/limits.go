@@ -0,0 +1,320 @@
+/*
+Copyright 2020 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package eventcel
+
+/* Limits on the evaluation of one event: nesting depth, statements executed, cost, and time.
+   The cost of evaluating an expression is the number of nodes in the expression, charged before each
+   evaluation, including the evaluation of the expression of the filter function for each element.
+   The loop of a comprehension macro, such as map or all, is charged for each iteration as it is evaluated:
+   its loop condition is wrapped in a call to CHARGE_ITERATION, which ends the loop once a limit is exceeded.
+   The deadline is checked between statements, before each evaluation of an expression, for each
+   iteration of a comprehension, and before each event is sent.
+*/
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	eventsv1alpha1 "github.com/kabanero-io/events-operator/pkg/apis/events/v1alpha1"
+	exprpb "google.golang.org/genproto/googleapis/api/expr/v1alpha1"
+)
+
+const (
+	DEFAULT_MAX_DEPTH            = 32
+	DEFAULT_MAX_STATEMENTS       = 10000
+	DEFAULT_MAX_COST       int64 = 1000000
+	DEFAULT_TIMEOUT              = 60 * time.Second
+
+	CHARGE_ITERATION = "@chargeIteration" // function wrapped around the loop condition of comprehensions. It can not be called from expressions
+)
+
+/* Limits in effect for the event being processed */
+type evalLimits struct {
+	maxDepth      int
+	maxStatements int
+	maxCost       int64
+	timeout       time.Duration // 0 for no timeout
+}
+
+/* Error returned when the evaluation exceeds a limit. It can not be caught by try */
+type limitError struct {
+	message string
+}
+
+func (le *limitError) Error() string {
+	return fmt.Sprintf("mediation evaluation stopped: %v", le.message)
+}
+
+/* Return the limits of the mediation, using those of the mediator or the defaults where not set */
+func resolveLimits(mediatorLimits *eventsv1alpha1.EventMediationLimits, mediationLimits *eventsv1alpha1.EventMediationLimits) (evalLimits, error) {
+	limits := evalLimits{
+		maxDepth:      DEFAULT_MAX_DEPTH,
+		maxStatements: DEFAULT_MAX_STATEMENTS,
+		maxCost:       DEFAULT_MAX_COST,
+		timeout:       DEFAULT_TIMEOUT,
+	}
+	for _, override := range []*eventsv1alpha1.EventMediationLimits{mediatorLimits, mediationLimits} {
+		if override == nil {
+			continue
+		}
+		if override.MaxDepth > 0 {
+			limits.maxDepth = override.MaxDepth
+		}
+		if override.MaxStatements > 0 {
+			limits.maxStatements = override.MaxStatements
+		}
+		if override.MaxCost > 0 {
+			limits.maxCost = override.MaxCost
+		}
+		if override.Timeout != "" {
+			timeout, err := time.ParseDuration(override.Timeout)
+			if err != nil {
+				return limits, fmt.Errorf("invalid timeout %v: %v", override.Timeout, err)
+			}
+			if timeout <= 0 {
+				return limits, fmt.Errorf("timeout %v is not positive", override.Timeout)
+			}
+			limits.timeout = timeout
+		}
+	}
+	return limits, nil
+}
+
+/* Start enforcing the limits for a new event processed under ctx. Return the function to call when done */
+func (p *Processor) startLimits(ctx context.Context, limits evalLimits) context.CancelFunc {
+	p.limits = limits
+	p.statements = 0
+	p.cost = 0
+	p.limitErr = nil
+	if limits.timeout > 0 {
+		p.ctx, p.cancel = context.WithTimeout(ctx, limits.timeout)
+	} else {
+		p.ctx, p.cancel = context.WithCancel(ctx)
+	}
+	return p.cancel
+}
+
+/* Context returns the context of the event being processed, to be used for lookups and deliveries */
+func (p *Processor) Context() context.Context {
+	if p.ctx == nil {
+		return context.Background()
+	}
+	return p.ctx
+}
+
+/* Record that a limit is exceeded. Later checks fail with the same error */
+func (p *Processor) exceeded(format string, args ...interface{}) error {
+	p.limitErr = &limitError{message: fmt.Sprintf(format, args...)}
+	return p.limitErr
+}
+
+/* Check that the evaluation has not been cancelled or timed out */
+func (p *Processor) checkDeadline() error {
+	if p.limitErr != nil {
+		return p.limitErr
+	}
+	if p.ctx == nil {
+		return nil
+	}
+	switch p.ctx.Err() {
+	case nil:
+		return nil
+	case context.DeadlineExceeded:
+		return p.exceeded("timeout of %v exceeded", p.limits.timeout)
+	default:
+		return p.exceeded("evaluation cancelled")
+	}
+}
+
+/* Check the nesting depth, and count a statement about to be executed */
+func (p *Processor) checkStatement(depth int) error {
+	if err := p.checkDeadline(); err != nil {
+		return err
+	}
+	if p.limits.maxDepth > 0 && depth > p.limits.maxDepth {
+		return p.exceeded("maximum nesting depth of %v exceeded", p.limits.maxDepth)
+	}
+	p.statements++
+	if p.limits.maxStatements > 0 && p.statements > p.limits.maxStatements {
+		return p.exceeded("maximum of %v statements executed exceeded", p.limits.maxStatements)
+	}
+	return nil
+}
+
+/* Charge the cost of evaluating an expression */
+func (p *Processor) chargeCost(ast cel.Ast) error {
+	return p.charge(expressionCost(ast.Expr()))
+}
+
+/* Charge a cost, after checking the deadline */
+func (p *Processor) charge(cost int64) error {
+	if err := p.checkDeadline(); err != nil {
+		return err
+	}
+	p.cost += cost
+	if p.limits.maxCost > 0 && p.cost > p.limits.maxCost {
+		return p.exceeded("maximum cost of %v exceeded", p.limits.maxCost)
+	}
+	return nil
+}
+
+/* Charge an iteration of a comprehension: the cost of its loop condition and step. Return the value of the loop condition,
+   or false to end the loop once a limit is exceeded. The limit error is returned by evalProgram
+*/
+func (p *Processor) chargeIterationCEL(cost ref.Val, condition ref.Val) ref.Val {
+	iterationCost, ok := cost.(types.Int)
+	if !ok {
+		return types.NewErr("%v expects an int cost, but got %v", CHARGE_ITERATION, cost.Type())
+	}
+	if p.charge(int64(iterationCost)) != nil {
+		return types.False
+	}
+	return condition
+}
+
+/* Create the program of a checked expression, whose comprehensions are charged for each iteration */
+func (p *Processor) program(env cel.Env, checked cel.Ast) (cel.Program, error) {
+	checkedExpr, err := cel.AstToCheckedExpr(checked)
+	if err != nil {
+		return nil, err
+	}
+	nextID := maxExprID(checkedExpr.GetExpr()) + 1
+	chargeIterations(checkedExpr.GetExpr(), &nextID)
+	return env.Program(cel.CheckedExprToAst(checkedExpr), p.getAdditionalCELFuncs())
+}
+
+/* Evaluate a program. Return the limit error if a limit was exceeded during the evaluation, as it ends comprehensions early */
+func (p *Processor) evalProgram(prg cel.Program, variables map[string]interface{}) (ref.Val, error) {
+	out, _, err := prg.Eval(variables)
+	if p.limitErr != nil {
+		return nil, p.limitErr
+	}
+	return out, err
+}
+
+/* Wrap the loop condition of each comprehension of an expression in a call to CHARGE_ITERATION.
+   The new nodes are given IDs from nextID, so that they do not match the references of the checked expression.
+*/
+func chargeIterations(expr *exprpb.Expr, nextID *int64) {
+	if expr == nil {
+		return
+	}
+	switch expr.ExprKind.(type) {
+	case *exprpb.Expr_SelectExpr:
+		chargeIterations(expr.GetSelectExpr().GetOperand(), nextID)
+	case *exprpb.Expr_CallExpr:
+		call := expr.GetCallExpr()
+		chargeIterations(call.GetTarget(), nextID)
+		for _, arg := range call.GetArgs() {
+			chargeIterations(arg, nextID)
+		}
+	case *exprpb.Expr_ListExpr:
+		for _, elem := range expr.GetListExpr().GetElements() {
+			chargeIterations(elem, nextID)
+		}
+	case *exprpb.Expr_StructExpr:
+		for _, entry := range expr.GetStructExpr().GetEntries() {
+			chargeIterations(entry.GetMapKey(), nextID)
+			chargeIterations(entry.GetValue(), nextID)
+		}
+	case *exprpb.Expr_ComprehensionExpr:
+		comprehension := expr.GetComprehensionExpr()
+		for _, sub := range []*exprpb.Expr{comprehension.GetIterRange(), comprehension.GetAccuInit(), comprehension.GetLoopCondition(),
+			comprehension.GetLoopStep(), comprehension.GetResult()} {
+			chargeIterations(sub, nextID)
+		}
+		iterationCost := expressionCost(comprehension.GetLoopCondition()) + expressionCost(comprehension.GetLoopStep())
+		costID := *nextID
+		callID := *nextID + 1
+		*nextID += 2
+		comprehension.LoopCondition = &exprpb.Expr{Id: callID, ExprKind: &exprpb.Expr_CallExpr{CallExpr: &exprpb.Expr_Call{
+			Function: CHARGE_ITERATION,
+			Args: []*exprpb.Expr{
+				{Id: costID, ExprKind: &exprpb.Expr_ConstExpr{ConstExpr: &exprpb.Constant{ConstantKind: &exprpb.Constant_Int64Value{Int64Value: iterationCost}}}},
+				comprehension.GetLoopCondition(),
+			},
+		}}}
+	}
+}
+
+/* Return the highest ID of the nodes of an expression */
+func maxExprID(expr *exprpb.Expr) int64 {
+	if expr == nil {
+		return 0
+	}
+	max := expr.GetId()
+	children := make([]*exprpb.Expr, 0)
+	switch expr.ExprKind.(type) {
+	case *exprpb.Expr_SelectExpr:
+		children = append(children, expr.GetSelectExpr().GetOperand())
+	case *exprpb.Expr_CallExpr:
+		call := expr.GetCallExpr()
+		children = append(append(children, call.GetTarget()), call.GetArgs()...)
+	case *exprpb.Expr_ListExpr:
+		children = append(children, expr.GetListExpr().GetElements()...)
+	case *exprpb.Expr_StructExpr:
+		for _, entry := range expr.GetStructExpr().GetEntries() {
+			children = append(children, entry.GetMapKey(), entry.GetValue())
+		}
+	case *exprpb.Expr_ComprehensionExpr:
+		comprehension := expr.GetComprehensionExpr()
+		children = append(children, comprehension.GetIterRange(), comprehension.GetAccuInit(), comprehension.GetLoopCondition(),
+			comprehension.GetLoopStep(), comprehension.GetResult())
+	}
+	for _, child := range children {
+		if id := maxExprID(child); id > max {
+			max = id
+		}
+	}
+	return max
+}
+
+/* Return the number of nodes in an expression. The nodes of a comprehension are counted once: its iterations are charged by CHARGE_ITERATION */
+func expressionCost(expr *exprpb.Expr) int64 {
+	if expr == nil {
+		return 0
+	}
+	cost := int64(1)
+	switch expr.ExprKind.(type) {
+	case *exprpb.Expr_SelectExpr:
+		cost += expressionCost(expr.GetSelectExpr().GetOperand())
+	case *exprpb.Expr_CallExpr:
+		call := expr.GetCallExpr()
+		cost += expressionCost(call.GetTarget())
+		for _, arg := range call.GetArgs() {
+			cost += expressionCost(arg)
+		}
+	case *exprpb.Expr_ListExpr:
+		for _, elem := range expr.GetListExpr().GetElements() {
+			cost += expressionCost(elem)
+		}
+	case *exprpb.Expr_StructExpr:
+		for _, entry := range expr.GetStructExpr().GetEntries() {
+			cost += expressionCost(entry.GetMapKey()) + expressionCost(entry.GetValue())
+		}
+	case *exprpb.Expr_ComprehensionExpr:
+		comprehension := expr.GetComprehensionExpr()
+		cost += expressionCost(comprehension.GetIterRange()) + expressionCost(comprehension.GetAccuInit()) +
+			expressionCost(comprehension.GetLoopCondition()) + expressionCost(comprehension.GetLoopStep()) +
+			expressionCost(comprehension.GetResult())
+	}
+	return cost
+}
@@ -0,0 +1,155 @@
+package eventcel
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	eventsv1alpha1 "github.com/kabanero-io/events-operator/pkg/apis/events/v1alpha1"
+	"github.com/kabanero-io/events-operator/pkg/debug"
+	"github.com/kabanero-io/events-operator/pkg/eventenv"
+	"github.com/kabanero-io/events-operator/pkg/status"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("TestLimits", func() {
+	assign := func(statement string) eventsv1alpha1.EventStatement {
+		return eventsv1alpha1.EventStatement{Assign: &statement}
+	}
+	nested := func(statements ...eventsv1alpha1.EventStatement) eventsv1alpha1.EventStatement {
+		return eventsv1alpha1.EventStatement{Body: &statements}
+	}
+	mediator := &eventsv1alpha1.EventMediator{
+		ObjectMeta: metav1.ObjectMeta{Name: "webhook", Namespace: "kabanero"},
+	}
+
+	var statusMgr *status.StatusManager
+	var processor *Processor
+	BeforeEach(func() {
+		statusMgr = status.NewStatusManager()
+		eventenv.InitEventEnv(&eventenv.EventEnv{
+			StatusMgr: statusMgr,
+			DebugMgr:  debug.NewDebugManager(),
+			Namespace: "kabanero",
+		})
+		processor = NewProcessor(nil, func(p *Processor, destination string, buf []byte, header map[string][]string) error {
+			return nil
+		})
+	})
+
+	processWithContext := func(ctx context.Context, limits *eventsv1alpha1.EventMediationLimits, body ...eventsv1alpha1.EventStatement) error {
+		mediation := &eventsv1alpha1.EventMediationImpl{Name: "webhook", Limits: limits, Body: body}
+		return processor.ProcessMessage(ctx, map[string][]string{}, map[string]interface{}{"items": []interface{}{1, 2, 3, 4, 5}}, mediator, mediation, ProcessOptions{Namespace: "kabanero"})
+	}
+
+	process := func(limits *eventsv1alpha1.EventMediationLimits, body ...eventsv1alpha1.EventStatement) error {
+		return processWithContext(context.Background(), limits, body...)
+	}
+
+	lastSummary := func() eventsv1alpha1.EventStatusSummary {
+		summaries := statusMgr.GetStatusSummary()
+		Expect(summaries).ToNot(BeEmpty())
+		return summaries[len(summaries)-1]
+	}
+
+	It("should resolve limits from the mediator and mediation", func() {
+		limits, err := resolveLimits(nil, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(limits.maxDepth).To(Equal(DEFAULT_MAX_DEPTH))
+		Expect(limits.timeout).To(Equal(DEFAULT_TIMEOUT))
+
+		limits, err = resolveLimits(&eventsv1alpha1.EventMediationLimits{MaxDepth: 4, Timeout: "5s"}, &eventsv1alpha1.EventMediationLimits{MaxDepth: 8, MaxCost: 100})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(limits.maxDepth).To(Equal(8))
+		Expect(limits.maxCost).To(Equal(int64(100)))
+		Expect(limits.maxStatements).To(Equal(DEFAULT_MAX_STATEMENTS))
+		Expect(limits.timeout).To(Equal(5 * time.Second))
+
+		_, err = resolveLimits(nil, &eventsv1alpha1.EventMediationLimits{Timeout: "soon"})
+		Expect(err).To(HaveOccurred())
+		Expect(process(&eventsv1alpha1.EventMediationLimits{Timeout: "soon"}, assign(`body.a = 1`))).ToNot(Succeed())
+		Expect(lastSummary().Message).To(ContainSubstring("Invalid limits"))
+	})
+
+	It("should count expression nodes", func() {
+		env, err := processor.initializeEmptyCELEnv()
+		Expect(err).ToNot(HaveOccurred())
+		parsed, issues := env.Parse(`1 + 2`)
+		Expect(issues == nil || issues.Err() == nil).To(BeTrue())
+		Expect(expressionCost(parsed.Expr())).To(Equal(int64(3)))
+	})
+
+	It("should enforce the maximum depth", func() {
+		body := nested(nested(nested(assign(`body.a = 1`))))
+		Expect(process(&eventsv1alpha1.EventMediationLimits{MaxDepth: 4}, body)).To(Succeed())
+		err := process(&eventsv1alpha1.EventMediationLimits{MaxDepth: 3}, body)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("depth"))
+		Expect(lastSummary().Result).To(Equal(status.RESULT_FAILED))
+		Expect(lastSummary().Message).To(ContainSubstring("maximum nesting depth of 3 exceeded"))
+	})
+
+	It("should enforce the maximum statements", func() {
+		Expect(process(&eventsv1alpha1.EventMediationLimits{MaxStatements: 2}, assign(`body.a = 1`), assign(`body.b = 2`))).To(Succeed())
+		err := process(&eventsv1alpha1.EventMediationLimits{MaxStatements: 2}, assign(`body.a = 1`), assign(`body.b = 2`), assign(`body.c = 3`))
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("statements"))
+	})
+
+	It("should charge the cost of filter for each element, and not be caught by try", func() {
+		filter := assign(`body.small = filter(body.items, " value < 3 ")`)
+		Expect(process(nil, filter)).To(Succeed())
+
+		try := eventsv1alpha1.EventStatement{Try: &[]eventsv1alpha1.EventStatement{filter}}
+		err := process(&eventsv1alpha1.EventMediationLimits{MaxCost: 15}, try)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("maximum cost of 15 exceeded"))
+		Expect(lastSummary().Message).To(ContainSubstring("maximum cost of 15 exceeded"))
+	})
+
+	It("should charge each iteration of comprehension macros", func() {
+		items := assign(`items = [1, 2, 3, 4, 5]`)
+		all := assign(`body.positive = items.all(i, i > 0)`)
+		Expect(process(nil, items, all)).To(Succeed())
+		cost := processor.cost
+		Expect(process(nil, items, assign(`body.positive = items.size() > 0`))).To(Succeed())
+		/* the condition and step of the 5 iterations cost 7 each */
+		Expect(cost - processor.cost).To(BeNumerically(">=", 35))
+
+		err := process(&eventsv1alpha1.EventMediationLimits{MaxCost: cost - 1}, items, all)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring(fmt.Sprintf("maximum cost of %v exceeded", cost-1)))
+		Expect(process(&eventsv1alpha1.EventMediationLimits{MaxCost: cost}, items, all)).To(Succeed())
+	})
+
+	It("should stop a comprehension at the timeout", func() {
+		items := make([]interface{}, 3000)
+		for index := range items {
+			items[index] = index
+		}
+		mediation := &eventsv1alpha1.EventMediationImpl{Name: "webhook", Limits: &eventsv1alpha1.EventMediationLimits{Timeout: "100ms", MaxCost: 1 << 40},
+			Body: []eventsv1alpha1.EventStatement{assign(`items = body.items`), assign(`body.pairs = items.map(i, items.filter(j, j < i).size()).size()`)}}
+		start := time.Now()
+		err := processor.ProcessMessage(context.Background(), map[string][]string{}, map[string]interface{}{"items": items}, mediator, mediation, ProcessOptions{Namespace: "kabanero"})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("timeout of 100ms exceeded"))
+		Expect(time.Since(start)).To(BeNumerically("<", 5*time.Second))
+	})
+
+	It("should stop at the timeout", func() {
+		err := process(&eventsv1alpha1.EventMediationLimits{Timeout: "1ns"}, assign(`body.a = 1`))
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("timeout"))
+	})
+
+	It("should stop when the event is cancelled", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		err := processWithContext(ctx, nil, assign(`body.a = 1`))
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("evaluation cancelled"))
+		Expect(processor.Context().Err()).To(HaveOccurred())
+	})
+})
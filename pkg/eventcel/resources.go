@@ -0,0 +1,182 @@
+/*
+Copyright 2020 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package eventcel
+
+/* Implementation of the read-only Kubernetes lookup functions getResource and listResources */
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	eventsv1alpha1 "github.com/kabanero-io/events-operator/pkg/apis/events/v1alpha1"
+	"github.com/kabanero-io/events-operator/pkg/eventenv"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	KIND_ALL    = "*"
+	KIND_SECRET = "Secret"
+)
+
+/* Return true if the kind of resource may be read according to the allowlist. Secrets must be allowed explicitly */
+func resourceAllowed(allowed *[]eventsv1alpha1.EventAllowedResource, apiVersion string, kind string) bool {
+	if allowed == nil {
+		return false
+	}
+	for _, resource := range *allowed {
+		if resource.APIVersion != apiVersion {
+			continue
+		}
+		if resource.Kind == kind {
+			return true
+		}
+		if resource.Kind == KIND_ALL && kind != KIND_SECRET {
+			return true
+		}
+	}
+	return false
+}
+
+/* Get a resource through the reader.
+   Return the resource as a map, or nil if it does not exist
+*/
+func getResource(ctx context.Context, reader client.Reader, allowed *[]eventsv1alpha1.EventAllowedResource, apiVersion string, kind string, namespace string, name string) (map[string]interface{}, error) {
+	if !resourceAllowed(allowed, apiVersion, kind) {
+		return nil, fmt.Errorf("reading resources of apiVersion %v kind %v is not allowed by the mediator", apiVersion, kind)
+	}
+	gv, err := schema.ParseGroupVersion(apiVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(gv.WithKind(kind))
+	err = reader.Get(ctx, k8stypes.NamespacedName{Namespace: namespace, Name: name}, obj)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return obj.Object, nil
+}
+
+/* List resources through the reader. The label selector, if not empty, uses the same syntax as kubectl */
+func listResources(ctx context.Context, reader client.Reader, allowed *[]eventsv1alpha1.EventAllowedResource, apiVersion string, kind string, namespace string, labelSelector string) ([]interface{}, error) {
+	if !resourceAllowed(allowed, apiVersion, kind) {
+		return nil, fmt.Errorf("reading resources of apiVersion %v kind %v is not allowed by the mediator", apiVersion, kind)
+	}
+	gv, err := schema.ParseGroupVersion(apiVersion)
+	if err != nil {
+		return nil, err
+	}
+	selector, err := labels.Parse(labelSelector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid label selector %v: %v", labelSelector, err)
+	}
+
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(gv.WithKind(kind + "List"))
+	err = reader.List(ctx, list, client.InNamespace(namespace), client.MatchingLabelsSelector{Selector: selector})
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]interface{}, 0, len(list.Items))
+	for _, item := range list.Items {
+		ret = append(ret, item.Object)
+	}
+	return ret, nil
+}
+
+/* Get the reader for lookups, and the allowlist of the mediator.
+   Lookups read from the API server: the cache of the mediator only holds its namespace, and would watch every kind looked up
+*/
+func (p *Processor) getResourceReader() (client.Reader, *[]eventsv1alpha1.EventAllowedResource, error) {
+	if p.mediator == nil {
+		return nil, nil, fmt.Errorf("no mediator")
+	}
+	/* TODO: avoid using global */
+	env := eventenv.GetEventEnv()
+	if env == nil || env.APIReader == nil {
+		return nil, nil, fmt.Errorf("API reader is not available")
+	}
+	return env.APIReader, p.mediator.Spec.AllowedResources, nil
+}
+
+/* Convert the four string parameters of getResource and listResources */
+func valsToStrings(function string, values []ref.Val) ([]string, error) {
+	if len(values) != 4 {
+		return nil, fmt.Errorf("%v: expecting 4 parameters but got %v", function, len(values))
+	}
+	ret := make([]string, 0, len(values))
+	for _, val := range values {
+		str, err := valToString(val)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %v", function, err)
+		}
+		ret = append(ret, str)
+	}
+	return ret, nil
+}
+
+/* implementation of getResource(apiVersion, kind, namespace, name) for CEL. Return null if the resource does not exist */
+func (p *Processor) getResourceCEL(values ...ref.Val) ref.Val {
+	params, err := valsToStrings("getResource", values)
+	if err != nil {
+		return types.NewErr("%v", err)
+	}
+	reader, allowed, err := p.getResourceReader()
+	if err != nil {
+		return types.NewErr("getResource: %v", err)
+	}
+	obj, err := getResource(p.Context(), reader, allowed, params[0], params[1], params[2], params[3])
+	if err != nil {
+		klog.Errorf("getResource %v error: %v", params, err)
+		return types.NewErr("getResource: %v", err)
+	}
+	if obj == nil {
+		return types.NullValue
+	}
+	return types.NewDynamicMap(types.DefaultTypeAdapter, obj)
+}
+
+/* implementation of listResources(apiVersion, kind, namespace, labelSelector) for CEL */
+func (p *Processor) listResourcesCEL(values ...ref.Val) ref.Val {
+	params, err := valsToStrings("listResources", values)
+	if err != nil {
+		return types.NewErr("%v", err)
+	}
+	reader, allowed, err := p.getResourceReader()
+	if err != nil {
+		return types.NewErr("listResources: %v", err)
+	}
+	items, err := listResources(p.Context(), reader, allowed, params[0], params[1], params[2], params[3])
+	if err != nil {
+		klog.Errorf("listResources %v error: %v", params, err)
+		return types.NewErr("listResources: %v", err)
+	}
+	return types.NewDynamicList(types.DefaultTypeAdapter, items)
+}
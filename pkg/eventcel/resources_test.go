@@ -0,0 +1,111 @@
+package eventcel
+
+import (
+	"context"
+
+	eventsv1alpha1 "github.com/kabanero-io/events-operator/pkg/apis/events/v1alpha1"
+	"github.com/kabanero-io/events-operator/pkg/debug"
+	"github.com/kabanero-io/events-operator/pkg/eventenv"
+	"github.com/kabanero-io/events-operator/pkg/status"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("TestResourceLookup", func() {
+	teamA := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "team-a",
+			Namespace: "kabanero",
+			Labels:    map[string]string{"team": "a"},
+		},
+		Data: map[string]string{"pipelineNamespace": "team-a-pipelines"},
+	}
+	teamB := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "team-b",
+			Namespace: "kabanero",
+			Labels:    map[string]string{"team": "b"},
+		},
+		Data: map[string]string{"pipelineNamespace": "team-b-pipelines"},
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "token",
+			Namespace: "kabanero",
+		},
+	}
+	reader := fake.NewFakeClient(teamA, teamB, secret)
+
+	allowAll := &[]eventsv1alpha1.EventAllowedResource{{APIVersion: "v1", Kind: "*"}}
+	allowSecrets := &[]eventsv1alpha1.EventAllowedResource{{APIVersion: "v1", Kind: "ConfigMap"}, {APIVersion: "v1", Kind: "Secret"}}
+
+	It("should only allow kinds in the allowlist", func() {
+		Expect(resourceAllowed(nil, "v1", "ConfigMap")).To(BeFalse())
+		Expect(resourceAllowed(allowAll, "v1", "ConfigMap")).To(BeTrue())
+		Expect(resourceAllowed(allowAll, "apps/v1", "Deployment")).To(BeFalse())
+		Expect(resourceAllowed(allowAll, "v1", "Secret")).To(BeFalse())
+		Expect(resourceAllowed(allowSecrets, "v1", "Secret")).To(BeTrue())
+		Expect(resourceAllowed(allowSecrets, "v1", "Namespace")).To(BeFalse())
+	})
+
+	It("should get a resource", func() {
+		obj, err := getResource(context.Background(), reader, allowAll, "v1", "ConfigMap", "kabanero", "team-a")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(obj["data"]).To(HaveKeyWithValue("pipelineNamespace", "team-a-pipelines"))
+	})
+
+	It("should return nil for a missing resource", func() {
+		obj, err := getResource(context.Background(), reader, allowAll, "v1", "ConfigMap", "kabanero", "team-c")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(obj).To(BeNil())
+	})
+
+	It("should not get a Secret unless explicitly allowed", func() {
+		_, err := getResource(context.Background(), reader, allowAll, "v1", "Secret", "kabanero", "token")
+		Expect(err).To(HaveOccurred())
+		obj, err := getResource(context.Background(), reader, allowSecrets, "v1", "Secret", "kabanero", "token")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(obj).ToNot(BeNil())
+	})
+
+	It("should list resources with a label selector", func() {
+		items, err := listResources(context.Background(), reader, allowAll, "v1", "ConfigMap", "kabanero", "")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(items).To(HaveLen(2))
+
+		items, err = listResources(context.Background(), reader, allowAll, "v1", "ConfigMap", "kabanero", "team=b")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(items).To(HaveLen(1))
+		Expect(items[0].(map[string]interface{})["data"]).To(HaveKeyWithValue("pipelineNamespace", "team-b-pipelines"))
+
+		_, err = listResources(context.Background(), reader, allowAll, "v1", "ConfigMap", "kabanero", "team in (")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should read resources of other namespaces from the API server, not the cache of the mediator", func() {
+		other := teamA.DeepCopy()
+		other.Namespace = "team-a"
+		eventenv.InitEventEnv(&eventenv.EventEnv{
+			StatusMgr: status.NewStatusManager(),
+			DebugMgr:  debug.NewDebugManager(),
+			Namespace: "kabanero",
+			Cache:     fake.NewFakeClient(teamA),
+			APIReader: fake.NewFakeClient(other),
+		})
+		statement := `body.pipelineNamespace = getResource("v1", "ConfigMap", "team-a", "team-a").data.pipelineNamespace`
+		mediator := &eventsv1alpha1.EventMediator{
+			ObjectMeta: metav1.ObjectMeta{Name: "webhook", Namespace: "kabanero"},
+			Spec:       eventsv1alpha1.EventMediatorSpec{AllowedResources: allowAll},
+		}
+		mediation := &eventsv1alpha1.EventMediationImpl{Name: "webhook", Body: []eventsv1alpha1.EventStatement{{Assign: &statement}}}
+		body := map[string]interface{}{}
+		processor := NewProcessor(nil, func(p *Processor, destination string, buf []byte, header map[string][]string) error {
+			return nil
+		})
+		Expect(processor.ProcessMessage(context.Background(), map[string][]string{}, body, mediator, mediation, ProcessOptions{Namespace: "kabanero"})).To(Succeed())
+		Expect(body["pipelineNamespace"]).To(Equal("team-a-pipelines"))
+	})
+})
@@ -0,0 +1,71 @@
+/*
+Copyright 2020 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package eventcel
+
+/* Evaluation of the condition of a mediation selector. The condition is evaluated against the header and body
+   of the event only, before any repository file is downloaded, so that events that do not match are
+   not sent to the GitHub API.
+*/
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/checker/decls"
+	eventsv1alpha1 "github.com/kabanero-io/events-operator/pkg/apis/events/v1alpha1"
+)
+
+/* MatchCondition returns the value of the condition of a selector for an event.
+   The built-in functions are available, except those that send events or modify resources.
+*/
+func MatchCondition(ctx context.Context, mediator *eventsv1alpha1.EventMediator, header map[string][]string, body map[string]interface{}, condition string) (bool, error) {
+	p := NewProcessor(nil, func(p *Processor, destination string, buf []byte, header map[string][]string) error {
+		return fmt.Errorf("sendEvent can not be called from a selector condition")
+	})
+	/* no client, so that resources can not be applied */
+	p.mediator = mediator
+	p.namespace = mediator.Namespace
+
+	limits, err := resolveLimits(mediator.Spec.Limits, nil)
+	if err != nil {
+		return false, err
+	}
+	defer p.startLimits(ctx, limits)()
+
+	env, err := p.initializeEmptyCELEnv()
+	if err != nil {
+		return false, err
+	}
+	env, err = env.Extend(cel.Declarations(
+		decls.NewIdent(HEADER, decls.NewMapType(decls.String, decls.Any), nil),
+		decls.NewIdent(BODY, decls.NewMapType(decls.String, decls.Any), nil)))
+	if err != nil {
+		return false, err
+	}
+	if body == nil {
+		body = make(map[string]interface{})
+	}
+	if header == nil {
+		header = make(map[string][]string)
+	}
+	variables := map[string]interface{}{
+		HEADER: header,
+		BODY:   body,
+	}
+	return p.evalCondition(env, condition, variables)
+}
@@ -0,0 +1,44 @@
+package eventcel
+
+import (
+	"context"
+
+	eventsv1alpha1 "github.com/kabanero-io/events-operator/pkg/apis/events/v1alpha1"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("TestSelectorCondition", func() {
+	mediator := &eventsv1alpha1.EventMediator{
+		ObjectMeta: metav1.ObjectMeta{Name: "webhook", Namespace: "kabanero"},
+	}
+	header := map[string][]string{"X-Github-Event": {"push"}}
+	body := map[string]interface{}{
+		"ref":    "refs/heads/master",
+		"sender": map[string]interface{}{"login": "octocat"},
+	}
+
+	It("should evaluate conditions on the header and body", func() {
+		matches, err := MatchCondition(context.Background(), mediator, header, body, `header["X-Github-Event"][0] == "push" && body.ref.endsWith("/master")`)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(matches).To(BeTrue())
+
+		matches, err = MatchCondition(context.Background(), mediator, header, body, `body.sender.login != "octocat"`)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(matches).To(BeFalse())
+
+		matches, err = MatchCondition(context.Background(), mediator, header, body, `has(body.pull_request) && body.pull_request.merged`)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(matches).To(BeFalse())
+	})
+
+	It("should return an error for conditions that can not be evaluated", func() {
+		_, err := MatchCondition(context.Background(), mediator, header, body, `body.pull_request.merged`)
+		Expect(err).To(HaveOccurred())
+		_, err = MatchCondition(context.Background(), mediator, header, body, `body.ref`)
+		Expect(err).To(HaveOccurred())
+		_, err = MatchCondition(context.Background(), mediator, header, body, `sendEvent("dest", body, header) == ""`)
+		Expect(err).To(HaveOccurred())
+	})
+})
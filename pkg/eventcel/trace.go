@@ -0,0 +1,161 @@
+/*
+Copyright 2020 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package eventcel
+
+/* Recording of the step-by-step evaluation of a mediation */
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+	"github.com/kabanero-io/events-operator/pkg/debug"
+	"github.com/kabanero-io/events-operator/pkg/eventenv"
+	"k8s.io/klog"
+)
+
+/* Return true if the request asks for its processing to be traced */
+func traceRequested(header map[string][]string) bool {
+	for key, values := range header {
+		if !strings.EqualFold(key, debug.TRACE_HEADER) || len(values) == 0 {
+			continue
+		}
+		enabled, err := strconv.ParseBool(values[0])
+		return err == nil && enabled
+	}
+	return false
+}
+
+/* Return the trace of the last message processed, or nil if it was not traced */
+func (p *Processor) GetTrace() *debug.Trace {
+	return p.trace
+}
+
+/* Save the trace, if any, to be retrieved from the debug endpoint */
+func (p *Processor) saveTrace(err error) {
+	if p.trace == nil {
+		return
+	}
+	if err != nil {
+		p.trace.Error = err.Error()
+	}
+	/* TODO: avoid using global */
+	env := eventenv.GetEventEnv()
+	if env == nil || env.DebugMgr == nil {
+		klog.Infof("saveTrace: no debug manager to save the trace of mediation %v", p.trace.Mediation)
+		return
+	}
+	env.DebugMgr.AddTrace(p.trace)
+}
+
+/* Record a statement that is visited */
+func (p *Processor) traceStatement(depth int, statement string, expression string) {
+	if p.trace == nil {
+		return
+	}
+	p.trace.AddStep(debug.TraceStep{Depth: depth, Statement: statement, Expression: expression})
+}
+
+/* Record the result of the condition of an if */
+func (p *Processor) traceCondition(depth int, condition string, result bool, err error) {
+	if p.trace == nil {
+		return
+	}
+	step := debug.TraceStep{Depth: depth, Statement: IF, Expression: condition}
+	if err != nil {
+		step.Error = err.Error()
+	} else {
+		step.Result = &result
+	}
+	p.trace.AddStep(step)
+}
+
+/* Record an error caught by a try, or the message of a fail */
+func (p *Processor) traceError(depth int, statement string, err error) {
+	if p.trace == nil {
+		return
+	}
+	p.trace.AddStep(debug.TraceStep{Depth: depth, Statement: statement, Error: err.Error()})
+}
+
+/* Record a sendEvent made by the statement being evaluated */
+func (p *Processor) traceSend(destination string, err error) {
+	if p.trace == nil {
+		return
+	}
+	step := debug.TraceStep{Depth: p.traceDepth + 1, Statement: SENDEVENT, Destination: destination}
+	if err != nil {
+		step.Error = err.Error()
+	}
+	p.trace.AddStep(step)
+}
+
+/* Find the current value of a variable, which may be of the form a.b.c */
+func lookupVariable(variables map[string]interface{}, name string) (interface{}, bool) {
+	var value interface{} = variables
+	for _, component := range strings.Split(name, ".") {
+		mapValue, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		value, ok = mapValue[component]
+		if !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+/* Return a copy of the value of a variable for the trace */
+func traceValue(variables map[string]interface{}, name string) interface{} {
+	value, ok := lookupVariable(variables, name)
+	if !ok {
+		return nil
+	}
+	converted, err := toJSONCompatibleValue(value)
+	if err != nil {
+		return nil
+	}
+	return converted
+}
+
+/* Set a variable, recording the old and new values in the trace */
+func (p *Processor) setOneVariableTraced(env cel.Env, name string, val string, varType string, variables map[string]interface{}, depth int, statement string, expression string) (cel.Env, error) {
+	if p.trace == nil {
+		return p.setOneTypedVariable(env, name, val, varType, variables)
+	}
+
+	/* add the step first, so that it precedes the steps of functions called by the expression */
+	index := len(p.trace.Steps)
+	p.trace.AddStep(debug.TraceStep{Depth: depth, Statement: statement, Expression: expression, Variable: name})
+	var oldValue interface{}
+	if name != "" {
+		oldValue = p.traceVariable(variables, name)
+	}
+
+	p.traceDepth = depth
+	env, err := p.setOneTypedVariable(env, name, val, varType, variables)
+
+	step := &p.trace.Steps[index]
+	step.OldValue = oldValue
+	if err != nil {
+		step.Error = err.Error()
+	} else if name != "" {
+		step.NewValue = p.traceVariable(variables, name)
+	}
+	return env, err
+}
@@ -0,0 +1,112 @@
+package eventcel
+
+import (
+	"context"
+
+	eventsv1alpha1 "github.com/kabanero-io/events-operator/pkg/apis/events/v1alpha1"
+	"github.com/kabanero-io/events-operator/pkg/debug"
+	"github.com/kabanero-io/events-operator/pkg/event"
+	"github.com/kabanero-io/events-operator/pkg/eventenv"
+	"github.com/kabanero-io/events-operator/pkg/status"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("TestTrace", func() {
+	assign := func(statement string) eventsv1alpha1.EventStatement {
+		return eventsv1alpha1.EventStatement{Assign: &statement}
+	}
+	condition := "body.ref == \"refs/heads/master\""
+	mediator := &eventsv1alpha1.EventMediator{
+		ObjectMeta: metav1.ObjectMeta{Name: "webhook", Namespace: "kabanero"},
+	}
+	mediation := &eventsv1alpha1.EventMediationImpl{
+		Name:   "webhook",
+		SendTo: []string{"dest"},
+		Body: []eventsv1alpha1.EventStatement{
+			assign(`body.branch = "unknown"`),
+			{If: &condition, Body: &[]eventsv1alpha1.EventStatement{
+				assign(`body.branch = "master"`),
+				assign(`sendEvent(dest, body, header)`),
+			}},
+		},
+	}
+	header := map[string][]string{}
+	var body map[string]interface{}
+	var debugMgr *debug.DebugManager
+	var sent []string
+	var processor *Processor
+	BeforeEach(func() {
+		debugMgr = debug.NewDebugManager()
+		eventenv.InitEventEnv(&eventenv.EventEnv{
+			StatusMgr: status.NewStatusManager(),
+			DebugMgr:  debugMgr,
+			Namespace: "kabanero",
+		})
+		/* assignments modify the body */
+		body = map[string]interface{}{"ref": "refs/heads/master"}
+		sent = make([]string, 0)
+		processor = NewProcessor(nil, func(p *Processor, destination string, buf []byte, header map[string][]string) error {
+			sent = append(sent, destination)
+			return nil
+		})
+	})
+
+	It("should not trace unless requested", func() {
+		Expect(processor.ProcessMessage(context.Background(), header, body, mediator, mediation, ProcessOptions{Namespace: "kabanero"})).To(Succeed())
+		Expect(processor.GetTrace()).To(BeNil())
+		Expect(debugMgr.GetTraces()).To(BeEmpty())
+		Expect(sent).To(Equal([]string{"dest"}))
+	})
+
+	It("should trace statements, conditions, assignments, and sends", func() {
+		traceHeader := map[string][]string{debug.TRACE_HEADER: {"true"}}
+		ctx := event.WithDeliveryID(context.Background(), "72d3162e-cc78-11e3-81ab-4c9367dc0958")
+		Expect(processor.ProcessMessage(ctx, traceHeader, body, mediator, mediation, ProcessOptions{Namespace: "kabanero"})).To(Succeed())
+		traces := debugMgr.GetTraces()
+		Expect(traces).To(HaveLen(1))
+		Expect(traces[0].DeliveryID).To(Equal("72d3162e-cc78-11e3-81ab-4c9367dc0958"))
+		steps := traces[0].Steps
+		Expect(steps).To(HaveLen(5))
+
+		Expect(steps[0].Statement).To(Equal(ASSIGN))
+		Expect(steps[0].Depth).To(Equal(1))
+		Expect(steps[0].Variable).To(Equal("body.branch"))
+		Expect(steps[0].OldValue).To(BeNil())
+		Expect(steps[0].NewValue).To(Equal("unknown"))
+
+		Expect(steps[1].Statement).To(Equal(IF))
+		Expect(steps[1].Depth).To(Equal(1))
+		Expect(*steps[1].Result).To(BeTrue())
+
+		Expect(steps[2].Depth).To(Equal(2))
+		Expect(steps[2].OldValue).To(Equal("unknown"))
+		Expect(steps[2].NewValue).To(Equal("master"))
+
+		Expect(steps[3].Statement).To(Equal(ASSIGN))
+		Expect(steps[3].Variable).To(Equal(""))
+		Expect(steps[4].Statement).To(Equal(SENDEVENT))
+		Expect(steps[4].Destination).To(Equal("dest"))
+		Expect(steps[4].Depth).To(Equal(3))
+	})
+
+	It("should trace when enabled for the mediation", func() {
+		tracedMediation := mediation.DeepCopy()
+		tracedMediation.Trace = true
+		tracedMediation.Body = append(tracedMediation.Body, eventsv1alpha1.EventStatement{Fail: &condition})
+		Expect(processor.ProcessMessage(context.Background(), header, body, mediator, tracedMediation, ProcessOptions{Namespace: "kabanero"})).ToNot(Succeed())
+		traces := debugMgr.GetTraces()
+		Expect(traces).To(HaveLen(1))
+		Expect(traces[0].Error).ToNot(BeEmpty())
+		steps := traces[0].Steps
+		Expect(steps[len(steps)-1].Statement).To(Equal(FAIL))
+	})
+
+	It("should parse the trace header", func() {
+		Expect(traceRequested(map[string][]string{"X-Events-Trace": {"true"}})).To(BeTrue())
+		Expect(traceRequested(map[string][]string{"x-events-trace": {"1"}})).To(BeTrue())
+		Expect(traceRequested(map[string][]string{"X-Events-Trace": {"no"}})).To(BeFalse())
+		Expect(traceRequested(map[string][]string{})).To(BeFalse())
+	})
+})
@@ -0,0 +1,144 @@
+package eventcel
+
+import (
+	"context"
+	"fmt"
+
+	eventsv1alpha1 "github.com/kabanero-io/events-operator/pkg/apis/events/v1alpha1"
+	"github.com/kabanero-io/events-operator/pkg/debug"
+	"github.com/kabanero-io/events-operator/pkg/eventenv"
+	"github.com/kabanero-io/events-operator/pkg/status"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("TestTry", func() {
+	assign := func(statement string) eventsv1alpha1.EventStatement {
+		return eventsv1alpha1.EventStatement{Assign: &statement}
+	}
+	nested := func(statements ...eventsv1alpha1.EventStatement) eventsv1alpha1.EventStatement {
+		return eventsv1alpha1.EventStatement{Body: &statements}
+	}
+	try := func(onError []eventsv1alpha1.EventStatement, statements ...eventsv1alpha1.EventStatement) eventsv1alpha1.EventStatement {
+		ret := eventsv1alpha1.EventStatement{Try: &statements}
+		if onError != nil {
+			ret.OnError = &onError
+		}
+		return ret
+	}
+	fail := func(message string) eventsv1alpha1.EventStatement {
+		return eventsv1alpha1.EventStatement{Fail: &message}
+	}
+	mediator := &eventsv1alpha1.EventMediator{
+		ObjectMeta: metav1.ObjectMeta{Name: "webhook", Namespace: "kabanero"},
+	}
+
+	var statusMgr *status.StatusManager
+	var body map[string]interface{}
+	BeforeEach(func() {
+		statusMgr = status.NewStatusManager()
+		eventenv.InitEventEnv(&eventenv.EventEnv{
+			StatusMgr: statusMgr,
+			DebugMgr:  debug.NewDebugManager(),
+			Namespace: "kabanero",
+		})
+		/* assignments modify the body */
+		body = map[string]interface{}{"ref": "refs/heads/master"}
+	})
+
+	process := func(statements ...eventsv1alpha1.EventStatement) error {
+		processor := NewProcessor(nil, func(p *Processor, destination string, buf []byte, header map[string][]string) error {
+			return nil
+		})
+		mediation := &eventsv1alpha1.EventMediationImpl{Name: "webhook", Body: statements}
+		return processor.ProcessMessage(context.Background(), map[string][]string{}, body, mediator, mediation, ProcessOptions{Namespace: "kabanero"})
+	}
+
+	It("should ignore errors of a try without onError, and keep the variables assigned before the error", func() {
+		Expect(process(
+			try(nil, assign(`count = 1`), assign(`body.missing = body.none.value`), assign(`count = 2`)),
+			assign(`total = count + 10`),
+			assign(`body.count = total`),
+		)).To(Succeed())
+		Expect(body["count"]).To(Equal(int64(11)))
+		Expect(body).ToNot(HaveKey("missing"))
+	})
+
+	It("should set the error variable for onError", func() {
+		Expect(process(
+			try([]eventsv1alpha1.EventStatement{
+				assign(`body.message = error.message`),
+				assign(`body.statement = error.statement`),
+				assign(`body.label = label + "-failed"`),
+			}, assign(`label = "build"`), assign(`body.missing = body.none.value`)),
+			assign(`body.after = label + "-" + error.statement`),
+		)).To(Succeed())
+		Expect(body["message"]).To(ContainSubstring("none"))
+		Expect(body["statement"]).To(Equal(`body.missing = body.none.value`))
+		Expect(body["label"]).To(Equal("build-failed"))
+		Expect(body["after"]).To(Equal(`build-body.missing = body.none.value`))
+	})
+
+	It("should declare the error variable for an outer onError after an inner one", func() {
+		Expect(process(
+			try([]eventsv1alpha1.EventStatement{assign(`body.outer = error.statement`)},
+				nested(try([]eventsv1alpha1.EventStatement{assign(`body.inner = error.statement`)}, assign(`body.a = body.none.a`))),
+				assign(`body.b = body.none.b`),
+			),
+		)).To(Succeed())
+		Expect(body["inner"]).To(Equal(`body.a = body.none.a`))
+		Expect(body["outer"]).To(Equal(`body.b = body.none.b`))
+	})
+
+	It("should catch errors of onError in an outer try", func() {
+		Expect(process(
+			try([]eventsv1alpha1.EventStatement{assign(`body.outer = error.statement`)},
+				try([]eventsv1alpha1.EventStatement{assign(`body.c = body.none.c`)}, assign(`body.a = body.none.a`)),
+			),
+		)).To(Succeed())
+		Expect(body["outer"]).To(Equal(`body.c = body.none.c`))
+	})
+
+	It("should not catch fail", func() {
+		err := process(
+			try([]eventsv1alpha1.EventStatement{assign(`body.caught = true`)}, assign(`branch = "master"`), fail(`"unsupported branch " + branch`)),
+			assign(`body.after = true`),
+		)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("unsupported branch master"))
+		Expect(body).ToNot(HaveKey("caught"))
+		Expect(body).ToNot(HaveKey("after"))
+
+		summaries := statusMgr.GetStatusSummary()
+		Expect(summaries).ToNot(BeEmpty())
+		Expect(summaries[len(summaries)-1].Result).To(Equal(status.RESULT_FAILED))
+		Expect(summaries[len(summaries)-1].Message).To(ContainSubstring("unsupported branch master"))
+	})
+
+	It("should tell the send handler whether a try is being evaluated", func() {
+		sendErr := fmt.Errorf("connection refused")
+		inTry := make([]bool, 0)
+		handler := func(p *Processor, destination string, buf []byte, header map[string][]string) error {
+			inTry = append(inTry, p.InTry())
+			if p.InTry() {
+				return sendErr
+			}
+			return nil
+		}
+		processor := NewProcessor(nil, handler)
+		mediation := &eventsv1alpha1.EventMediationImpl{Name: "webhook", Body: []eventsv1alpha1.EventStatement{
+			assign(`dest = "listener"`),
+			assign(`body.result = sendEvent(dest, body, header)`),
+			try([]eventsv1alpha1.EventStatement{assign(`body.failed = error.destination`), assign(`sendEvent(dest, body, header)`)},
+				assign(`sendEvent(dest, body, header)`),
+				assign(`body.sent = true`),
+			),
+		}}
+		Expect(processor.ProcessMessage(context.Background(), map[string][]string{}, body, mediator, mediation, ProcessOptions{Namespace: "kabanero"})).To(Succeed())
+		Expect(inTry).To(Equal([]bool{false, true, false}))
+		Expect(body["result"]).To(Equal(""))
+		Expect(body["failed"]).To(Equal("listener"))
+		Expect(body).ToNot(HaveKey("sent"))
+	})
+})
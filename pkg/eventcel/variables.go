@@ -0,0 +1,381 @@
+/*
+Copyright 2020 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package eventcel
+
+/* Variables declared in the variables section of a mediator or mediation.
+   The value of a variable is a string, a CEL expression, or read from a Secret, ConfigMap, or environment variable.
+   Secrets and ConfigMaps are read through the cache of the worker, which watches them, so that changes take
+   effect for the next event without restarting the worker.
+*/
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	eventsv1alpha1 "github.com/kabanero-io/events-operator/pkg/apis/events/v1alpha1"
+	"github.com/kabanero-io/events-operator/pkg/debug"
+	"github.com/kabanero-io/events-operator/pkg/eventenv"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog"
+	exprpb "google.golang.org/genproto/googleapis/api/expr/v1alpha1"
+	sigsyaml "sigs.k8s.io/yaml"
+)
+
+const (
+	REDACTED = "<redacted>" // value of a variable read from a Secret, as shown in traces
+)
+
+/* Error returned when the value of a variable is not of its declared type. The default is not used for it */
+type variableTypeError struct {
+	message string
+}
+
+func (te *variableTypeError) Error() string {
+	return te.message
+}
+
+/* Return an error if the value is not of the declared type. Any type is accepted if varType is "" */
+func checkVariableType(name string, varType string, out ref.Val) error {
+	if varType == "" || out.Type().TypeName() == varType {
+		return nil
+	}
+	return &variableTypeError{message: fmt.Sprintf("variable %v is declared as %v, but the value is of type %v", name, varType, out.Type().TypeName())}
+}
+
+/* Return true if varType may be declared for a variable */
+func validVariableType(varType string) bool {
+	switch varType {
+	case "", TYPEINT, TYPEBOOL, TYPELIST, TYPEMAP, TYPESTRING:
+		return true
+	}
+	return false
+}
+
+/* Convert a string to the declared type. Lists and maps are parsed as YAML or JSON */
+func convertVariableValue(name string, str string, varType string) (ref.Val, error) {
+	var value interface{}
+	var err error
+	switch varType {
+	case "", TYPESTRING:
+		value = str
+	case TYPEINT:
+		value, err = strconv.ParseInt(strings.TrimSpace(str), 10, 64)
+	case TYPEBOOL:
+		value, err = strconv.ParseBool(strings.TrimSpace(str))
+	case TYPELIST, TYPEMAP:
+		err = sigsyaml.Unmarshal([]byte(str), &value)
+	}
+	if err != nil {
+		/* the value itself is not included, as it may be a secret */
+		return nil, &variableTypeError{message: fmt.Sprintf("variable %v is declared as %v, but its value can not be converted: %v", name, varType, err)}
+	}
+	out := types.DefaultTypeAdapter.NativeToValue(value)
+	if types.IsError(out) {
+		return nil, fmt.Errorf("unable to convert the value of variable %v: %v", name, out)
+	}
+	return out, checkVariableType(name, varType, out)
+}
+
+/* Read the value of a variable from its source. Return the value, and whether it exists */
+func (p *Processor) readVariableSource(source *eventsv1alpha1.EventVariableSource) (string, bool, error) {
+	count := 0
+	if source.SecretKeyRef != nil {
+		count++
+	}
+	if source.ConfigMapKeyRef != nil {
+		count++
+	}
+	if source.Env != "" {
+		count++
+	}
+	if count != 1 {
+		return "", false, fmt.Errorf("valueFrom must specify exactly one of secretKeyRef, configMapKeyRef, or env")
+	}
+
+	if source.Env != "" {
+		value, found := os.LookupEnv(source.Env)
+		return value, found, nil
+	}
+
+	env := eventenv.GetEventEnv()
+	if env == nil || env.Cache == nil {
+		return "", false, fmt.Errorf("resource cache is not available")
+	}
+	if source.SecretKeyRef != nil {
+		secret := &corev1.Secret{}
+		err := env.Cache.Get(p.Context(), k8stypes.NamespacedName{Namespace: p.namespace, Name: source.SecretKeyRef.Name}, secret)
+		if err != nil {
+			if errors.IsNotFound(err) {
+				return "", false, nil
+			}
+			return "", false, err
+		}
+		if value, ok := secret.Data[source.SecretKeyRef.Key]; ok {
+			return string(value), true, nil
+		}
+		value, ok := secret.StringData[source.SecretKeyRef.Key]
+		return value, ok, nil
+	}
+
+	configMap := &corev1.ConfigMap{}
+	err := env.Cache.Get(p.Context(), k8stypes.NamespacedName{Namespace: p.namespace, Name: source.ConfigMapKeyRef.Name}, configMap)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+	if value, ok := configMap.Data[source.ConfigMapKeyRef.Key]; ok {
+		return value, true, nil
+	}
+	value, ok := configMap.BinaryData[source.ConfigMapKeyRef.Key]
+	return string(value), ok, nil
+}
+
+/* Return a description of the source of a variable, for traces and error messages */
+func describeVariableSource(source *eventsv1alpha1.EventVariableSource) string {
+	if source.SecretKeyRef != nil {
+		return fmt.Sprintf("secretKeyRef %v/%v", source.SecretKeyRef.Name, source.SecretKeyRef.Key)
+	}
+	if source.ConfigMapKeyRef != nil {
+		return fmt.Sprintf("configMapKeyRef %v/%v", source.ConfigMapKeyRef.Name, source.ConfigMapKeyRef.Key)
+	}
+	return fmt.Sprintf("env %v", source.Env)
+}
+
+/* Return true if the source is optional, so that the variable is not set when the source does not exist */
+func optionalVariableSource(source *eventsv1alpha1.EventVariableSource) bool {
+	if source.SecretKeyRef != nil && source.SecretKeyRef.Optional != nil {
+		return *source.SecretKeyRef.Optional
+	}
+	if source.ConfigMapKeyRef != nil && source.ConfigMapKeyRef.Optional != nil {
+		return *source.ConfigMapKeyRef.Optional
+	}
+	return false
+}
+
+/* Return true if the variable, of the form a.b.c, holds a value read from a Secret or computed from one */
+func (p *Processor) isSecretVariable(name string) bool {
+	for secret := range p.secretVariables {
+		if name == secret || strings.HasPrefix(name, secret+".") {
+			return true
+		}
+	}
+	return false
+}
+
+/* Record whether the value assigned to a variable is read from a Secret or computed from one */
+func (p *Processor) setSecretVariable(name string, secret bool) {
+	if secret {
+		p.secretVariables[name] = true
+	} else {
+		delete(p.secretVariables, name)
+	}
+}
+
+/* Return true if an expression references a variable that holds a value of a Secret, or a map containing one */
+func (p *Processor) referencesSecret(expr *exprpb.Expr) bool {
+	for _, path := range referencedVariables(expr, nil) {
+		if p.isSecretVariable(path) {
+			return true
+		}
+		for secret := range p.secretVariables {
+			if strings.HasPrefix(secret, path+".") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+/* Append the variables referenced by an expression, as a, or a.b.c for selections of fields */
+func referencedVariables(expr *exprpb.Expr, paths []string) []string {
+	if expr == nil {
+		return paths
+	}
+	switch expr.ExprKind.(type) {
+	case *exprpb.Expr_IdentExpr:
+		paths = append(paths, expr.GetIdentExpr().GetName())
+	case *exprpb.Expr_SelectExpr:
+		if path, ok := selectionPath(expr); ok {
+			return append(paths, path)
+		}
+		paths = referencedVariables(expr.GetSelectExpr().GetOperand(), paths)
+	case *exprpb.Expr_CallExpr:
+		call := expr.GetCallExpr()
+		paths = referencedVariables(call.GetTarget(), paths)
+		for _, arg := range call.GetArgs() {
+			paths = referencedVariables(arg, paths)
+		}
+	case *exprpb.Expr_ListExpr:
+		for _, elem := range expr.GetListExpr().GetElements() {
+			paths = referencedVariables(elem, paths)
+		}
+	case *exprpb.Expr_StructExpr:
+		for _, entry := range expr.GetStructExpr().GetEntries() {
+			paths = referencedVariables(entry.GetMapKey(), paths)
+			paths = referencedVariables(entry.GetValue(), paths)
+		}
+	case *exprpb.Expr_ComprehensionExpr:
+		comprehension := expr.GetComprehensionExpr()
+		for _, sub := range []*exprpb.Expr{comprehension.GetIterRange(), comprehension.GetAccuInit(), comprehension.GetLoopCondition(), comprehension.GetLoopStep(), comprehension.GetResult()} {
+			paths = referencedVariables(sub, paths)
+		}
+	}
+	return paths
+}
+
+/* Return a.b.c for a selection of fields of a variable, or false if the operand is not a variable */
+func selectionPath(expr *exprpb.Expr) (string, bool) {
+	switch expr.ExprKind.(type) {
+	case *exprpb.Expr_IdentExpr:
+		return expr.GetIdentExpr().GetName(), true
+	case *exprpb.Expr_SelectExpr:
+		selection := expr.GetSelectExpr()
+		if selection.GetTestOnly() {
+			return "", false
+		}
+		operand, ok := selectionPath(selection.GetOperand())
+		return operand + "." + selection.GetField(), ok
+	}
+	return "", false
+}
+
+/* Return the value of a variable for the trace, or REDACTED if it holds a value of a Secret */
+func (p *Processor) traceVariable(variables map[string]interface{}, name string) interface{} {
+	if p.isSecretVariable(name) {
+		if _, ok := lookupVariable(variables, name); ok {
+			return REDACTED
+		}
+	}
+	return traceValue(variables, name)
+}
+
+/* Return a copy of the variables for logging, with the values of Secrets replaced by REDACTED */
+func (p *Processor) redactedVariables() map[string]interface{} {
+	ret := make(map[string]interface{}, len(p.variables))
+	for key, value := range p.variables {
+		ret[key] = value
+	}
+	for secret := range p.secretVariables {
+		components := strings.Split(secret, ".")
+		current := ret
+		for _, component := range components[:len(components)-1] {
+			next, ok := current[component].(map[string]interface{})
+			if !ok {
+				current = nil
+				break
+			}
+			copied := make(map[string]interface{}, len(next))
+			for key, value := range next {
+				copied[key] = value
+			}
+			current[component] = copied
+			current = copied
+		}
+		last := components[len(components)-1]
+		if _, ok := current[last]; ok {
+			current[last] = REDACTED
+		}
+	}
+	return ret
+}
+
+/* Set a variable from a value that is already evaluated */
+func (p *Processor) setEvaluatedVariable(env cel.Env, name string, description string, out ref.Val, variables map[string]interface{}, redact bool) (cel.Env, error) {
+	var step *debug.TraceStep
+	if p.trace != nil {
+		index := len(p.trace.Steps)
+		p.trace.AddStep(debug.TraceStep{Depth: 0, Statement: VARIABLES, Expression: description, Variable: name})
+		step = &p.trace.Steps[index]
+		step.OldValue = p.traceVariable(variables, name)
+	}
+	env, err := createOneVariable(env, name, description, out, variables)
+	if err == nil {
+		p.setSecretVariable(name, redact)
+	}
+	if step != nil {
+		if err != nil {
+			step.Error = err.Error()
+		} else {
+			step.NewValue = p.traceVariable(variables, name)
+		}
+	}
+	return env, err
+}
+
+/* Set a variable of the variables section of a mediator or mediation */
+func (p *Processor) setMediationVariable(env cel.Env, variable *eventsv1alpha1.EventMediationVariable, variables map[string]interface{}) (cel.Env, error) {
+	if !validVariableType(variable.Type) {
+		return env, fmt.Errorf("variable %v has invalid type %v. Valid types are int, bool, list, map, and string", variable.Name, variable.Type)
+	}
+
+	var err error
+	var newEnv cel.Env
+	if variable.ValueFrom != nil {
+		source := variable.ValueFrom
+		description := describeVariableSource(source)
+		str, found, readErr := p.readVariableSource(source)
+		if readErr != nil {
+			err = fmt.Errorf("unable to read variable %v from %v: %v", variable.Name, description, readErr)
+		} else if !found {
+			if optionalVariableSource(source) && variable.Default == nil {
+				return env, nil
+			}
+			err = fmt.Errorf("unable to read variable %v: %v not found", variable.Name, description)
+		} else {
+			out, convertErr := convertVariableValue(variable.Name, str, variable.Type)
+			if convertErr != nil {
+				return env, convertErr
+			}
+			return p.setEvaluatedVariable(env, variable.Name, description, out, variables, source.SecretKeyRef != nil)
+		}
+	} else if variable.ValueExpression != nil {
+		newEnv, err = p.setOneVariableTraced(env, variable.Name, *variable.ValueExpression, variable.Type, variables, 0, VARIABLES, *variable.ValueExpression)
+		if err == nil {
+			return newEnv, nil
+		}
+	} else if variable.Value != nil {
+		if variable.Type == "" || variable.Type == TYPESTRING {
+			return p.setOneVariableTraced(env, variable.Name, "\""+*variable.Value+"\"", variable.Type, variables, 0, VARIABLES, *variable.Value)
+		}
+		/* value of another declared type is converted from the string */
+		out, convertErr := convertVariableValue(variable.Name, *variable.Value, variable.Type)
+		if convertErr != nil {
+			return env, convertErr
+		}
+		return p.setEvaluatedVariable(env, variable.Name, *variable.Value, out, variables, false)
+	} else if variable.Default == nil {
+		return env, nil
+	}
+
+	/* use the default, unless the value is of the wrong type or a limit is exceeded */
+	if _, isTypeErr := err.(*variableTypeError); isTypeErr || p.limitErr != nil || variable.Default == nil {
+		return env, err
+	}
+	if err != nil {
+		klog.Infof("Using default %v for variable %v: %v", *variable.Default, variable.Name, err)
+	}
+	return p.setOneVariableTraced(env, variable.Name, *variable.Default, variable.Type, variables, 0, VARIABLES, *variable.Default)
+}
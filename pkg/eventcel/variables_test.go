@@ -0,0 +1,195 @@
+package eventcel
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	eventsv1alpha1 "github.com/kabanero-io/events-operator/pkg/apis/events/v1alpha1"
+	"github.com/kabanero-io/events-operator/pkg/debug"
+	"github.com/kabanero-io/events-operator/pkg/eventenv"
+	"github.com/kabanero-io/events-operator/pkg/status"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("TestVariables", func() {
+	str := func(value string) *string {
+		return &value
+	}
+	optional := true
+	mediator := &eventsv1alpha1.EventMediator{
+		ObjectMeta: metav1.ObjectMeta{Name: "webhook", Namespace: "kabanero"},
+	}
+
+	var processor *Processor
+	var debugMgr *debug.DebugManager
+	BeforeEach(func() {
+		kubeClient := fake.NewFakeClient(
+			&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "token", Namespace: "kabanero"},
+				Data:       map[string][]byte{"password": []byte("s3cret")},
+			},
+			&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "settings", Namespace: "kabanero"},
+				Data:       map[string]string{"replicas": "3", "enabled": "true", "names": "[a, b]", "url": "https://example.com"},
+			},
+		)
+		debugMgr = debug.NewDebugManager()
+		eventenv.InitEventEnv(&eventenv.EventEnv{
+			Cache:     kubeClient,
+			StatusMgr: status.NewStatusManager(),
+			DebugMgr:  debugMgr,
+			Namespace: "kabanero",
+		})
+		processor = NewProcessor(nil, func(p *Processor, destination string, buf []byte, header map[string][]string) error {
+			return nil
+		})
+	})
+
+	process := func(header map[string][]string, variables ...eventsv1alpha1.EventMediationVariable) (map[string]interface{}, error) {
+		mediation := &eventsv1alpha1.EventMediationImpl{Name: "webhook", Variables: &variables}
+		err := processor.ProcessMessage(context.Background(), header, map[string]interface{}{}, mediator, mediation, ProcessOptions{Namespace: "kabanero"})
+		if err != nil {
+			return nil, err
+		}
+		return processor.GetVariables()
+	}
+
+	It("should read variables from Secrets, ConfigMaps, and environment variables", func() {
+		os.Setenv("EVENTCEL_TEST_REGION", "us-east")
+		defer os.Unsetenv("EVENTCEL_TEST_REGION")
+
+		variables, err := process(map[string][]string{},
+			eventsv1alpha1.EventMediationVariable{Name: "token", ValueFrom: &eventsv1alpha1.EventVariableSource{
+				SecretKeyRef: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "token"}, Key: "password"}}},
+			eventsv1alpha1.EventMediationVariable{Name: "replicas", Type: TYPEINT, ValueFrom: &eventsv1alpha1.EventVariableSource{
+				ConfigMapKeyRef: &corev1.ConfigMapKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "settings"}, Key: "replicas"}}},
+			eventsv1alpha1.EventMediationVariable{Name: "names", Type: TYPELIST, ValueFrom: &eventsv1alpha1.EventVariableSource{
+				ConfigMapKeyRef: &corev1.ConfigMapKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "settings"}, Key: "names"}}},
+			eventsv1alpha1.EventMediationVariable{Name: "region", ValueFrom: &eventsv1alpha1.EventVariableSource{Env: "EVENTCEL_TEST_REGION"}},
+			eventsv1alpha1.EventMediationVariable{Name: "scaled", ValueExpression: str("replicas * 2")},
+		)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(variables["token"]).To(Equal("s3cret"))
+		Expect(variables["replicas"]).To(Equal(int64(3)))
+		Expect(variables["names"]).To(Equal([]interface{}{"a", "b"}))
+		Expect(variables["region"]).To(Equal("us-east"))
+		Expect(variables["scaled"]).To(Equal(int64(6)))
+	})
+
+	It("should convert values of declared types", func() {
+		variables, err := process(map[string][]string{},
+			eventsv1alpha1.EventMediationVariable{Name: "count", Type: TYPEINT, Value: str("5")},
+			eventsv1alpha1.EventMediationVariable{Name: "enabled", Type: TYPEBOOL, Value: str("true")},
+			eventsv1alpha1.EventMediationVariable{Name: "labels", Type: TYPEMAP, Value: str(`{"team": "a"}`)},
+			eventsv1alpha1.EventMediationVariable{Name: "name", Type: TYPESTRING, Value: str("hello")},
+		)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(variables["count"]).To(Equal(int64(5)))
+		Expect(variables["enabled"]).To(Equal(true))
+		Expect(variables["labels"]).To(Equal(map[string]interface{}{"team": "a"}))
+		Expect(variables["name"]).To(Equal("hello"))
+	})
+
+	It("should reject values of the wrong type, even with a default", func() {
+		_, err := process(map[string][]string{},
+			eventsv1alpha1.EventMediationVariable{Name: "count", Type: TYPEINT, ValueExpression: str(`"five"`), Default: str("0")})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("declared as int"))
+
+		_, err = process(map[string][]string{},
+			eventsv1alpha1.EventMediationVariable{Name: "count", Type: TYPEINT, Value: str("five")})
+		Expect(err).To(HaveOccurred())
+
+		_, err = process(map[string][]string{},
+			eventsv1alpha1.EventMediationVariable{Name: "count", Type: "float", Value: str("5")})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("invalid type"))
+	})
+
+	It("should use the default when the value can not be evaluated or found", func() {
+		variables, err := process(map[string][]string{},
+			eventsv1alpha1.EventMediationVariable{Name: "divided", Type: TYPEINT, ValueExpression: str("1 / 0"), Default: str("0")},
+			eventsv1alpha1.EventMediationVariable{Name: "url", ValueFrom: &eventsv1alpha1.EventVariableSource{
+				ConfigMapKeyRef: &corev1.ConfigMapKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "missing"}, Key: "url"}},
+				Default: str(`"https://default.example.com"`)},
+		)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(variables["divided"]).To(Equal(int64(0)))
+		Expect(variables["url"]).To(Equal("https://default.example.com"))
+
+		_, err = process(map[string][]string{},
+			eventsv1alpha1.EventMediationVariable{Name: "divided", ValueExpression: str("1 / 0")})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should fail on a missing source unless it is optional", func() {
+		missing := &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "token"}, Key: "missing"}
+		_, err := process(map[string][]string{},
+			eventsv1alpha1.EventMediationVariable{Name: "token", ValueFrom: &eventsv1alpha1.EventVariableSource{SecretKeyRef: missing}})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("not found"))
+
+		missing.Optional = &optional
+		variables, err := process(map[string][]string{},
+			eventsv1alpha1.EventMediationVariable{Name: "token", ValueFrom: &eventsv1alpha1.EventVariableSource{SecretKeyRef: missing}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(variables).ToNot(HaveKey("token"))
+	})
+
+	It("should not record the values of Secrets in traces", func() {
+		_, err := process(map[string][]string{debug.TRACE_HEADER: {"true"}},
+			eventsv1alpha1.EventMediationVariable{Name: "token", ValueFrom: &eventsv1alpha1.EventVariableSource{
+				SecretKeyRef: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "token"}, Key: "password"}}})
+		Expect(err).ToNot(HaveOccurred())
+		traces := debugMgr.GetTraces()
+		Expect(traces).To(HaveLen(1))
+		Expect(traces[0].Steps).To(HaveLen(1))
+		Expect(traces[0].Steps[0].Expression).To(Equal("secretKeyRef token/password"))
+		Expect(traces[0].Steps[0].NewValue).To(Equal(REDACTED))
+	})
+
+	It("should not record the values of variables computed from Secrets", func() {
+		secretRef := &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "token"}, Key: "password"}
+		mediation := &eventsv1alpha1.EventMediationImpl{Name: "webhook",
+			Variables: &[]eventsv1alpha1.EventMediationVariable{
+				{Name: "token", ValueFrom: &eventsv1alpha1.EventVariableSource{SecretKeyRef: secretRef}},
+				{Name: "auth", ValueExpression: str(`"Bearer " + token`)},
+				{Name: "user", Value: str("admin")},
+			},
+			Body: []eventsv1alpha1.EventStatement{
+				{Assign: str(`body.credentials = {"user": user, "auth": auth}`)},
+				{Assign: str(`body.copy = body.credentials`)},
+				{Assign: str(`body.user = body.credentials.user + ""`)},
+			},
+		}
+		body := map[string]interface{}{}
+		Expect(processor.ProcessMessage(context.Background(), map[string][]string{debug.TRACE_HEADER: {"true"}}, body, mediator, mediation, ProcessOptions{Namespace: "kabanero"})).To(Succeed())
+		Expect(body["copy"]).To(Equal(map[string]interface{}{"user": "admin", "auth": "Bearer s3cret"}))
+
+		values := make(map[string]interface{})
+		for _, step := range debugMgr.GetTraces()[0].Steps {
+			values[step.Variable] = step.NewValue
+		}
+		Expect(values["token"]).To(Equal(REDACTED))
+		Expect(values["auth"]).To(Equal(REDACTED))
+		Expect(values["user"]).To(Equal("admin"))
+		Expect(values["body.credentials"]).To(Equal(REDACTED))
+		Expect(values["body.copy"]).To(Equal(REDACTED))
+		Expect(fmt.Sprintf("%v", processor.redactedVariables())).ToNot(ContainSubstring("s3cret"))
+	})
+
+	It("should not include the values of variables in errors", func() {
+		_, err := process(map[string][]string{},
+			eventsv1alpha1.EventMediationVariable{Name: "token", ValueFrom: &eventsv1alpha1.EventVariableSource{
+				SecretKeyRef: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "token"}, Key: "password"}}},
+			eventsv1alpha1.EventMediationVariable{Name: "count", ValueExpression: str("token + 1")},
+		)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).ToNot(ContainSubstring("s3cret"))
+	})
+})
@@ -1,10 +1,16 @@
 package eventenv
 
 import (
+	"context"
+
+	eventsv1alpha1 "github.com/kabanero-io/events-operator/pkg/apis/events/v1alpha1"
 	"github.com/kabanero-io/events-operator/pkg/connections"
+	"github.com/kabanero-io/events-operator/pkg/debug"
 	"github.com/kabanero-io/events-operator/pkg/listeners"
 	"github.com/kabanero-io/events-operator/pkg/managers"
 	"github.com/kabanero-io/events-operator/pkg/status"
+	"github.com/kabanero-io/events-operator/pkg/templates"
+	"github.com/kabanero-io/events-operator/pkg/utils"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -13,18 +19,51 @@ const (
 )
 
 type EventEnv struct {
+	Context             context.Context // cancelled when the process shuts down
 	Client              client.Client
+	Cache               client.Reader // reads from the informer cache, including unstructured objects
+	APIReader           client.Reader // reads directly from the API server, in any namespace, for getResource and listResources
 	EventMgr            *managers.EventManager
 	ConnectionsMgr      *connections.ConnectionsManager
 	ListenerMgr         listeners.ListenerManager
     StatusMgr           *status.StatusManager
     StatusUpdater       *status.Updater
+	TemplateMgr         *templates.TemplateManager
+	DebugMgr            *debug.DebugManager
+	StackIndex          *utils.StackListenerIndex // stacks and event listeners from the watches of the worker, or nil to read them from the API server. Not used until synced
+	DownloadYAML        DownloadYAMLFunc // downloads a YAML file from the repository of a webhook event
+	GetPullRequest      GetPullRequestFunc // reads the head and base of a pull request of the repository of a webhook event
+	GetPermission       GetPermissionFunc  // reads the permission of a user on the repository of a webhook event
+	ReportStatus        ReportStatusFunc   // reports a commit status or check run on the repository of a webhook event
+	CompareCommits      CompareCommitsFunc // lists the files changed between two commits of the repository of a webhook event
+	ListFiles           ListFilesFunc      // lists the files of a commit of the repository of a webhook event
 	MediatorName        string // Kubernetes name of this mediator worker if not ""
 	IsOperator          bool   // true if this instance is an operator, not a worker
 	Namespace           string // namespace we're running under
 	KabaneroIntegration bool   // true to integrate with Kabanero
 }
 
+/* Function to download a YAML file from a repository, with the credentials of the GitHub repository configuration repo.
+   The file is read at commit ref, or at the head of the push or pull request of the event if ref is "".
+   Return the file as a map, and whether it exists */
+type DownloadYAMLFunc func(ctx context.Context, kubeClient client.Client, namespace string, repo *eventsv1alpha1.EventGithubRepository, header map[string][]string, body map[string]interface{}, fileName string, ref string) (map[string]interface{}, bool, error)
+
+/* Function to read the head and base of pull request number of the repository of a webhook event */
+type GetPullRequestFunc func(ctx context.Context, kubeClient client.Client, namespace string, repo *eventsv1alpha1.EventGithubRepository, header map[string][]string, body map[string]interface{}, number int) (*utils.PullRequestRefs, error)
+
+/* Function to read the permission of user login on the repository of a webhook event: admin, write, read, or none */
+type GetPermissionFunc func(ctx context.Context, kubeClient client.Client, namespace string, repo *eventsv1alpha1.EventGithubRepository, header map[string][]string, body map[string]interface{}, login string) (string, error)
+
+/* Function to report a commit status or check run on the repository of a webhook event */
+type ReportStatusFunc func(ctx context.Context, kubeClient client.Client, namespace string, repo *eventsv1alpha1.EventGithubRepository, header map[string][]string, body map[string]interface{}, report *utils.GithubStatusReport) error
+
+/* Function to list the files changed between the base and head commits of the repository of a webhook event */
+type CompareCommitsFunc func(ctx context.Context, kubeClient client.Client, namespace string, repo *eventsv1alpha1.EventGithubRepository, header map[string][]string, body map[string]interface{}, base, head string) ([]string, error)
+
+/* Function to list the files of the repository of a webhook event at commit ref, or at the head of the push or pull request of the event if ref is "".
+   Return whether the list is complete */
+type ListFilesFunc func(ctx context.Context, kubeClient client.Client, namespace string, repo *eventsv1alpha1.EventGithubRepository, header map[string][]string, body map[string]interface{}, ref string) ([]string, bool, error)
+
 var eventEnv *EventEnv
 
 func InitEventEnv(env *EventEnv) {
@@ -51,6 +51,7 @@ type listenerInfo struct {
 }
 
 type ListenerOptions struct {
+	Host        string // address to listen on, or "" for all addresses
 	Port        int32
 	TLSCertPath string
 	TLSKeyPath  string
@@ -102,7 +103,7 @@ func (listenerMgr *ListenerManagerDefault) NewListener(handler http.Handler, opt
     klog.Infof("Starting listener thread for port %v", port)
 	go func() {
 		klog.Infof("Listener thread started for port %v", port)
-		err := http.ListenAndServe(":"+strconv.Itoa(int(port)), handler)
+		err := http.ListenAndServe(options.Host+":"+strconv.Itoa(int(port)), handler)
 		if err != nil {
 			klog.Errorf("Listener thread error for port %v, error: %v", port, err)
 		}
@@ -157,7 +158,7 @@ func (listenerMgr *ListenerManagerDefault) NewListenerTLS(handler http.Handler,
     klog.Infof("Strating listener thread for port %v", port)
 	go func() {
 		klog.Infof("TLS Listener thread started for port %v", port)
-		err := http.ListenAndServeTLS(":"+strconv.Itoa(int(port)), options.TLSCertPath, options.TLSKeyPath, handler)
+		err := http.ListenAndServeTLS(options.Host+":"+strconv.Itoa(int(port)), options.TLSCertPath, options.TLSKeyPath, handler)
 		if err != nil {
 			klog.Infof("TLS Listener thread error for port %v, error: %v  ", port, err)
 		}
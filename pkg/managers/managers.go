@@ -6,6 +6,7 @@ import (
     "k8s.io/klog"
 
 	eventsv1alpha1 "github.com/kabanero-io/events-operator/pkg/apis/events/v1alpha1"
+	"github.com/kabanero-io/events-operator/pkg/utils"
 //	corev1 "k8s.io/api/core/v1"
 //	"k8s.io/apimachinery/pkg/api/errors"
 	// metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -93,6 +94,7 @@ type MediatorManager struct {
     mediator *eventsv1alpha1.EventMediator // the mediator whose mediations we are managing
     // importMediations map[string]*MediationsManager // imported mediations
     containedEventMediationImplMgr map[string]*EventMediationImplManager // mediations contained within
+    urlPatterns map[string]*utils.URLPattern // compiled urlPattern of the selector of each mediation, by name of mediation
 }
 
 /* Add a new mediations */
@@ -180,7 +182,13 @@ func NewEventManager() *EventManager {
     }
 }
 
-func (mgr *EventManager) AddEventMediator(mediator *eventsv1alpha1.EventMediator) {
+/* Add or replace a mediator. The mediator is not added if the urlPattern of a selector is not valid. */
+func (mgr *EventManager) AddEventMediator(mediator *eventsv1alpha1.EventMediator) error {
+    urlPatterns, err := utils.CompileURLPatterns(mediator)
+    if err != nil {
+        return err
+    }
+
     mgr.mutex.Lock()
     defer mgr.mutex.Unlock()
 
@@ -189,11 +197,13 @@ func (mgr *EventManager) AddEventMediator(mediator *eventsv1alpha1.EventMediator
         manager: mgr,
         mediator: mediator,
         containedEventMediationImplMgr: make(map[string]*EventMediationImplManager),
+        urlPatterns: urlPatterns,
     }
     hash := eventsv1alpha1.MediatorHashKey(mediator)
     klog.Infof("Adding new EventMediator with key: %v", hash)
     mgr.mediatorMgrs[hash] = mediatorMgr
     mediatorMgr.initialize()
+    return nil
 }
 
 func (mgr *EventManager) GetMediator(key string)  *eventsv1alpha1.EventMediator{
@@ -212,6 +222,17 @@ func (mgr *EventManager) GetMediator(key string)  *eventsv1alpha1.EventMediator{
     return mediatorMgr.mediator
 }
 
+/* Return the compiled urlPatterns of a mediator, or nil if the mediator is not the one that was added */
+func (mgr *EventManager) GetURLPatterns(mediator *eventsv1alpha1.EventMediator) map[string]*utils.URLPattern {
+    mgr.mutex.Lock()
+    defer mgr.mutex.Unlock()
+
+    mediatorMgr, exists := mgr.mediatorMgrs[eventsv1alpha1.MediatorHashKey(mediator)]
+    if !exists || mediatorMgr.mediator != mediator {
+        return nil
+    }
+    return mediatorMgr.urlPatterns
+}
 
 func (mgr *EventManager) GetMediatorManagers() []*MediatorManager {
     mgr.mutex.Lock()
@@ -53,9 +53,25 @@ var _ = Describe("TestEvbentManager", func() {
 		It("should add an EventMediator successfully", func() {
 			numInitialManagers := len(mgr.GetMediatorManagers())
 			key := v1alpha1.MediatorHashKey(mediator)
-			mgr.AddEventMediator(mediator)
+			Expect(mgr.AddEventMediator(mediator)).To(Succeed())
 			Expect(mgr.GetMediator(key)).ToNot(BeNil())
 			Expect(len(mgr.GetMediatorManagers())).Should(Equal(numInitialManagers + 1))
 		})
+
+		It("should not add an EventMediator with an invalid urlPattern", func() {
+			invalid := mediator.DeepCopy()
+			(*invalid.Spec.Mediations)[0].Selector = &v1alpha1.EventMediationSelector{UrlPattern: "^hooks/("}
+			Expect(mgr.AddEventMediator(invalid)).ToNot(Succeed())
+			Expect(mgr.GetMediator(v1alpha1.MediatorHashKey(invalid))).To(BeNil())
+		})
+
+		It("should return the compiled urlPatterns of the EventMediator that was added", func() {
+			withSelector := mediator.DeepCopy()
+			(*withSelector.Spec.Mediations)[0].Selector = &v1alpha1.EventMediationSelector{UrlPattern: "/teams/{team}"}
+			Expect(mgr.AddEventMediator(withSelector)).To(Succeed())
+			urlPatterns := mgr.GetURLPatterns(withSelector)
+			Expect(urlPatterns).To(HaveKey("mediation-test"))
+			Expect(mgr.GetURLPatterns(withSelector.DeepCopy())).To(BeNil())
+		})
 	})
 })
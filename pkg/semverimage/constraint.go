@@ -0,0 +1,203 @@
+package semverimage
+
+import (
+	"fmt"
+	"strings"
+)
+
+/* Operators of comparators. ^, ~, and partial versions are translated into them */
+const (
+	opEQ = "="
+	opGT = ">"
+	opGE = ">="
+	opLT = "<"
+	opLE = "<="
+)
+
+/* Operators that may prefix a version in a constraint, longest first */
+var constraintOperators = []string{">=", "<=", "~>", ">", "<", "=", "^", "~"}
+
+/* A comparison of a version to a bound */
+type comparator struct {
+	operator string
+	bound    *Version // version with major, minor, and patch
+}
+
+/* Constraint on versions, such as ^1.2, ~0.3.1, >=0.2 <0.4, or 1.x.
+   It is a list of alternatives separated by ||, each a list of comparators separated by spaces or commas that must all be satisfied:
+     1.2.3, =1.2.3: the version 1.2.3 only
+     1.2, 1.2.x, 1.2.*: any 1.2.y version. A partial version is a range, as for IsCompatible
+     >1.2.3, >=1.2, <2, <=1.2: versions greater or lower than the bound. Missing components of a bound are ranges: >1.2 is >=1.3.0
+     ~1.2.3: patch versions, >=1.2.3 <1.3.0. ~1 is >=1.0.0 <2.0.0
+     ^1.2.3: versions with the same left-most non-zero component, >=1.2.3 <2.0.0. ^0.2.3 is >=0.2.3 <0.3.0
+     *, x, or "": any version
+   A pre-release version, such as 1.3.0-beta.1, satisfies an alternative only if one of its comparators is a pre-release of the same
+   major, minor, and patch version, so that a range does not select a pre-release unless asked for.
+*/
+type Constraint struct {
+	text         string
+	alternatives [][]comparator
+}
+
+/* Parse a constraint */
+func NewConstraint(str string) (*Constraint, error) {
+	ret := &Constraint{text: strings.TrimSpace(str)}
+	for _, alternative := range strings.Split(str, "||") {
+		comparators := make([]comparator, 0)
+		tokens := strings.Fields(strings.Replace(alternative, ",", " ", -1))
+		for index := 0; index < len(tokens); index++ {
+			token := tokens[index]
+			if isConstraintOperator(token) && index+1 < len(tokens) {
+				/* operator separated from its version, as in >= 0.2 */
+				index++
+				token += tokens[index]
+			}
+			parsed, err := parseComparator(token)
+			if err != nil {
+				return nil, fmt.Errorf("invalid version constraint %v: %v", str, err)
+			}
+			comparators = append(comparators, parsed...)
+		}
+		ret.alternatives = append(ret.alternatives, comparators)
+	}
+	return ret, nil
+}
+
+func isConstraintOperator(token string) bool {
+	for _, operator := range constraintOperators {
+		if token == operator {
+			return true
+		}
+	}
+	return false
+}
+
+/* Return the lowest version of a partial version, with missing components set to 0 */
+func lowest(ver *Version) *Version {
+	ret := *ver
+	if ret.Major < 0 {
+		ret.Major = 0
+	}
+	if ret.Minor < 0 {
+		ret.Minor = 0
+	}
+	if ret.Patch < 0 {
+		ret.Patch = 0
+	}
+	return &ret
+}
+
+/* Return the lowest version above the range of a partial version: 2.0.0 for 1, and 1.3.0 for 1.2 */
+func aboveRange(ver *Version) *Version {
+	if ver.Minor < 0 {
+		return &Version{Major: ver.Major + 1, Minor: 0, Patch: 0}
+	}
+	return &Version{Major: ver.Major, Minor: ver.Minor + 1, Patch: 0}
+}
+
+/* Parse one comparator of a constraint, such as ^1.2, into comparators with full versions */
+func parseComparator(token string) ([]comparator, error) {
+	operator := ""
+	for _, op := range constraintOperators {
+		if strings.HasPrefix(token, op) {
+			operator = op
+			break
+		}
+	}
+	ver, err := parseVersion(token[len(operator):], true)
+	if err != nil {
+		return nil, err
+	}
+	if ver.Major < 0 {
+		/* any version */
+		return []comparator{}, nil
+	}
+	full := ver.Patch >= 0
+
+	switch operator {
+	case "", opEQ:
+		if full {
+			return []comparator{{opEQ, ver}}, nil
+		}
+		return []comparator{{opGE, lowest(ver)}, {opLT, aboveRange(ver)}}, nil
+	case opGE:
+		return []comparator{{opGE, lowest(ver)}}, nil
+	case opGT:
+		if full {
+			return []comparator{{opGT, ver}}, nil
+		}
+		return []comparator{{opGE, aboveRange(ver)}}, nil
+	case opLT:
+		return []comparator{{opLT, lowest(ver)}}, nil
+	case opLE:
+		if full {
+			return []comparator{{opLE, ver}}, nil
+		}
+		return []comparator{{opLT, aboveRange(ver)}}, nil
+	case "~", "~>":
+		upper := &Version{Major: ver.Major + 1, Minor: 0, Patch: 0}
+		if ver.Minor >= 0 {
+			upper = &Version{Major: ver.Major, Minor: ver.Minor + 1, Patch: 0}
+		}
+		return []comparator{{opGE, lowest(ver)}, {opLT, upper}}, nil
+	case "^":
+		var upper *Version
+		switch {
+		case ver.Major > 0 || ver.Minor < 0:
+			upper = &Version{Major: ver.Major + 1, Minor: 0, Patch: 0}
+		case ver.Minor > 0 || ver.Patch < 0:
+			upper = &Version{Major: 0, Minor: ver.Minor + 1, Patch: 0}
+		default:
+			upper = &Version{Major: 0, Minor: 0, Patch: ver.Patch + 1}
+		}
+		return []comparator{{opGE, lowest(ver)}, {opLT, upper}}, nil
+	}
+	return nil, fmt.Errorf("unknown operator in %v", token)
+}
+
+/* Return true if a version satisfies the comparator */
+func (comp *comparator) satisfiedBy(ver *Version) bool {
+	result := ver.Compare(comp.bound)
+	switch comp.operator {
+	case opEQ:
+		return result == 0
+	case opGT:
+		return result > 0
+	case opGE:
+		return result >= 0
+	case opLT:
+		return result < 0
+	case opLE:
+		return result <= 0
+	}
+	return false
+}
+
+/* Return true if the version satisfies the constraint. Missing minor or patch components of the version are treated as 0 */
+func (constraint *Constraint) Check(ver *Version) bool {
+	ver = lowest(ver)
+	for _, comparators := range constraint.alternatives {
+		satisfied := true
+		/* a pre-release needs a comparator that is a pre-release of the same version */
+		preReleaseAllowed := ver.PreRelease == ""
+		for index := range comparators {
+			comp := &comparators[index]
+			if !comp.satisfiedBy(ver) {
+				satisfied = false
+				break
+			}
+			bound := comp.bound
+			if bound.PreRelease != "" && bound.Major == ver.Major && bound.Minor == ver.Minor && bound.Patch == ver.Patch {
+				preReleaseAllowed = true
+			}
+		}
+		if satisfied && preReleaseAllowed {
+			return true
+		}
+	}
+	return false
+}
+
+func (constraint *Constraint) String() string {
+	return constraint.text
+}
@@ -0,0 +1,83 @@
+package semverimage_test
+
+import (
+	"github.com/kabanero-io/events-operator/pkg/semverimage"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SemverConstraint", func() {
+
+	It("should parse pre-release versions, build metadata, and a leading v", func() {
+		ver, err := semverimage.NewVersion("v1.2.3-beta.2+20200401")
+		Expect(err).Should(BeNil())
+		Expect(*ver).Should(Equal(semverimage.Version{Major: 1, Minor: 2, Patch: 3, PreRelease: "beta.2", Build: "20200401"}))
+		Expect(ver.String()).Should(Equal("1.2.3-beta.2+20200401"))
+
+		for _, invalid := range []string{"", "v", "1.2.3.4", "1.x", "1.2-beta", "1.2.3-", "1.2.3-beta..1", "1.2.3+build_1", "a.b.c"} {
+			_, err = semverimage.NewVersion(invalid)
+			Expect(err).ShouldNot(BeNil(), invalid)
+		}
+	})
+
+	It("should order pre-release versions", func() {
+		ordered := []string{"1.0.0-alpha", "1.0.0-alpha.1", "1.0.0-alpha.beta", "1.0.0-beta", "1.0.0-beta.2", "1.0.0-beta.11", "1.0.0-rc.1", "1.0.0", "1.0.1"}
+		for index := 0; index+1 < len(ordered); index++ {
+			lower, err := semverimage.NewVersion(ordered[index])
+			Expect(err).Should(BeNil())
+			higher, err := semverimage.NewVersion(ordered[index+1])
+			Expect(err).Should(BeNil())
+			Expect(higher.GreaterThan(lower)).Should(BeTrue(), ordered[index+1]+" > "+ordered[index])
+			Expect(lower.GreaterThan(higher)).Should(BeFalse())
+		}
+		withBuild, _ := semverimage.NewVersion("1.0.0+build.5")
+		release, _ := semverimage.NewVersion("1.0.0")
+		Expect(withBuild.Compare(release)).Should(Equal(0))
+	})
+
+	It("should check versions against constraints", func() {
+		data := []struct {
+			Constraint string
+			Satisfied  []string
+			Rejected   []string
+		}{
+			{"1.2.3", []string{"1.2.3", "v1.2.3"}, []string{"1.2.4", "1.2.3-beta"}},
+			{"0.3", []string{"0.3.0", "0.3.99"}, []string{"0.4.0", "0.2.9"}},
+			{"1.x", []string{"1.0.0", "1.9.9"}, []string{"2.0.0", "0.9.0"}},
+			{"*", []string{"0.0.1", "5.1.0"}, []string{"5.1.0-rc.1"}},
+			{"", []string{"0.0.1"}, []string{}},
+			{"^1.2", []string{"1.2.0", "1.9.0"}, []string{"1.1.9", "2.0.0"}},
+			{"^0.3.1", []string{"0.3.1", "0.3.9"}, []string{"0.3.0", "0.4.0"}},
+			{"^0.0.3", []string{"0.0.3"}, []string{"0.0.4"}},
+			{"~0.3.1", []string{"0.3.1", "0.3.7"}, []string{"0.3.0", "0.4.0"}},
+			{"~1", []string{"1.0.0", "1.5.0"}, []string{"2.0.0"}},
+			{">=0.2 <0.4", []string{"0.2.0", "0.3.9"}, []string{"0.1.9", "0.4.0"}},
+			{">= 0.2, < 0.4", []string{"0.3.0"}, []string{"0.4.0"}},
+			{">1.2", []string{"1.3.0"}, []string{"1.2.9"}},
+			{"<=1.2", []string{"1.2.9"}, []string{"1.3.0"}},
+			{"^0.3 || ^1.0", []string{"0.3.5", "1.4.0"}, []string{"0.4.0", "2.0.0"}},
+			{">=1.3.0-beta.1 <1.4", []string{"1.3.0-beta.2", "1.3.0", "1.3.5"}, []string{"1.3.0-alpha", "1.3.1-beta.1"}},
+		}
+		for _, entry := range data {
+			constraint, err := semverimage.NewConstraint(entry.Constraint)
+			Expect(err).Should(BeNil(), entry.Constraint)
+			for _, str := range entry.Satisfied {
+				ver, err := semverimage.NewVersion(str)
+				Expect(err).Should(BeNil())
+				Expect(constraint.Check(ver)).Should(BeTrue(), entry.Constraint+" satisfied by "+str)
+			}
+			for _, str := range entry.Rejected {
+				ver, err := semverimage.NewVersion(str)
+				Expect(err).Should(BeNil())
+				Expect(constraint.Check(ver)).Should(BeFalse(), entry.Constraint+" rejects "+str)
+			}
+		}
+	})
+
+	It("should reject invalid constraints", func() {
+		for _, invalid := range []string{"^a.b", ">=1.2.3.4", "1.x.3", "=>1.2"} {
+			_, err := semverimage.NewConstraint(invalid)
+			Expect(err).ShouldNot(BeNil(), invalid)
+		}
+	})
+})
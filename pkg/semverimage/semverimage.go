@@ -7,9 +7,11 @@ import (
 )
 
 type Version struct {
-    Major int  /* major version */
+    Major int  /* major version, or -1 for a wildcard in a constraint */
     Minor int /* minor version, or -1 */
     Patch int /* patch version, or -1 */
+    PreRelease string /* dot separated pre-release identifiers, such as "beta.2", or "" for a release */
+    Build string /* build metadata, ignored when comparing versions, or "" */
 }
 
 /* Return true if current version is compatible with other version.
@@ -49,57 +51,154 @@ func (ver *Version) IsCompatible(otherVer *Version) bool {
 
 /* Return true if this version is greater than other version*/
 func (ver *Version) GreaterThan(otherVer *Version) bool {
-    if ver.Major > otherVer.Major {
-        return true
-    }
-    if ver.Major < otherVer.Major {
-        return false
+    return ver.Compare(otherVer) > 0
+}
+
+/* Compare this version to other version. A pre-release version is lower than the release of the same version.
+   Build metadata is ignored.
+   Return -1 if ver < otherVer, 0 if equal, and 1 if ver > otherVer
+*/
+func (ver *Version) Compare(otherVer *Version) int {
+    for _, pair := range [][2]int{ {ver.Major, otherVer.Major}, {ver.Minor, otherVer.Minor}, {ver.Patch, otherVer.Patch} } {
+        if pair[0] > pair[1] {
+            return 1
+        }
+        if pair[0] < pair[1] {
+            return -1
+        }
     }
+    return comparePreRelease(ver.PreRelease, otherVer.PreRelease)
+}
 
-    /* same major */
-    if ver.Minor > otherVer.Minor {
-        return true
+/* Compare pre-release identifiers. A release, with no identifiers, is higher than any pre-release.
+   Numeric identifiers are compared numerically, and are lower than alphanumeric identifiers, which are compared in ASCII order.
+   If all identifiers are equal, the version with more identifiers is higher.
+*/
+func comparePreRelease(pre string, otherPre string) int {
+    if pre == otherPre {
+        return 0
+    }
+    if pre == "" {
+        return 1
+    }
+    if otherPre == "" {
+        return -1
+    }
+    ids := strings.Split(pre, ".")
+    otherIds := strings.Split(otherPre, ".")
+    for index := 0; index < len(ids) && index < len(otherIds); index++ {
+        num, err := strconv.Atoi(ids[index])
+        isNum := err == nil
+        otherNum, err := strconv.Atoi(otherIds[index])
+        otherIsNum := err == nil
+        switch {
+        case isNum && otherIsNum:
+            if num != otherNum {
+                if num > otherNum {
+                    return 1
+                }
+                return -1
+            }
+        case isNum:
+            return -1
+        case otherIsNum:
+            return 1
+        default:
+            if ids[index] != otherIds[index] {
+                if ids[index] > otherIds[index] {
+                    return 1
+                }
+                return -1
+            }
+        }
     }
-    if ver.Minor < otherVer.Minor {
-        return false
+    if len(ids) > len(otherIds) {
+        return 1
     }
-
-    /* same minor */
-    return ver.Patch > otherVer.Patch
+    if len(ids) < len(otherIds) {
+        return -1
+    }
+    return 0
 }
 
-/* Parse version string for form "major.minor.patch", where minor and patch are optional */
+/* Parse version string for form "major.minor.patch", where minor and patch are optional.
+   The version may start with v, such as v1.2.3, and end with pre-release identifiers after -,
+   and build metadata after +, such as 1.2.3-beta.2+20200401. A pre-release version must have all three components.
+*/
 func NewVersion(str string) (*Version, error ) {
+    return parseVersion(str, false)
+}
+
+/* Parse a version. If allowWildcards is true, the components may be x, X, or *, as in 1.x, which are set to -1 */
+func parseVersion(str string, allowWildcards bool) (*Version, error) {
+    invalid := fmt.Errorf("%v not a semantic version of form major.minor.patch", str)
     ret := &Version {
-        Minor: -1, 
+        Major: -1,
+        Minor: -1,
         Patch: -1,
     }
 
-    var err error
-    var remainder string
-    ret.Major, remainder, err = parseInt(str, ".")
-    if err != nil {
-        return nil, fmt.Errorf("%v not a semantic version of form major.minor.patch", str)
+    remainder := str
+    if len(remainder) > 1 && (remainder[0] == 'v' || remainder[0] == 'V') {
+        remainder = remainder[1:]
     }
-    if remainder == ""  {
-        return ret, nil
+    if index := strings.Index(remainder, "+"); index >= 0 {
+        ret.Build = remainder[index+1:]
+        remainder = remainder[:index]
+        if !validIdentifiers(ret.Build) {
+            return nil, invalid
+        }
+    }
+    if index := strings.Index(remainder, "-"); index >= 0 {
+        ret.PreRelease = remainder[index+1:]
+        remainder = remainder[:index]
+        if !validIdentifiers(ret.PreRelease) {
+            return nil, invalid
+        }
     }
 
-    ret.Minor, remainder, err = parseInt(remainder, ".")
-    if err != nil {
-        return nil, fmt.Errorf("%v not a semantic version of form major.minor.patch", str)
+    components := strings.Split(remainder, ".")
+    if len(components) > 3 {
+        return nil, invalid
     }
-    if remainder == ""  {
-        return ret, nil
+    values := []*int{ &ret.Major, &ret.Minor, &ret.Patch }
+    wildcard := false
+    for index, component := range components {
+        if allowWildcards && (component == "x" || component == "X" || component == "*") {
+            wildcard = true
+            continue
+        }
+        value, err := strconv.Atoi(component)
+        if err != nil || value < 0 || wildcard {
+            /* not a number, or a number after a wildcard */
+            return nil, invalid
+        }
+        *values[index] = value
     }
-
-    ret.Patch, err = strconv.Atoi(remainder)
-    if err != nil {
-        return nil, fmt.Errorf("%v not a semantic version of form major.minor.patch", str)
+    if ret.Major < 0 && !wildcard {
+        return nil, invalid
+    }
+    if (ret.PreRelease != "" || ret.Build != "") && ret.Patch < 0 {
+        return nil, invalid
     }
     return ret, nil
 }
 
+/* Return true if identifiers are non-empty dot separated alphanumerics and hyphens */
+func validIdentifiers(identifiers string) bool {
+    for _, identifier := range strings.Split(identifiers, ".") {
+        if identifier == "" {
+            return false
+        }
+        for _, ch := range identifier {
+            if !(ch >= '0' && ch <= '9' || ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch == '-') {
+                return false
+            }
+        }
+    }
+    return true
+}
+
 func (ver *Version) String() string {
     var ret string
     if ver.Minor < 0 {
@@ -111,34 +210,11 @@ func (ver *Version) String() string {
             ret = fmt.Sprintf("%d.%d.%d", ver.Major, ver.Minor, ver.Patch)
         }
     }
-    return ret
-}
-
-/*  Fetch first integer component from the input string, up to the optional separator.
- Return:
-   ret: the integer
-   remainder: what remains after separtor
-   error: any error
-*/
-func parseInt(str string, separator string) (ret int, remainder string, err error ) {
-    remainder = str
-    index := strings.Index(str, separator)
-    if index == 0 {
-        return ret, remainder, fmt.Errorf("separtor %s first component of string %s", separator, str)
-    }
-    if index < 0 {
-        /* no separator found */
-        remainder = ""
-        ret, err = strconv.Atoi(str)
-        return ret, remainder, err
-    }
-
-    intStr := str[0:index]
-    if index == len(str) -1 {
-       remainder = ""
-    } else {
-       remainder = str[index+1:]
+    if ver.PreRelease != "" {
+        ret += "-" + ver.PreRelease
     }
-    ret, err = strconv.Atoi(intStr)
-    return ret, remainder, err
+    if ver.Build != "" {
+        ret += "+" + ver.Build
+    }
+    return ret
 }
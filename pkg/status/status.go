@@ -30,12 +30,16 @@ const (
     MAX_RETAINED_MESSAGES = 100 // maximum number of messages to retain
 
    /* Operations names */
+   OPERATION_LOAD_MEDIATOR = "load-mediator"
    OPERATION_VALIDATE_WEBHOOK_SECRET = "validate-webhook-secret"
    OPERATION_RESOLVE_REPOSITORY_TYPE = "resolve-repository-type"
    OPERATION_FIND_MEDIATION = "find-mediation"
    OPERATION_INITIALIZE_VARIABLES = "initialize-mediation-variables"
    OPERATION_EVALUATE_MEDIATION = "evaluate-mediation"
    OPERATION_SEND_EVENT = "send-event"
+   OPERATION_APPLY_RESOURCE = "apply-resource"
+   OPERATION_CHECK_COMMAND_PERMISSION = "check-command-permission"
+   OPERATION_REPORT_STATUS = "report-status"
 
    /* Parameter names */
    PARAM_FROM = "from"
@@ -48,10 +52,23 @@ const (
    PARAM_BRANCH = "branch"
    PARAM_GITHUB_EVENT = "github-event"
    PARAM_STACK = "stack"
+   PARAM_RESOURCE = "resource"
+   PARAM_COMMAND = "command"
+   PARAM_USER = "user"
+   PARAM_COMMIT = "commit"
 
    /* Results */
    RESULT_FAILED = "failed"
    RESULT_COMPLETED = "completed"
+   RESULT_DRY_RUN = "dry-run"
+   RESULT_WARNING = "warning"
+
+   /* Conditions */
+   CONDITION_STACK_LISTENERS_AVAILABLE = "StackListenersAvailable" // whether every active stack version has an event listener with a URL
+   CONDITION_TRUE = "True"
+   CONDITION_FALSE = "False"
+   REASON_LISTENERS_AVAILABLE = "ListenersAvailable"
+   REASON_LISTENERS_MISSING = "ListenersMissing"
 
 )
 
@@ -59,6 +76,7 @@ const (
 
 type  StatusManager struct {
     summaryList *list.List 
+    conditions []eventsv1alpha1.EventMediatorCondition
     needsUpdate bool
     mutex sync.Mutex
 }
@@ -71,6 +89,7 @@ type summaryElement struct {
 func NewStatusManager() *StatusManager {
     sm := &StatusManager {
         summaryList : list.New(),
+        conditions: make([]eventsv1alpha1.EventMediatorCondition, 0),
         needsUpdate: true,
     }
     return sm
@@ -123,6 +142,40 @@ func (sm *StatusManager) AddEventSummary(summary *eventsv1alpha1.EventStatusSumm
     sm.needsUpdate = true
 }
 
+/* Set a condition, replacing the condition of the same type.
+   The transition time is kept if the status, reason, and message did not change.
+*/
+func (sm *StatusManager) SetCondition(condition eventsv1alpha1.EventMediatorCondition) {
+    sm.mutex.Lock()
+    defer sm.mutex.Unlock()
+
+    for index := range sm.conditions {
+        existing := &sm.conditions[index]
+        if existing.Type != condition.Type {
+            continue
+        }
+        if existing.Status == condition.Status && existing.Reason == condition.Reason && existing.Message == condition.Message {
+            return
+        }
+        klog.Infof("SetCondition: %v changed to %v: %v", condition.Type, condition.Status, condition.Message)
+        condition.LastTransitionTime.Time = time.Now()
+        *existing = condition
+        sm.needsUpdate = true
+        return
+    }
+    klog.Infof("SetCondition: %v is %v: %v", condition.Type, condition.Status, condition.Message)
+    condition.LastTransitionTime.Time = time.Now()
+    sm.conditions = append(sm.conditions, condition)
+    sm.needsUpdate = true
+}
+
+func (sm *StatusManager) GetConditions() []eventsv1alpha1.EventMediatorCondition {
+    sm.mutex.Lock()
+    defer sm.mutex.Unlock()
+
+    return append([]eventsv1alpha1.EventMediatorCondition{}, sm.conditions...)
+}
+
 func (sm *StatusManager) GetStatusSummary() []eventsv1alpha1.EventStatusSummary {
     sm.mutex.Lock()
     defer sm.mutex.Unlock()
@@ -154,8 +207,11 @@ func (sm *StatusManager) SendStatus(updater *Updater) {
     defer sm.mutex.Unlock()
 
     if sm.needsUpdate {
-        summaryArray := sm.getStatusSummaryHelper()
-        updater.SendUpdate(summaryArray)
+        status := eventsv1alpha1.EventMediatorStatus {
+            Summary: sm.getStatusSummaryHelper(),
+            Conditions: append([]eventsv1alpha1.EventMediatorCondition{}, sm.conditions...),
+        }
+        updater.SendUpdate(status)
         sm.needsUpdate = false
     }
 }
@@ -169,9 +225,9 @@ type Updater struct {
     client client.Client // controller client
     timerStarted bool // true if timer started
     timerChan chan struct{} // channel for timer
-    statusChan chan *[]eventsv1alpha1.EventStatusSummary // channel to send status update
+    statusChan chan *eventsv1alpha1.EventMediatorStatus // channel to send status update
 
-    summary *[]eventsv1alpha1.EventStatusSummary  // latest summary
+    status *eventsv1alpha1.EventMediatorStatus  // latest status
 
     mutex sync.Mutex
 }
@@ -182,15 +238,15 @@ func NewSatusUpdater(client client.Client, namespace string, name string, durati
         duration: duration,
         timerStarted: false,
         timerChan: make(chan struct{}, 1),
-        statusChan: make(chan *[]eventsv1alpha1.EventStatusSummary),
-        summary: nil,
+        statusChan: make(chan *eventsv1alpha1.EventMediatorStatus),
+        status: nil,
     }
 
     // Thread to update status
     go func() {
         for  {
              status := updater.getStatus()
-             err := utils.UpdateStatus(updater.client, namespace, name, *status)
+             err := utils.UpdateStatus(updater.client, namespace, name, status)
              if err != nil {
                 updater.putBack(status)
              }
@@ -200,33 +256,33 @@ func NewSatusUpdater(client client.Client, namespace string, name string, durati
 }
 
 /* Put back what was processed. */
-func (updater *Updater) putBack(summary *[]eventsv1alpha1.EventStatusSummary) {
+func (updater *Updater) putBack(status *eventsv1alpha1.EventMediatorStatus) {
     updater.mutex.Lock()
     defer updater.mutex.Unlock()
 
     /* Put back only if there is nothing newer */
-    if updater.summary == nil {
-        updater.summary = summary
+    if updater.status == nil {
+        updater.status = status
         updater.startTimer()
     }
 }
 
 /* Get available status. Block if needed. */
-func (updater *Updater) getStatus() *[]eventsv1alpha1.EventStatusSummary {
+func (updater *Updater) getStatus() *eventsv1alpha1.EventMediatorStatus {
     for {
          select {
-              case summary, _:= <- updater.statusChan:
+              case status, _:= <- updater.statusChan:
                   klog.Infof("Updater getStatus: Received status")
                   updater.mutex.Lock()
-                  updater.summary = summary
+                  updater.status = status
                   updater.startTimer()
                   updater.mutex.Unlock()
               case <- updater.timerChan:
                   updater.mutex.Lock()
                   updater.timerStarted = false
-                  klog.Infof("Updater getStatus: Timer fired, has status: %v", updater.summary != nil)
-                  ret := updater.summary
-                  updater.summary = nil
+                  klog.Infof("Updater getStatus: Timer fired, has status: %v", updater.status != nil)
+                  ret := updater.status
+                  updater.status = nil
                   updater.mutex.Unlock()
                   if ret != nil {
                       return ret
@@ -237,9 +293,9 @@ func (updater *Updater) getStatus() *[]eventsv1alpha1.EventStatusSummary {
 }
 
 /* Send Update */
-func (updater *Updater) SendUpdate(summary []eventsv1alpha1.EventStatusSummary) {
+func (updater *Updater) SendUpdate(status eventsv1alpha1.EventMediatorStatus) {
     klog.Infof("Updater SendUpdate called")
-    updater.statusChan <- &summary
+    updater.statusChan <- &status
 }
 
 func (updater *Updater) startTimer() {
@@ -125,4 +125,20 @@ var _ = Describe("StatusTest", func() {
 		Expect(resultLen).Should(Equal(status.MAX_RETAINED_MESSAGES))
 		Expect(CompareList(arraySummary, resultSummary)).Should(BeTrue())
 	})
+	It("should replace conditions of the same type and keep their transition time if unchanged", func() {
+		statusMgr.SetCondition(eventsv1alpha1.EventMediatorCondition{Type: status.CONDITION_STACK_LISTENERS_AVAILABLE, Status: status.CONDITION_TRUE})
+		conditions := statusMgr.GetConditions()
+		Expect(len(conditions)).Should(Equal(1))
+		transitionTime := conditions[0].LastTransitionTime
+		Expect(transitionTime.IsZero()).Should(BeFalse())
+
+		statusMgr.SetCondition(eventsv1alpha1.EventMediatorCondition{Type: status.CONDITION_STACK_LISTENERS_AVAILABLE, Status: status.CONDITION_TRUE})
+		Expect(statusMgr.GetConditions()[0].LastTransitionTime).Should(Equal(transitionTime))
+
+		statusMgr.SetCondition(eventsv1alpha1.EventMediatorCondition{Type: status.CONDITION_STACK_LISTENERS_AVAILABLE, Status: status.CONDITION_FALSE, Message: "kabanero/nodejs:0.3.1"})
+		conditions = statusMgr.GetConditions()
+		Expect(len(conditions)).Should(Equal(1))
+		Expect(conditions[0].Status).Should(Equal(status.CONDITION_FALSE))
+		Expect(conditions[0].Message).Should(Equal("kabanero/nodejs:0.3.1"))
+	})
 })
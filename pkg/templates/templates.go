@@ -0,0 +1,96 @@
+package templates
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"text/template"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+/* A parsed template, and the version of the ConfigMap it was parsed from */
+type cachedTemplate struct {
+	resourceVersion string
+	tmpl            *template.Template
+}
+
+/* TemplateManager caches templates parsed from ConfigMaps. A cached template is re-parsed when the
+   resource version of its ConfigMap changes, and dropped when the ConfigMap changes or is deleted.
+*/
+type TemplateManager struct {
+	templates map[string]*cachedTemplate // key is namespace/name/key
+	mutex     sync.Mutex
+}
+
+func NewTemplateManager() *TemplateManager {
+	return &TemplateManager{
+		templates: make(map[string]*cachedTemplate),
+	}
+}
+
+func getConfigMapPrefix(namespace string, name string) string {
+	return namespace + "/" + name + "/"
+}
+
+/* Parse a template */
+func ParseTemplate(name string, text string) (*template.Template, error) {
+	return template.New(name).Option("missingkey=error").Parse(text)
+}
+
+/* Get the template stored under key of a ConfigMap */
+func (templateMgr *TemplateManager) GetConfigMapTemplate(ctx context.Context, kubeClient client.Client, namespace string, name string, key string) (*template.Template, error) {
+	configMap := &corev1.ConfigMap{}
+	err := kubeClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, configMap)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get ConfigMap %v/%v: %v", namespace, name, err)
+	}
+
+	cacheKey := getConfigMapPrefix(namespace, name) + key
+	templateMgr.mutex.Lock()
+	defer templateMgr.mutex.Unlock()
+
+	cached, exists := templateMgr.templates[cacheKey]
+	if exists && cached.resourceVersion == configMap.ResourceVersion {
+		return cached.tmpl, nil
+	}
+
+	text, exists := configMap.Data[key]
+	if !exists {
+		return nil, fmt.Errorf("key %v not found in ConfigMap %v/%v", key, namespace, name)
+	}
+	tmpl, err := ParseTemplate(cacheKey, text)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse template %v in ConfigMap %v/%v: %v", key, namespace, name, err)
+	}
+	klog.Infof("Parsed template %v, resource version %v", cacheKey, configMap.ResourceVersion)
+	templateMgr.templates[cacheKey] = &cachedTemplate{
+		resourceVersion: configMap.ResourceVersion,
+		tmpl:            tmpl,
+	}
+	return tmpl, nil
+}
+
+/* Remove all cached templates of a ConfigMap */
+func (templateMgr *TemplateManager) Invalidate(namespace string, name string) {
+	templateMgr.mutex.Lock()
+	defer templateMgr.mutex.Unlock()
+
+	prefix := getConfigMapPrefix(namespace, name)
+	for cacheKey := range templateMgr.templates {
+		if strings.HasPrefix(cacheKey, prefix) {
+			delete(templateMgr.templates, cacheKey)
+		}
+	}
+}
+
+/* Return the number of cached templates */
+func (templateMgr *TemplateManager) Size() int {
+	templateMgr.mutex.Lock()
+	defer templateMgr.mutex.Unlock()
+	return len(templateMgr.templates)
+}
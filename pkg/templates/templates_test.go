@@ -0,0 +1,99 @@
+package templates_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/kabanero-io/events-operator/pkg/templates"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestTemplates(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Templates Suite")
+}
+
+var _ = Describe("TestTemplateManager", func() {
+	var (
+		configMap *corev1.ConfigMap
+		mgr       *templates.TemplateManager
+	)
+
+	BeforeEach(func() {
+		configMap = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            "templates",
+				Namespace:       "kabanero",
+				ResourceVersion: "1",
+			},
+			Data: map[string]string{
+				"greeting": "hello {{.name}}",
+			},
+		}
+		mgr = templates.NewTemplateManager()
+	})
+
+	It("should render and cache a ConfigMap template", func() {
+		kubeClient := fake.NewFakeClient(configMap)
+		tmpl, err := mgr.GetConfigMapTemplate(context.Background(), kubeClient, "kabanero", "templates", "greeting")
+		Expect(err).ToNot(HaveOccurred())
+
+		buf := new(bytes.Buffer)
+		Expect(tmpl.Execute(buf, map[string]interface{}{"name": "world"})).To(Succeed())
+		Expect(buf.String()).To(Equal("hello world"))
+		Expect(mgr.Size()).To(Equal(1))
+
+		cached, err := mgr.GetConfigMapTemplate(context.Background(), kubeClient, "kabanero", "templates", "greeting")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cached).To(BeIdenticalTo(tmpl))
+	})
+
+	It("should re-parse a template when the ConfigMap changes", func() {
+		kubeClient := fake.NewFakeClient(configMap)
+		tmpl, err := mgr.GetConfigMapTemplate(context.Background(), kubeClient, "kabanero", "templates", "greeting")
+		Expect(err).ToNot(HaveOccurred())
+
+		updated := &corev1.ConfigMap{}
+		Expect(kubeClient.Get(context.Background(), types.NamespacedName{Namespace: "kabanero", Name: "templates"}, updated)).To(Succeed())
+		updated.Data["greeting"] = "goodbye {{.name}}"
+		Expect(kubeClient.Update(context.Background(), updated)).To(Succeed())
+
+		reparsed, err := mgr.GetConfigMapTemplate(context.Background(), kubeClient, "kabanero", "templates", "greeting")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(reparsed).ToNot(BeIdenticalTo(tmpl))
+
+		buf := new(bytes.Buffer)
+		Expect(reparsed.Execute(buf, map[string]interface{}{"name": "world"})).To(Succeed())
+		Expect(buf.String()).To(Equal("goodbye world"))
+	})
+
+	It("should drop cached templates when invalidated", func() {
+		kubeClient := fake.NewFakeClient(configMap)
+		_, err := mgr.GetConfigMapTemplate(context.Background(), kubeClient, "kabanero", "templates", "greeting")
+		Expect(err).ToNot(HaveOccurred())
+		mgr.Invalidate("kabanero", "other")
+		Expect(mgr.Size()).To(Equal(1))
+		mgr.Invalidate("kabanero", "templates")
+		Expect(mgr.Size()).To(Equal(0))
+	})
+
+	It("should return an error for a missing ConfigMap or key", func() {
+		kubeClient := fake.NewFakeClient(configMap)
+		_, err := mgr.GetConfigMapTemplate(context.Background(), kubeClient, "kabanero", "missing", "greeting")
+		Expect(err).To(HaveOccurred())
+		_, err = mgr.GetConfigMapTemplate(context.Background(), kubeClient, "kabanero", "templates", "missing")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should fail to render a missing variable", func() {
+		tmpl, err := templates.ParseTemplate("inline", "hello {{.name}}")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(tmpl.Execute(new(bytes.Buffer), map[string]interface{}{})).ToNot(Succeed())
+	})
+})
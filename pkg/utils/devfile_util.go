@@ -0,0 +1,123 @@
+/*
+Copyright 2020 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+/* The attributes of a devfile.yaml used to find the pipelines of a repository */
+type Devfile struct {
+	Name         string                 // metadata.name
+	Version      string                 // metadata.version
+	Stack        string                 // the stack of the parent: parent.id or parent.kubernetes.name
+	StackVersion string                 // parent.version, or "" for any version
+	Attributes   map[string]interface{} // metadata.attributes, overridden by the top level attributes
+}
+
+/* Return the string at key of a map of a devfile, or "" if it does not exist */
+func devfileString(devfileMap map[string]interface{}, section string, key string) (string, error) {
+	value, ok := devfileMap[key]
+	if !ok || value == nil {
+		return "", nil
+	}
+	typed, ok := value.(string)
+	if !ok {
+		/* versions such as 1.0 are parsed as numbers, and their text is lost */
+		return "", fmt.Errorf("%v.%v in devfile is not a string but %T. Quote the value", section, key, value)
+	}
+	return typed, nil
+}
+
+/* Return the map at key of a devfile, or nil if it does not exist */
+func devfileMap(devfile map[string]interface{}, key string) (map[string]interface{}, error) {
+	value, ok := devfile[key]
+	if !ok || value == nil {
+		return nil, nil
+	}
+	ret, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%v in devfile is not a map but %T", key, value)
+	}
+	return ret, nil
+}
+
+/* ParseDevfile parses the metadata, parent, and attributes of a devfile of schema version 2 */
+func ParseDevfile(devfile map[string]interface{}) (*Devfile, error) {
+	schemaVersion, err := devfileString(devfile, "devfile", "schemaVersion")
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(schemaVersion, "2.") {
+		return nil, fmt.Errorf("unsupported devfile schemaVersion %v. Only version 2 is supported", schemaVersion)
+	}
+
+	ret := &Devfile{Attributes: make(map[string]interface{})}
+	metadata, err := devfileMap(devfile, "metadata")
+	if err != nil {
+		return nil, err
+	}
+	if metadata != nil {
+		if ret.Name, err = devfileString(metadata, "metadata", "name"); err != nil {
+			return nil, err
+		}
+		if ret.Version, err = devfileString(metadata, "metadata", "version"); err != nil {
+			return nil, err
+		}
+		attributes, err := devfileMap(metadata, "attributes")
+		if err != nil {
+			return nil, err
+		}
+		for key, value := range attributes {
+			ret.Attributes[key] = value
+		}
+	}
+
+	attributes, err := devfileMap(devfile, "attributes")
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range attributes {
+		ret.Attributes[key] = value
+	}
+
+	parent, err := devfileMap(devfile, "parent")
+	if err != nil {
+		return nil, err
+	}
+	if parent != nil {
+		if ret.Stack, err = devfileString(parent, "parent", "id"); err != nil {
+			return nil, err
+		}
+		if ret.Stack == "" {
+			kubernetes, err := devfileMap(parent, "kubernetes")
+			if err != nil {
+				return nil, err
+			}
+			if kubernetes != nil {
+				if ret.Stack, err = devfileString(kubernetes, "parent.kubernetes", "name"); err != nil {
+					return nil, err
+				}
+			}
+		}
+		if ret.StackVersion, err = devfileString(parent, "parent", "version"); err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
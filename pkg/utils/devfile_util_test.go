@@ -0,0 +1,94 @@
+package utils_test
+
+import (
+	"encoding/json"
+
+	"github.com/kabanero-io/events-operator/pkg/utils"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("TestDevfile", func() {
+	It("should parse the metadata, parent, and attributes", func() {
+		devfile, err := utils.ParseDevfile(map[string]interface{}{
+			"schemaVersion": "2.1.0",
+			"metadata": map[string]interface{}{
+				"name":       "demo",
+				"version":    "1.0",
+				"attributes": map[string]interface{}{"team": "blue", "pipeline": "default"},
+			},
+			"parent": map[string]interface{}{
+				"kubernetes": map[string]interface{}{"name": "java-openliberty"},
+			},
+			"attributes": map[string]interface{}{"pipeline": "build-push"},
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(devfile.Name).To(Equal("demo"))
+		Expect(devfile.Version).To(Equal("1.0"))
+		Expect(devfile.Stack).To(Equal("java-openliberty"))
+		Expect(devfile.StackVersion).To(BeEmpty())
+		Expect(devfile.Attributes).To(Equal(map[string]interface{}{"team": "blue", "pipeline": "build-push"}))
+	})
+
+	It("should prefer the registry id of the parent", func() {
+		devfile, err := utils.ParseDevfile(map[string]interface{}{
+			"schemaVersion": "2.2.0",
+			"parent":        map[string]interface{}{"id": "nodejs", "uri": "https://registry.devfile.io/nodejs", "version": "0.3"},
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(devfile.Stack).To(Equal("nodejs"))
+		Expect(devfile.StackVersion).To(Equal("0.3"))
+		Expect(devfile.Attributes).To(BeEmpty())
+	})
+
+	It("should parse the nested maps of a downloaded devfile", func() {
+		devfileMap, err := utils.YAMLToMap([]byte(`
+schemaVersion: 2.1.0
+metadata:
+  name: demo
+  version: "0.10"
+  attributes:
+    team: blue
+    ports: [8080, {name: debug, port: 7777}]
+parent:
+  id: nodejs
+  version: ^0.3
+`))
+		Expect(err).ToNot(HaveOccurred())
+		devfile, err := utils.ParseDevfile(devfileMap)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(devfile.Name).To(Equal("demo"))
+		Expect(devfile.Version).To(Equal("0.10"))
+		Expect(devfile.Stack).To(Equal("nodejs"))
+		Expect(devfile.StackVersion).To(Equal("^0.3"))
+		Expect(devfile.Attributes["ports"]).To(Equal([]interface{}{8080, map[string]interface{}{"name": "debug", "port": 7777}}))
+
+		/* the repository type value is passed to CEL as JSON */
+		_, err = json.Marshal(devfileMap)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("should reject versions that are not strings", func() {
+		devfileMap, err := utils.YAMLToMap([]byte("schemaVersion: 2.1.0\nmetadata:\n  version: 0.10\n"))
+		Expect(err).ToNot(HaveOccurred())
+		_, err = utils.ParseDevfile(devfileMap)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("metadata.version"))
+	})
+
+	It("should not use the uri of the parent as the stack", func() {
+		devfile, err := utils.ParseDevfile(map[string]interface{}{
+			"schemaVersion": "2.2.0",
+			"parent":        map[string]interface{}{"uri": "https://registry.devfile.io/nodejs"},
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(devfile.Stack).To(BeEmpty())
+	})
+
+	It("should reject devfiles of other schema versions", func() {
+		_, err := utils.ParseDevfile(map[string]interface{}{"apiVersion": "1.0.0"})
+		Expect(err).To(HaveOccurred())
+		_, err = utils.ParseDevfile(map[string]interface{}{"schemaVersion": "2.0.0", "metadata": "demo"})
+		Expect(err).To(HaveOccurred())
+	})
+})
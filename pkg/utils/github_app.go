@@ -0,0 +1,270 @@
+/*
+Copyright 2020 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+/* Authentication to the GitHub API as a GitHub App.
+   A JWT signed with the private key of the app is exchanged for an installation token, which is then used
+   like a personal access token. Installation tokens are cached until shortly before they expire.
+*/
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/google/go-github/github"
+	eventsv1alpha1 "github.com/kabanero-io/events-operator/pkg/apis/events/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	GITHUB_API_URL = "https://api.github.com/"
+
+	githubAppJWTLifetime        = 9 * time.Minute // GitHub accepts at most 10 minutes
+	githubAppClockSkew          = time.Minute     // the JWT is issued in the past in case the clock of GitHub is behind
+	githubAppTokenRenewalMargin = 5 * time.Minute // installation tokens are renewed when they expire within this time
+)
+
+/* Transport that sends an Authorization header with every request */
+type githubTokenTransport struct {
+	authorization string
+	base          http.RoundTripper
+}
+
+func (t *githubTokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", t.authorization)
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(req)
+}
+
+/* Return the URL of the GitHub API for a GitHub server, such as https://github.com */
+func githubAPIURL(githubURL string, isEnterprise bool) string {
+	if isEnterprise {
+		return githubURL + "/api/v3/"
+	}
+	return GITHUB_API_URL
+}
+
+/* Return a client of the GitHub API that sends authorization with each request */
+func newGithubAPIClient(apiURL string, authorization string) (*github.Client, error) {
+	httpClient := &http.Client{Transport: &githubTokenTransport{authorization: authorization}}
+	return github.NewEnterpriseClient(apiURL, apiURL, httpClient)
+}
+
+type githubAppTokenKey struct {
+	apiURL         string
+	appID          int64
+	installationID int64
+}
+
+type githubAppInstallationKey struct {
+	apiURL string
+	appID  int64
+	owner  string
+	repo   string
+}
+
+type githubAppToken struct {
+	token     string
+	expiresAt time.Time
+}
+
+/* GithubAppTokenCache caches the installation tokens of GitHub Apps, and the installations discovered for repositories */
+type GithubAppTokenCache struct {
+	tokens        map[githubAppTokenKey]githubAppToken
+	installations map[githubAppInstallationKey]int64
+	now           func() time.Time
+	mutex         sync.Mutex
+}
+
+func NewGithubAppTokenCache() *GithubAppTokenCache {
+	return &GithubAppTokenCache{
+		tokens:        make(map[githubAppTokenKey]githubAppToken),
+		installations: make(map[githubAppInstallationKey]int64),
+		now:           time.Now,
+	}
+}
+
+/* installation tokens of all GitHub Apps of this process */
+var githubAppTokens = NewGithubAppTokenCache()
+
+/* Return a JWT to authenticate as the GitHub App */
+func (cache *GithubAppTokenCache) appJWT(appID int64, privateKey []byte) (string, error) {
+	key, err := jwt.ParseRSAPrivateKeyFromPEM(privateKey)
+	if err != nil {
+		return "", fmt.Errorf("unable to parse private key of GitHub App %v: %v", appID, err)
+	}
+	now := cache.now()
+	claims := &jwt.StandardClaims{
+		IssuedAt:  now.Add(-githubAppClockSkew).Unix(),
+		ExpiresAt: now.Add(githubAppJWTLifetime).Unix(),
+		Issuer:    strconv.FormatInt(appID, 10),
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodRS256, claims).SignedString(key)
+}
+
+/* Return the ID of the installation of the app for a repository */
+func (cache *GithubAppTokenCache) findInstallation(ctx context.Context, apiClient *github.Client, apiURL string, appID int64, owner string, repo string) (int64, error) {
+	key := githubAppInstallationKey{apiURL: apiURL, appID: appID, owner: owner, repo: repo}
+	cache.mutex.Lock()
+	id, ok := cache.installations[key]
+	cache.mutex.Unlock()
+	if ok {
+		return id, nil
+	}
+
+	installation, resp, err := apiClient.Apps.FindRepositoryInstallation(ctx, owner, repo)
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			return 0, fmt.Errorf("GitHub App %v is not installed for repository %v/%v", appID, owner, repo)
+		}
+		return 0, fmt.Errorf("unable to find installation of GitHub App %v for repository %v/%v: %v", appID, owner, repo, err)
+	}
+	id = installation.GetID()
+	klog.Infof("found installation %v of GitHub App %v for repository %v/%v", id, appID, owner, repo)
+
+	cache.mutex.Lock()
+	cache.installations[key] = id
+	cache.mutex.Unlock()
+	return id, nil
+}
+
+/*
+InstallationToken returns an installation token of a GitHub App.
+Input:
+    ctx: context of the request
+    apiURL: URL of the GitHub API
+    appID: ID of the app
+    installationID: ID of the installation of the app, or 0 to discover the installation for the repository owner/repo
+    owner, repo: the repository to be accessed
+    privateKey: PEM encoded private key of the app
+*/
+func (cache *GithubAppTokenCache) InstallationToken(ctx context.Context, apiURL string, appID int64, installationID int64, owner string, repo string, privateKey []byte) (string, error) {
+	if installationID != 0 {
+		if token, ok := cache.cachedToken(githubAppTokenKey{apiURL: apiURL, appID: appID, installationID: installationID}); ok {
+			return token, nil
+		}
+	}
+
+	appToken, err := cache.appJWT(appID, privateKey)
+	if err != nil {
+		return "", err
+	}
+	apiClient, err := newGithubAPIClient(apiURL, "Bearer "+appToken)
+	if err != nil {
+		return "", err
+	}
+	if installationID == 0 {
+		installationID, err = cache.findInstallation(ctx, apiClient, apiURL, appID, owner, repo)
+		if err != nil {
+			return "", err
+		}
+	}
+	key := githubAppTokenKey{apiURL: apiURL, appID: appID, installationID: installationID}
+	if token, ok := cache.cachedToken(key); ok {
+		return token, nil
+	}
+
+	/* POST app/installations/{id}/access_tokens. The path used by this version of go-github is deprecated */
+	req, err := apiClient.NewRequest("POST", fmt.Sprintf("app/installations/%v/access_tokens", installationID), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/vnd.github.machine-man-preview+json")
+	installationToken := &github.InstallationToken{}
+	_, err = apiClient.Do(ctx, req, installationToken)
+	if err != nil {
+		return "", fmt.Errorf("unable to create token for installation %v of GitHub App %v: %v", installationID, appID, err)
+	}
+	if installationToken.GetToken() == "" {
+		return "", fmt.Errorf("no token returned for installation %v of GitHub App %v", installationID, appID)
+	}
+
+	cache.mutex.Lock()
+	cache.tokens[key] = githubAppToken{token: installationToken.GetToken(), expiresAt: installationToken.GetExpiresAt()}
+	cache.mutex.Unlock()
+	klog.Infof("created token for installation %v of GitHub App %v, expires at %v", installationID, appID, installationToken.GetExpiresAt())
+	return installationToken.GetToken(), nil
+}
+
+/* Return a cached token that does not expire soon */
+func (cache *GithubAppTokenCache) cachedToken(key githubAppTokenKey) (string, bool) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	token, ok := cache.tokens[key]
+	if !ok {
+		return "", false
+	}
+	if cache.now().Add(githubAppTokenRenewalMargin).After(token.expiresAt) {
+		delete(cache.tokens, key)
+		return "", false
+	}
+	return token.token, true
+}
+
+/*
+GetGithubCredentials returns the user and token to access a repository through the GitHub API.
+For a GitHub App, the user is "" and the token is an installation token. Otherwise, they are read from the secret of
+the repository, or from the secret found for htmlURL if no secret is specified.
+Input:
+    ctx: context of the request
+    kubeClient: client to API server
+    namespace: namespace of the secrets
+    repo: the GitHub repository configuration of the mediator, or nil
+    apiURL: URL of the GitHub API
+    owner, name, htmlURL: the repository to be accessed
+*/
+func GetGithubCredentials(ctx context.Context, kubeClient client.Client, namespace string, repo *eventsv1alpha1.EventGithubRepository, apiURL string, owner string, name string, htmlURL string) (string, string, error) {
+	if repo == nil || repo.App == nil {
+		secretName := ""
+		if repo != nil {
+			secretName = repo.Secret
+		}
+		user, token, err := GetGitHubSecret(ctx, kubeClient, namespace, secretName, htmlURL)
+		if err != nil {
+			return "", "", fmt.Errorf("unable to get user/token secret for URL %s: %v", htmlURL, err)
+		}
+		return user, token, nil
+	}
+
+	app := repo.App
+	secret := &corev1.Secret{}
+	err := kubeClient.Get(ctx, client.ObjectKey{Namespace: namespace, Name: app.PrivateKey.Name}, secret)
+	if err != nil {
+		return "", "", fmt.Errorf("unable to read private key of GitHub App %v from Secret %v/%v: %v", app.AppID, namespace, app.PrivateKey.Name, err)
+	}
+	privateKey, ok := secret.Data[app.PrivateKey.Key]
+	if !ok {
+		return "", "", fmt.Errorf("Secret %v/%v does not contain the private key %v of GitHub App %v", namespace, app.PrivateKey.Name, app.PrivateKey.Key, app.AppID)
+	}
+	token, err := githubAppTokens.InstallationToken(ctx, apiURL, app.AppID, app.InstallationID, owner, name, privateKey)
+	if err != nil {
+		return "", "", err
+	}
+	return "", token, nil
+}
@@ -0,0 +1,153 @@
+package utils_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	eventsv1alpha1 "github.com/kabanero-io/events-operator/pkg/apis/events/v1alpha1"
+	"github.com/kabanero-io/events-operator/pkg/utils"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("TestGithubApp", func() {
+	var privateKey *rsa.PrivateKey
+	var privateKeyPEM []byte
+	var server *httptest.Server
+	var tokenRequests, installationRequests int
+	var tokenLifetime time.Duration
+
+	BeforeEach(func() {
+		var err error
+		privateKey, err = rsa.GenerateKey(rand.Reader, 2048)
+		Expect(err).ToNot(HaveOccurred())
+		privateKeyPEM = pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(privateKey)})
+		tokenRequests = 0
+		installationRequests = 0
+		tokenLifetime = time.Hour
+
+		/* Return the issuer of the JWT of the request */
+		appID := func(req *http.Request) string {
+			token, err := jwt.ParseWithClaims(strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer "), &jwt.StandardClaims{}, func(token *jwt.Token) (interface{}, error) {
+				return &privateKey.PublicKey, nil
+			})
+			if err != nil {
+				return ""
+			}
+			return token.Claims.(*jwt.StandardClaims).Issuer
+		}
+
+		mux := http.NewServeMux()
+		mux.HandleFunc("/api/v3/repos/kabanero-io/demo/installation", func(writer http.ResponseWriter, req *http.Request) {
+			installationRequests++
+			if appID(req) != "7" {
+				writer.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(writer, `{"id": 42}`)
+		})
+		mux.HandleFunc("/api/v3/app/installations/42/access_tokens", func(writer http.ResponseWriter, req *http.Request) {
+			tokenRequests++
+			if req.Method != "POST" || appID(req) != "7" {
+				writer.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			writer.WriteHeader(http.StatusCreated)
+			json.NewEncoder(writer).Encode(map[string]interface{}{
+				"token":      fmt.Sprintf("installation-token-%d", tokenRequests),
+				"expires_at": time.Now().Add(tokenLifetime).UTC().Format(time.RFC3339),
+			})
+		})
+		mux.HandleFunc("/api/v3/repos/kabanero-io/demo/contents/.appsody-config.yaml", func(writer http.ResponseWriter, req *http.Request) {
+			if req.Header.Get("Authorization") != "token installation-token-1" {
+				writer.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			json.NewEncoder(writer).Encode(map[string]interface{}{
+				"type":     "file",
+				"encoding": "base64",
+				"content":  base64.StdEncoding.EncodeToString([]byte("stack: docker.io/kabanero/nodejs:0.3")),
+			})
+		})
+		server = httptest.NewServer(mux)
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("should discover the installation and cache its token", func() {
+		cache := utils.NewGithubAppTokenCache()
+		for i := 0; i < 2; i++ {
+			token, err := cache.InstallationToken(context.Background(), server.URL+"/api/v3/", 7, 0, "kabanero-io", "demo", privateKeyPEM)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(token).To(Equal("installation-token-1"))
+		}
+		Expect(installationRequests).To(Equal(1))
+		Expect(tokenRequests).To(Equal(1))
+
+		token, err := cache.InstallationToken(context.Background(), server.URL+"/api/v3/", 7, 42, "kabanero-io", "demo", privateKeyPEM)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(token).To(Equal("installation-token-1"))
+		Expect(tokenRequests).To(Equal(1))
+	})
+
+	It("should renew tokens that are about to expire", func() {
+		tokenLifetime = time.Minute
+		cache := utils.NewGithubAppTokenCache()
+		token, err := cache.InstallationToken(context.Background(), server.URL+"/api/v3/", 7, 42, "kabanero-io", "demo", privateKeyPEM)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(token).To(Equal("installation-token-1"))
+		token, err = cache.InstallationToken(context.Background(), server.URL+"/api/v3/", 7, 42, "kabanero-io", "demo", privateKeyPEM)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(token).To(Equal("installation-token-2"))
+	})
+
+	It("should report an app that is not installed", func() {
+		cache := utils.NewGithubAppTokenCache()
+		_, err := cache.InstallationToken(context.Background(), server.URL+"/api/v3/", 7, 0, "kabanero-io", "other", privateKeyPEM)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("not installed"))
+
+		_, err = cache.InstallationToken(context.Background(), server.URL+"/api/v3/", 7, 0, "kabanero-io", "demo", []byte("not a key"))
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should download repository files with the installation token", func() {
+		kubeClient := fake.NewFakeClient(&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "github-app", Namespace: "kabanero"},
+			Data:       map[string][]byte{"private-key.pem": privateKeyPEM},
+		})
+		repo := &eventsv1alpha1.EventGithubRepository{App: &eventsv1alpha1.EventGithubApp{
+			AppID:      7,
+			PrivateKey: corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "github-app"}, Key: "private-key.pem"},
+		}}
+		user, token, err := utils.GetGithubCredentials(context.Background(), kubeClient, "kabanero", repo, server.URL+"/api/v3/", "kabanero-io", "demo", "https://github.com/kabanero-io/demo")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(user).To(BeEmpty())
+		Expect(token).To(Equal("installation-token-1"))
+
+		content, found, err := utils.DownloadFileFromGithub(context.Background(), "kabanero-io", "demo", ".appsody-config.yaml", "abc123", server.URL, user, token, true)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(found).To(BeTrue())
+		Expect(string(content)).To(Equal("stack: docker.io/kabanero/nodejs:0.3"))
+
+		repo.App.PrivateKey.Key = "missing"
+		_, _, err = utils.GetGithubCredentials(context.Background(), kubeClient, "kabanero", repo, server.URL+"/api/v3/", "kabanero-io", "demo", "https://github.com/kabanero-io/demo")
+		Expect(err).To(HaveOccurred())
+	})
+})
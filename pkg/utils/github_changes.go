@@ -0,0 +1,269 @@
+/*
+Copyright 2020 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+/* Files changed by push and pull request events, for mediations of monorepos.
+   The files of a push are listed by the commits of its payload. The payload of a pull request does not list its files,
+   and the payload of a large push lists only its first commits, so their files are read through the compare API of GitHub.
+   The projects of the changed files are found in the tree of the repository, read once through the git trees API.
+*/
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	eventsv1alpha1 "github.com/kabanero-io/events-operator/pkg/apis/events/v1alpha1"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	githubPushCommitsMax = 20                                         // push payloads list at most 20 commits
+	githubNullSHA        = "0000000000000000000000000000000000000000" // before of the push of a new branch, after of a deleted branch
+
+	ROOT_DIRECTORY = "." // the directory of files at the root of the repository
+
+	GITHUB_COMPARE_FILES_MAX = 300 // the compare API lists at most 300 files
+)
+
+/* GetPushChangedFiles returns the files added, modified, or removed by the commits of a push event, sorted, and whether the payload lists all of its commits.
+   Return false for other events, and for the push that deletes a branch.
+*/
+func GetPushChangedFiles(header map[string][]string, body map[string]interface{}) ([]string, bool) {
+	if githubEvent(header) != "push" || bodyString(body, "after") == githubNullSHA {
+		return nil, false
+	}
+	commits, ok := body["commits"].([]interface{})
+	if !ok {
+		return nil, false
+	}
+	files := make(map[string]bool)
+	for _, commitObj := range commits {
+		commit, ok := commitObj.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for _, key := range []string{"added", "modified", "removed"} {
+			names, _ := commit[key].([]interface{})
+			for _, name := range names {
+				if nameStr, ok := name.(string); ok {
+					files[nameStr] = true
+				}
+			}
+		}
+	}
+	ret := make([]string, 0, len(files))
+	for file := range files {
+		ret = append(ret, file)
+	}
+	sort.Strings(ret)
+	return ret, len(commits) < githubPushCommitsMax
+}
+
+/* Return the X-Github-Event header, or "" */
+func githubEvent(header map[string][]string) string {
+	if values := header["X-Github-Event"]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+/* Return the string at a path of nested maps of an event, or "" */
+func bodyString(body map[string]interface{}, keys ...string) string {
+	var value interface{} = body
+	for _, key := range keys {
+		valueMap, ok := value.(map[string]interface{})
+		if !ok {
+			return ""
+		}
+		value = valueMap[key]
+	}
+	str, _ := value.(string)
+	return str
+}
+
+/* GetChangeRefs returns the base and head commits of the changes of an event: before and after of a push, the base and head of a pull request,
+   or of refs, the pull request of a ChatOps command, if it is not nil. base is "" if the push created its branch, and the changes cannot be compared.
+   Return false if the event changes no files: events other than push and pull request, such as ping or release, and the push that deletes a branch.
+*/
+func GetChangeRefs(header map[string][]string, body map[string]interface{}, refs *PullRequestRefs) (string, string, bool, error) {
+	if refs != nil {
+		return refs.BaseSHA, refs.HeadSHA, true, nil
+	}
+	event := githubEvent(header)
+	switch event {
+	case "push":
+		head := bodyString(body, "after")
+		if head == githubNullSHA {
+			return "", "", false, nil
+		}
+		base := bodyString(body, "before")
+		if base == githubNullSHA {
+			base = ""
+		}
+		return base, head, true, nil
+	case "pull_request", "pull_request_review":
+		base := bodyString(body, "pull_request", "base", "sha")
+		head := bodyString(body, "pull_request", "head", "sha")
+		if base == "" || head == "" {
+			return "", "", false, fmt.Errorf("%v message does not contain pull_request.base.sha and pull_request.head.sha", event)
+		}
+		return base, head, true, nil
+	}
+	return "", "", false, nil
+}
+
+/* CompareCommitsFromGithub returns the files changed between the base and head commits, sorted. GitHub lists at most 300 files */
+func CompareCommitsFromGithub(ctx context.Context, owner, repository, base, head, githubURL, user, token string, isEnterprise bool) ([]string, error) {
+	client, err := newGithubClient(githubURL, user, token, isEnterprise)
+	if err != nil {
+		return nil, err
+	}
+	comparison, _, err := client.Repositories.CompareCommits(ctx, owner, repository, base, head)
+	if err != nil {
+		return nil, fmt.Errorf("unable to compare %v...%v of %v/%v: %v", base, head, owner, repository, err)
+	}
+	files := make([]string, 0, len(comparison.Files))
+	for _, file := range comparison.Files {
+		files = append(files, file.GetFilename())
+	}
+	sort.Strings(files)
+	klog.Infof("%v files changed between %v and %v of %v/%v", len(files), base, head, owner, repository)
+	return files, nil
+}
+
+/* ListRepositoryFilesFromGithub returns the files of the tree of a commit, sorted, and whether the tree is complete.
+   GitHub truncates the trees of large repositories.
+*/
+func ListRepositoryFilesFromGithub(ctx context.Context, owner, repository, ref, githubURL, user, token string, isEnterprise bool) ([]string, bool, error) {
+	client, err := newGithubClient(githubURL, user, token, isEnterprise)
+	if err != nil {
+		return nil, false, err
+	}
+	tree, _, err := client.Git.GetTree(ctx, owner, repository, ref, true)
+	if err != nil {
+		return nil, false, fmt.Errorf("unable to list the files of %v of %v/%v: %v", ref, owner, repository, err)
+	}
+	files := make([]string, 0, len(tree.Entries))
+	for _, entry := range tree.Entries {
+		if entry.GetType() == "blob" {
+			files = append(files, entry.GetPath())
+		}
+	}
+	sort.Strings(files)
+	klog.Infof("%v files in %v of %v/%v, truncated: %v", len(files), ref, owner, repository, tree.GetTruncated())
+	return files, !tree.GetTruncated(), nil
+}
+
+/* ListRepositoryFiles returns the files of the repository of a webhook message at commit ref, or at the head of the push or pull request
+   of the event if ref is "", with the credentials of repo. Return whether the list is complete
+*/
+func ListRepositoryFiles(ctx context.Context, kubeClient client.Client, namespace string, repo *eventsv1alpha1.EventGithubRepository, header map[string][]string, body map[string]interface{}, ref string) ([]string, bool, error) {
+	githubURL, owner, name, user, token, isEnterprise, err := getGithubAccess(ctx, kubeClient, namespace, repo, header, body)
+	if err != nil {
+		return nil, false, err
+	}
+	if ref == "" {
+		_, _, _, ref, err = getRepositoryInfo(body, githubEvent(header))
+		if err != nil {
+			return nil, false, err
+		}
+		if ref == "" {
+			return nil, false, fmt.Errorf("the %v event of %v/%v has no commit to list the files of", githubEvent(header), owner, name)
+		}
+	}
+	return ListRepositoryFilesFromGithub(ctx, owner, name, ref, githubURL, user, token, isEnterprise)
+}
+
+/* CompareCommits returns the files changed between the base and head commits of the repository of a webhook message, with the credentials of repo */
+func CompareCommits(ctx context.Context, kubeClient client.Client, namespace string, repo *eventsv1alpha1.EventGithubRepository, header map[string][]string, body map[string]interface{}, base, head string) ([]string, error) {
+	githubURL, owner, name, user, token, isEnterprise, err := getGithubAccess(ctx, kubeClient, namespace, repo, header, body)
+	if err != nil {
+		return nil, err
+	}
+	return CompareCommitsFromGithub(ctx, owner, name, base, head, githubURL, user, token, isEnterprise)
+}
+
+/* Match the / separated segments of a file to those of a pattern. ** matches any number of segments */
+func matchPathSegments(pattern []string, file []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for index := 0; index <= len(file); index++ {
+				if matchPathSegments(pattern[1:], file[index:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(file) == 0 {
+			return false
+		}
+		if matched, err := path.Match(pattern[0], file[0]); err != nil || !matched {
+			return false
+		}
+		pattern = pattern[1:]
+		file = file[1:]
+	}
+	return len(file) == 0
+}
+
+/* MatchPathGlob returns true if a file path of the repository matches a glob. The glob is matched against the whole path.
+   Each segment is matched as by path.Match, and a ** segment matches any number of directories, as in services/api/** for all files under services/api
+*/
+func MatchPathGlob(pattern string, file string) bool {
+	return matchPathSegments(strings.Split(pattern, "/"), strings.Split(file, "/"))
+}
+
+/* FilterPaths returns the files that match any include glob, or all files if include is empty, and match no exclude glob */
+func FilterPaths(files []string, include []string, exclude []string) []string {
+	ret := make([]string, 0)
+	for _, file := range files {
+		included := len(include) == 0
+		for _, pattern := range include {
+			if MatchPathGlob(pattern, file) {
+				included = true
+				break
+			}
+		}
+		if !included {
+			continue
+		}
+		excluded := false
+		for _, pattern := range exclude {
+			if MatchPathGlob(pattern, file) {
+				excluded = true
+				break
+			}
+		}
+		if !excluded {
+			ret = append(ret, file)
+		}
+	}
+	return ret
+}
+
+/* ParentDirectories returns the directories that contain a file, nearest first, ending with ROOT_DIRECTORY */
+func ParentDirectories(file string) []string {
+	ret := make([]string, 0)
+	for dir := path.Dir(file); dir != ROOT_DIRECTORY && dir != "/" && dir != ""; dir = path.Dir(dir) {
+		ret = append(ret, dir)
+	}
+	return append(ret, ROOT_DIRECTORY)
+}
@@ -0,0 +1,153 @@
+package utils_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/kabanero-io/events-operator/pkg/utils"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("TestGithubChanges", func() {
+	pushHeader := map[string][]string{"X-Github-Event": {"push"}}
+
+	It("should list the changed files of the commits of a push", func() {
+		body := map[string]interface{}{
+			"commits": []interface{}{
+				map[string]interface{}{"added": []interface{}{"services/api/app.js"}, "modified": []interface{}{"README.md"}},
+				map[string]interface{}{"modified": []interface{}{"README.md"}, "removed": []interface{}{"services/web/old.js"}},
+			},
+		}
+		files, complete := utils.GetPushChangedFiles(pushHeader, body)
+		Expect(complete).To(BeTrue())
+		Expect(files).To(Equal([]string{"README.md", "services/api/app.js", "services/web/old.js"}))
+
+		/* the payload lists at most 20 commits */
+		commits := make([]interface{}, 20)
+		for index := range commits {
+			commits[index] = map[string]interface{}{"modified": []interface{}{"README.md"}}
+		}
+		_, complete = utils.GetPushChangedFiles(pushHeader, map[string]interface{}{"commits": commits})
+		Expect(complete).To(BeFalse())
+
+		_, complete = utils.GetPushChangedFiles(map[string][]string{"X-Github-Event": {"pull_request"}}, body)
+		Expect(complete).To(BeFalse())
+
+		/* the push that deletes a branch */
+		_, complete = utils.GetPushChangedFiles(pushHeader, map[string]interface{}{"after": "0000000000000000000000000000000000000000", "commits": []interface{}{}})
+		Expect(complete).To(BeFalse())
+	})
+
+	It("should find the commits to compare", func() {
+		body := map[string]interface{}{"before": "6113728f", "after": "0d1a26e6"}
+		base, head, hasChanges, err := utils.GetChangeRefs(pushHeader, body, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(hasChanges).To(BeTrue())
+		Expect(base).To(Equal("6113728f"))
+		Expect(head).To(Equal("0d1a26e6"))
+
+		body["before"] = "0000000000000000000000000000000000000000"
+		base, _, hasChanges, err = utils.GetChangeRefs(pushHeader, body, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(hasChanges).To(BeTrue())
+		Expect(base).To(BeEmpty())
+
+		/* the push that deletes a branch changes no files */
+		_, _, hasChanges, err = utils.GetChangeRefs(pushHeader, map[string]interface{}{"before": "6113728f", "after": "0000000000000000000000000000000000000000"}, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(hasChanges).To(BeFalse())
+
+		prBody := map[string]interface{}{
+			"pull_request": map[string]interface{}{
+				"head": map[string]interface{}{"sha": "ec26c3e5"},
+				"base": map[string]interface{}{"sha": "f95f852b"},
+			},
+		}
+		base, head, _, err = utils.GetChangeRefs(map[string][]string{"X-Github-Event": {"pull_request"}}, prBody, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(base).To(Equal("f95f852b"))
+		Expect(head).To(Equal("ec26c3e5"))
+
+		refs := &utils.PullRequestRefs{Number: 4, HeadSHA: "ec26c3e5", BaseSHA: "f95f852b"}
+		base, head, _, err = utils.GetChangeRefs(map[string][]string{"X-Github-Event": {"issue_comment"}}, nil, refs)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(base).To(Equal("f95f852b"))
+		Expect(head).To(Equal("ec26c3e5"))
+
+		_, _, _, err = utils.GetChangeRefs(map[string][]string{"X-Github-Event": {"pull_request"}}, map[string]interface{}{}, nil)
+		Expect(err).To(HaveOccurred())
+
+		for _, event := range []string{"ping", "release", "create", "issue_comment"} {
+			_, _, hasChanges, err = utils.GetChangeRefs(map[string][]string{"X-Github-Event": {event}}, map[string]interface{}{}, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(hasChanges).To(BeFalse(), event)
+		}
+	})
+
+	It("should match path globs", func() {
+		Expect(utils.MatchPathGlob("services/**", "services/api/src/app.js")).To(BeTrue())
+		Expect(utils.MatchPathGlob("services/**", "docs/index.md")).To(BeFalse())
+		Expect(utils.MatchPathGlob("**/*.md", "README.md")).To(BeTrue())
+		Expect(utils.MatchPathGlob("**/*.md", "services/web/README.md")).To(BeTrue())
+		Expect(utils.MatchPathGlob("services/*/package.json", "services/api/package.json")).To(BeTrue())
+		Expect(utils.MatchPathGlob("services/*/package.json", "services/api/lib/package.json")).To(BeFalse())
+		Expect(utils.MatchPathGlob("*.md", "docs/index.md")).To(BeFalse())
+
+		files := []string{"README.md", "docs/index.md", "services/api/app.js", "services/web/README.md"}
+		Expect(utils.FilterPaths(files, nil, nil)).To(Equal(files))
+		Expect(utils.FilterPaths(files, []string{"services/**"}, []string{"**/*.md"})).To(Equal([]string{"services/api/app.js"}))
+		Expect(utils.FilterPaths(files, nil, []string{"docs/**"})).To(Equal([]string{"README.md", "services/api/app.js", "services/web/README.md"}))
+	})
+
+	It("should list the parent directories of a file", func() {
+		Expect(utils.ParentDirectories("services/api/src/app.js")).To(Equal([]string{"services/api/src", "services/api", "services", "."}))
+		Expect(utils.ParentDirectories("README.md")).To(Equal([]string{"."}))
+	})
+
+	It("should compare commits on GitHub", func() {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/api/v3/repos/kabanero-io/demo/compare/f95f852b...ec26c3e5", func(writer http.ResponseWriter, req *http.Request) {
+			if req.Header.Get("Authorization") != "token installation-token" {
+				writer.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(writer, `{"files": [{"filename": "services/web/src/app.js", "status": "modified"}, {"filename": "README.md", "status": "added"}]}`)
+		})
+		server := httptest.NewServer(mux)
+		defer server.Close()
+
+		files, err := utils.CompareCommitsFromGithub(context.Background(), "kabanero-io", "demo", "f95f852b", "ec26c3e5", server.URL, "", "installation-token", true)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(files).To(Equal([]string{"README.md", "services/web/src/app.js"}))
+
+		_, err = utils.CompareCommitsFromGithub(context.Background(), "kabanero-io", "demo", "f95f852b", "ec26c3e5", server.URL, "", "wrong-token", true)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should list the files of a commit on GitHub", func() {
+		truncated := false
+		mux := http.NewServeMux()
+		mux.HandleFunc("/api/v3/repos/kabanero-io/demo/git/trees/ec26c3e5", func(writer http.ResponseWriter, req *http.Request) {
+			Expect(req.URL.Query().Get("recursive")).To(Equal("1"))
+			fmt.Fprintf(writer, `{"sha": "ec26c3e5", "truncated": %v, "tree": [{"path": "services", "type": "tree"}, {"path": "services/web/.appsody-config.yaml", "type": "blob"}, {"path": ".appsody-config.yaml", "type": "blob"}]}`, truncated)
+		})
+		server := httptest.NewServer(mux)
+		defer server.Close()
+
+		files, complete, err := utils.ListRepositoryFilesFromGithub(context.Background(), "kabanero-io", "demo", "ec26c3e5", server.URL, "", "installation-token", true)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(complete).To(BeTrue())
+		Expect(files).To(Equal([]string{".appsody-config.yaml", "services/web/.appsody-config.yaml"}))
+
+		truncated = true
+		_, complete, err = utils.ListRepositoryFilesFromGithub(context.Background(), "kabanero-io", "demo", "ec26c3e5", server.URL, "", "installation-token", true)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(complete).To(BeFalse())
+
+		_, _, err = utils.ListRepositoryFilesFromGithub(context.Background(), "kabanero-io", "demo", "f95f852b", server.URL, "", "installation-token", true)
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,241 @@
+/*
+Copyright 2020 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+/* ChatOps commands, such as /retest or /build prod, in comments on pull requests.
+   The permission of the author of the comment, and the head of the pull request, are read through the GitHub API,
+   as the issue_comment event contains neither.
+*/
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/go-github/github"
+	eventsv1alpha1 "github.com/kabanero-io/events-operator/pkg/apis/events/v1alpha1"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	GITHUB_PERMISSION_NONE  = "none"
+	GITHUB_PERMISSION_READ  = "read"
+	GITHUB_PERMISSION_WRITE = "write"
+	GITHUB_PERMISSION_ADMIN = "admin"
+)
+
+/* repository permissions from the lowest to the highest */
+var githubPermissionRanks = map[string]int{
+	GITHUB_PERMISSION_NONE:  0,
+	GITHUB_PERMISSION_READ:  1,
+	"triage":                2,
+	GITHUB_PERMISSION_WRITE: 3,
+	"maintain":              4,
+	GITHUB_PERMISSION_ADMIN: 5,
+}
+
+/* The head and base of a pull request */
+type PullRequestRefs struct {
+	Number  int
+	HeadRef string
+	HeadSHA string
+	BaseRef string
+	BaseSHA string
+}
+
+/* A ChatOps command in a comment on a pull request */
+type GithubCommand struct {
+	Name        string           // name of the command, without the leading /
+	Args        []string         // arguments of the command
+	User        string           // login of the author of the comment
+	PullRequest *PullRequestRefs // the pull request commented on
+}
+
+/* ParseGithubCommand returns the name and arguments of the first line of a comment that starts with /, and whether one is found */
+func ParseGithubCommand(comment string) (string, []string, bool) {
+	for _, line := range strings.Split(comment, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "/") {
+			continue
+		}
+		fields := strings.Fields(line[1:])
+		if len(fields) == 0 {
+			continue
+		}
+		return fields[0], fields[1:], true
+	}
+	return "", nil, false
+}
+
+/* GetGithubCommand returns the command of a new comment on a pull request of an issue_comment event, with the number
+   of the pull request. The head and base of the pull request are not set. Return nil if the event is not a new comment
+   on a pull request, or the comment is not a command. Edited comments are ignored, so that commands are not run again.
+*/
+func GetGithubCommand(header map[string][]string, body map[string]interface{}) *GithubCommand {
+	event, ok := header["X-Github-Event"]
+	if !ok || len(event) == 0 || event[0] != "issue_comment" {
+		return nil
+	}
+	if action, _ := body["action"].(string); action != "created" {
+		return nil
+	}
+	issue, _ := body["issue"].(map[string]interface{})
+	if issue == nil || issue["pull_request"] == nil {
+		return nil
+	}
+	var number int
+	switch value := issue["number"].(type) {
+	case float64:
+		number = int(value)
+	case int:
+		number = value
+	case int64:
+		number = int(value)
+	default:
+		return nil
+	}
+	comment, _ := body["comment"].(map[string]interface{})
+	commentBody, _ := comment["body"].(string)
+	name, args, ok := ParseGithubCommand(commentBody)
+	if !ok {
+		return nil
+	}
+	commentUser, _ := comment["user"].(map[string]interface{})
+	login, _ := commentUser["login"].(string)
+	return &GithubCommand{Name: name, Args: args, User: login, PullRequest: &PullRequestRefs{Number: number}}
+}
+
+/* IsValidGithubPermission returns true if permission is a repository permission of GitHub */
+func IsValidGithubPermission(permission string) bool {
+	_, ok := githubPermissionRanks[permission]
+	return ok
+}
+
+/* HasGithubPermission returns true if permission is at least the required permission. Unknown permissions have none */
+func HasGithubPermission(permission string, required string) bool {
+	return githubPermissionRanks[permission] >= githubPermissionRanks[required]
+}
+
+/* Return a client of the GitHub API of githubURL. If user is "", token is a GitHub App installation token */
+func newGithubClient(githubURL, user, token string, isEnterprise bool) (*github.Client, error) {
+	if user == "" {
+		return newGithubAPIClient(githubAPIURL(githubURL, isEnterprise), "token "+token)
+	}
+	tp := github.BasicAuthTransport{
+		Username: user,
+		Password: token,
+	}
+	if isEnterprise {
+		githubURL = githubURL + "/api/v3"
+		return github.NewEnterpriseClient(githubURL, githubURL, tp.Client())
+	}
+	return github.NewClient(tp.Client()), nil
+}
+
+/* GetPullRequestFromGithub returns the head and base of a pull request */
+func GetPullRequestFromGithub(ctx context.Context, owner, repository string, number int, githubURL, user, token string, isEnterprise bool) (*PullRequestRefs, error) {
+	client, err := newGithubClient(githubURL, user, token, isEnterprise)
+	if err != nil {
+		return nil, err
+	}
+	pr, _, err := client.PullRequests.Get(ctx, owner, repository, number)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get pull request %v of %v/%v: %v", number, owner, repository, err)
+	}
+	return &PullRequestRefs{
+		Number:  number,
+		HeadRef: pr.GetHead().GetRef(),
+		HeadSHA: pr.GetHead().GetSHA(),
+		BaseRef: pr.GetBase().GetRef(),
+		BaseSHA: pr.GetBase().GetSHA(),
+	}, nil
+}
+
+/* Permission of a collaborator. role_name distinguishes triage and maintain, which permission reports as read and write */
+type collaboratorPermission struct {
+	Permission string `json:"permission"`
+	RoleName   string `json:"role_name"`
+}
+
+/* GetPermissionFromGithub returns the permission of a user on a repository: admin, maintain, write, triage, read, or none */
+func GetPermissionFromGithub(ctx context.Context, owner, repository, login, githubURL, user, token string, isEnterprise bool) (string, error) {
+	client, err := newGithubClient(githubURL, user, token, isEnterprise)
+	if err != nil {
+		return "", err
+	}
+	/* the client does not decode role_name */
+	req, err := client.NewRequest("GET", fmt.Sprintf("repos/%v/%v/collaborators/%v/permission", owner, repository, login), nil)
+	if err != nil {
+		return "", err
+	}
+	level := &collaboratorPermission{}
+	resp, err := client.Do(ctx, req, level)
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			/* not a collaborator */
+			return GITHUB_PERMISSION_NONE, nil
+		}
+		return "", fmt.Errorf("unable to get permission of %v on %v/%v: %v", login, owner, repository, err)
+	}
+	/* custom roles, and servers without role_name, fall back to the permission */
+	permission := level.Permission
+	if IsValidGithubPermission(level.RoleName) {
+		permission = level.RoleName
+	}
+	klog.Infof("permission of %v on %v/%v: %v", login, owner, repository, permission)
+	return permission, nil
+}
+
+/* Return the URL of the GitHub server of a webhook message, whether it is GitHub Enterprise, and the credentials to access the repository of the message */
+func getGithubAccess(ctx context.Context, kubeClient client.Client, namespace string, repo *eventsv1alpha1.EventGithubRepository, header map[string][]string, body map[string]interface{}) (githubURL, owner, name, user, token string, isEnterprise bool, err error) {
+	hostHeader, isEnterprise := header[http.CanonicalHeaderKey("x-github-enterprise-host")]
+	host := "github.com"
+	if isEnterprise {
+		host = hostHeader[0]
+	}
+	githubURL = "https://" + host
+
+	owner, name, htmlURL, _, err := getRepositoryInfo(body, "")
+	if err != nil {
+		return "", "", "", "", "", false, fmt.Errorf("unable to get repository owner, name, or html_url from webhook message: %v", err)
+	}
+	user, token, err = GetGithubCredentials(ctx, kubeClient, namespace, repo, githubAPIURL(githubURL, isEnterprise), owner, name, htmlURL)
+	if err != nil {
+		return "", "", "", "", "", false, err
+	}
+	return githubURL, owner, name, user, token, isEnterprise, nil
+}
+
+/* GetPullRequest returns the head and base of a pull request of the repository of a webhook message, with the credentials of repo */
+func GetPullRequest(ctx context.Context, kubeClient client.Client, namespace string, repo *eventsv1alpha1.EventGithubRepository, header map[string][]string, body map[string]interface{}, number int) (*PullRequestRefs, error) {
+	githubURL, owner, name, user, token, isEnterprise, err := getGithubAccess(ctx, kubeClient, namespace, repo, header, body)
+	if err != nil {
+		return nil, err
+	}
+	return GetPullRequestFromGithub(ctx, owner, name, number, githubURL, user, token, isEnterprise)
+}
+
+/* GetPermission returns the permission of a user on the repository of a webhook message, with the credentials of repo */
+func GetPermission(ctx context.Context, kubeClient client.Client, namespace string, repo *eventsv1alpha1.EventGithubRepository, header map[string][]string, body map[string]interface{}, login string) (string, error) {
+	githubURL, owner, name, user, token, isEnterprise, err := getGithubAccess(ctx, kubeClient, namespace, repo, header, body)
+	if err != nil {
+		return "", err
+	}
+	return GetPermissionFromGithub(ctx, owner, name, login, githubURL, user, token, isEnterprise)
+}
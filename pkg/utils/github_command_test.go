@@ -0,0 +1,114 @@
+package utils_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/kabanero-io/events-operator/pkg/utils"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("TestGithubCommand", func() {
+	It("should parse the first command of a comment", func() {
+		name, args, ok := utils.ParseGithubCommand("/retest")
+		Expect(ok).To(BeTrue())
+		Expect(name).To(Equal("retest"))
+		Expect(args).To(BeEmpty())
+
+		name, args, ok = utils.ParseGithubCommand("Thanks for the fix.\r\n  /build prod  --force \n/retest")
+		Expect(ok).To(BeTrue())
+		Expect(name).To(Equal("build"))
+		Expect(args).To(Equal([]string{"prod", "--force"}))
+
+		_, _, ok = utils.ParseGithubCommand("Looks good to me. See /docs for details\n/")
+		Expect(ok).To(BeFalse())
+	})
+
+	It("should find commands in new comments on pull requests only", func() {
+		buf, err := ioutil.ReadFile("../eventcel/testdata/github/issue_comment.json")
+		Expect(err).ToNot(HaveOccurred())
+		body := make(map[string]interface{})
+		Expect(json.Unmarshal(buf, &body)).To(Succeed())
+		header := map[string][]string{"X-Github-Event": {"issue_comment"}}
+
+		command := utils.GetGithubCommand(header, body)
+		Expect(command).ToNot(BeNil())
+		Expect(command.Name).To(Equal("retest"))
+		Expect(command.User).To(Equal("hubot"))
+		Expect(command.PullRequest.Number).To(Equal(4))
+
+		Expect(utils.GetGithubCommand(map[string][]string{"X-Github-Event": {"push"}}, body)).To(BeNil())
+
+		body["action"] = "edited"
+		Expect(utils.GetGithubCommand(header, body)).To(BeNil())
+		body["action"] = "created"
+
+		delete(body["issue"].(map[string]interface{}), "pull_request")
+		Expect(utils.GetGithubCommand(header, body)).To(BeNil())
+	})
+
+	It("should compare permissions", func() {
+		Expect(utils.HasGithubPermission("admin", "write")).To(BeTrue())
+		Expect(utils.HasGithubPermission("write", "write")).To(BeTrue())
+		Expect(utils.HasGithubPermission("read", "write")).To(BeFalse())
+		Expect(utils.HasGithubPermission("none", "read")).To(BeFalse())
+		Expect(utils.HasGithubPermission("unknown", "read")).To(BeFalse())
+		Expect(utils.IsValidGithubPermission("maintain")).To(BeTrue())
+		Expect(utils.IsValidGithubPermission("owner")).To(BeFalse())
+	})
+
+	It("should read pull requests and permissions from GitHub", func() {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/api/v3/repos/kabanero-io/demo/pulls/4", func(writer http.ResponseWriter, req *http.Request) {
+			if req.Header.Get("Authorization") != "token installation-token" {
+				writer.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(writer, `{"number": 4, "head": {"ref": "fix/readme", "sha": "ec26c3e5"}, "base": {"ref": "master", "sha": "f95f852b"}}`)
+		})
+		mux.HandleFunc("/api/v3/repos/kabanero-io/demo/collaborators/hubot/permission", func(writer http.ResponseWriter, req *http.Request) {
+			fmt.Fprint(writer, `{"permission": "write", "user": {"login": "hubot"}}`)
+		})
+		mux.HandleFunc("/api/v3/repos/kabanero-io/demo/collaborators/triager/permission", func(writer http.ResponseWriter, req *http.Request) {
+			fmt.Fprint(writer, `{"permission": "read", "role_name": "triage", "user": {"login": "triager"}}`)
+		})
+		mux.HandleFunc("/api/v3/repos/kabanero-io/demo/collaborators/releaser/permission", func(writer http.ResponseWriter, req *http.Request) {
+			fmt.Fprint(writer, `{"permission": "write", "role_name": "release-manager", "user": {"login": "releaser"}}`)
+		})
+		mux.HandleFunc("/api/v3/repos/kabanero-io/demo/collaborators/stranger/permission", func(writer http.ResponseWriter, req *http.Request) {
+			writer.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(writer, `{"message": "Not Found"}`)
+		})
+		server := httptest.NewServer(mux)
+		defer server.Close()
+
+		refs, err := utils.GetPullRequestFromGithub(context.Background(), "kabanero-io", "demo", 4, server.URL, "", "installation-token", true)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(*refs).To(Equal(utils.PullRequestRefs{Number: 4, HeadRef: "fix/readme", HeadSHA: "ec26c3e5", BaseRef: "master", BaseSHA: "f95f852b"}))
+
+		_, err = utils.GetPullRequestFromGithub(context.Background(), "kabanero-io", "demo", 5, server.URL, "", "installation-token", true)
+		Expect(err).To(HaveOccurred())
+
+		permission, err := utils.GetPermissionFromGithub(context.Background(), "kabanero-io", "demo", "hubot", server.URL, "", "installation-token", true)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(permission).To(Equal("write"))
+
+		permission, err = utils.GetPermissionFromGithub(context.Background(), "kabanero-io", "demo", "triager", server.URL, "", "installation-token", true)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(permission).To(Equal("triage"))
+
+		/* custom roles have the permission of their base role */
+		permission, err = utils.GetPermissionFromGithub(context.Background(), "kabanero-io", "demo", "releaser", server.URL, "", "installation-token", true)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(permission).To(Equal("write"))
+
+		permission, err = utils.GetPermissionFromGithub(context.Background(), "kabanero-io", "demo", "stranger", server.URL, "", "installation-token", true)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(permission).To(Equal("none"))
+	})
+})
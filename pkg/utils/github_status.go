@@ -0,0 +1,114 @@
+/*
+Copyright 2020 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/go-github/github"
+	eventsv1alpha1 "github.com/kabanero-io/events-operator/pkg/apis/events/v1alpha1"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	REPORT_STATUS_TYPE_STATUS    = "status"   // commit status
+	REPORT_STATUS_TYPE_CHECK_RUN = "checkRun" // check run. Only GitHub Apps may create check runs
+
+	GITHUB_STATE_PENDING = "pending"
+	GITHUB_STATE_ERROR   = "error"
+
+	githubStatusDescriptionMax = 140 // maximum number of characters of the description of a commit status
+)
+
+/* A commit status or check run to be reported on a commit */
+type GithubStatusReport struct {
+	Type        string // REPORT_STATUS_TYPE_STATUS or REPORT_STATUS_TYPE_CHECK_RUN
+	SHA         string // the commit
+	Branch      string // the branch of the commit, required for check runs
+	State       string // GITHUB_STATE_PENDING or GITHUB_STATE_ERROR
+	Context     string // context of the commit status, or name of the check run
+	Description string
+	TargetURL   string // optional URL linked from the status
+}
+
+/* Truncate a description to max characters, ending with ... if truncated. Multi-byte characters are not split */
+func truncateDescription(description string, max int) string {
+	runes := []rune(description)
+	if len(runes) <= max {
+		return description
+	}
+	return string(runes[:max-3]) + "..."
+}
+
+/* ReportStatusToGithub creates a commit status or check run. If user is "", token is a GitHub App installation token */
+func ReportStatusToGithub(ctx context.Context, owner, repository string, report *GithubStatusReport, githubURL, user, token string, isEnterprise bool) error {
+	client, err := newGithubClient(githubURL, user, token, isEnterprise)
+	if err != nil {
+		return err
+	}
+	var targetURL *string
+	if report.TargetURL != "" {
+		targetURL = github.String(report.TargetURL)
+	}
+
+	switch report.Type {
+	case REPORT_STATUS_TYPE_STATUS, "":
+		description := truncateDescription(report.Description, githubStatusDescriptionMax)
+		status := &github.RepoStatus{
+			State:       github.String(report.State),
+			Context:     github.String(report.Context),
+			Description: github.String(description),
+			TargetURL:   targetURL,
+		}
+		_, _, err = client.Repositories.CreateStatus(ctx, owner, repository, report.SHA, status)
+	case REPORT_STATUS_TYPE_CHECK_RUN:
+		opts := github.CreateCheckRunOptions{
+			Name:       report.Context,
+			HeadBranch: report.Branch,
+			HeadSHA:    report.SHA,
+			DetailsURL: targetURL,
+			Output:     &github.CheckRunOutput{Title: github.String(report.Context), Summary: github.String(report.Description)},
+		}
+		if report.State == GITHUB_STATE_PENDING {
+			opts.Status = github.String("queued")
+		} else {
+			opts.Status = github.String("completed")
+			opts.Conclusion = github.String("failure")
+			opts.CompletedAt = &github.Timestamp{Time: time.Now()}
+		}
+		_, _, err = client.Checks.CreateCheckRun(ctx, owner, repository, opts)
+	default:
+		return fmt.Errorf("unsupported reportStatus type %v. Use %v or %v", report.Type, REPORT_STATUS_TYPE_STATUS, REPORT_STATUS_TYPE_CHECK_RUN)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to report %v %v on commit %v of %v/%v: %v", report.Type, report.State, report.SHA, owner, repository, err)
+	}
+	klog.Infof("reported %v %v %v on commit %v of %v/%v", report.Type, report.Context, report.State, report.SHA, owner, repository)
+	return nil
+}
+
+/* ReportStatus creates a commit status or check run on the repository of a webhook message, with the credentials of repo */
+func ReportStatus(ctx context.Context, kubeClient client.Client, namespace string, repo *eventsv1alpha1.EventGithubRepository, header map[string][]string, body map[string]interface{}, report *GithubStatusReport) error {
+	githubURL, owner, name, user, token, isEnterprise, err := getGithubAccess(ctx, kubeClient, namespace, repo, header, body)
+	if err != nil {
+		return err
+	}
+	return ReportStatusToGithub(ctx, owner, name, report, githubURL, user, token, isEnterprise)
+}
@@ -0,0 +1,91 @@
+package utils_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/kabanero-io/events-operator/pkg/utils"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("TestGithubStatus", func() {
+	var server *httptest.Server
+	var requests map[string]map[string]interface{}
+
+	BeforeEach(func() {
+		requests = make(map[string]map[string]interface{})
+		server = httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+			if req.Method != "POST" || req.Header.Get("Authorization") != "token installation-token" {
+				writer.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			body := make(map[string]interface{})
+			Expect(json.NewDecoder(req.Body).Decode(&body)).To(Succeed())
+			requests[req.URL.Path] = body
+			writer.WriteHeader(http.StatusCreated)
+			writer.Write([]byte(`{"id": 1}`))
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("should create a commit status", func() {
+		report := &utils.GithubStatusReport{
+			Type:        utils.REPORT_STATUS_TYPE_STATUS,
+			SHA:         "ec26c3e5",
+			State:       utils.GITHUB_STATE_PENDING,
+			Context:     "webhook/build",
+			Description: strings.Repeat("x", 200),
+			TargetURL:   "https://dashboard.example.com",
+		}
+		Expect(utils.ReportStatusToGithub(context.Background(), "kabanero-io", "demo", report, server.URL, "", "installation-token", true)).To(Succeed())
+		status := requests["/api/v3/repos/kabanero-io/demo/statuses/ec26c3e5"]
+		Expect(status).ToNot(BeNil())
+		Expect(status["state"]).To(Equal("pending"))
+		Expect(status["context"]).To(Equal("webhook/build"))
+		Expect(status["target_url"]).To(Equal("https://dashboard.example.com"))
+		Expect(status["description"]).To(HaveLen(140))
+
+		/* 140 characters, not bytes */
+		report.Description = strings.Repeat("é", 200)
+		Expect(utils.ReportStatusToGithub(context.Background(), "kabanero-io", "demo", report, server.URL, "", "installation-token", true)).To(Succeed())
+		description := requests["/api/v3/repos/kabanero-io/demo/statuses/ec26c3e5"]["description"].(string)
+		Expect(utf8.ValidString(description)).To(BeTrue())
+		Expect(description).To(Equal(strings.Repeat("é", 137) + "..."))
+	})
+
+	It("should create a failed check run for an error", func() {
+		report := &utils.GithubStatusReport{
+			Type:        utils.REPORT_STATUS_TYPE_CHECK_RUN,
+			SHA:         "ec26c3e5",
+			Branch:      "fix/readme",
+			State:       utils.GITHUB_STATE_ERROR,
+			Context:     "webhook/build",
+			Description: "Unable to send event to dest",
+		}
+		Expect(utils.ReportStatusToGithub(context.Background(), "kabanero-io", "demo", report, server.URL, "", "installation-token", true)).To(Succeed())
+		checkRun := requests["/api/v3/repos/kabanero-io/demo/check-runs"]
+		Expect(checkRun).ToNot(BeNil())
+		Expect(checkRun["name"]).To(Equal("webhook/build"))
+		Expect(checkRun["head_sha"]).To(Equal("ec26c3e5"))
+		Expect(checkRun["head_branch"]).To(Equal("fix/readme"))
+		Expect(checkRun["status"]).To(Equal("completed"))
+		Expect(checkRun["conclusion"]).To(Equal("failure"))
+		Expect(checkRun["output"]).To(HaveKeyWithValue("summary", "Unable to send event to dest"))
+	})
+
+	It("should report errors", func() {
+		report := &utils.GithubStatusReport{Type: "comment", SHA: "ec26c3e5", State: utils.GITHUB_STATE_PENDING}
+		Expect(utils.ReportStatusToGithub(context.Background(), "kabanero-io", "demo", report, server.URL, "", "installation-token", true)).ToNot(Succeed())
+
+		report.Type = utils.REPORT_STATUS_TYPE_STATUS
+		Expect(utils.ReportStatusToGithub(context.Background(), "kabanero-io", "demo", report, server.URL, "", "wrong-token", true)).ToNot(Succeed())
+	})
+})
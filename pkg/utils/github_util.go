@@ -19,8 +19,10 @@ package utils
 import (
 	"context"
 	"fmt"
+	"path"
     "strings"
 	"github.com/google/go-github/github"
+	eventsv1alpha1 "github.com/kabanero-io/events-operator/pkg/apis/events/v1alpha1"
 	// "k8s.io/client-go/kubernetes"
     "sigs.k8s.io/controller-runtime/pkg/client"
 	"k8s.io/klog"
@@ -119,13 +121,20 @@ func getRepositoryInfo(body map[string]interface{}, repositoryEvent string) (str
 
 /*
 DownloadYAML Downloads a YAML file from a git repository.
+  ctx: context of the event being processed. The download stops when it is cancelled
   kubeClient: controller client to API server
   namespace: namespace to look for secret to github
-  secretName name of the secret containing the token to access github
+  repo: GitHub repository configuration of the mediator, with the secret or GitHub App to access github. If nil, the secret is found by the URL of the repository
   header: HTTP header from webhook
   bodyMap: HTTP  message body from webhook
+  ref: commit to download the file from. If "", the head commit of a push or pull_request event, or the default branch for other events
 */
-func DownloadYAML(kubeClient client.Client, namespace string, secretName string, header map[string][]string, bodyMap map[string]interface{}, fileName string) (map[string]interface{}, bool, error) {
+func DownloadYAML(ctx context.Context, kubeClient client.Client, namespace string, repo *eventsv1alpha1.EventGithubRepository, header map[string][]string, bodyMap map[string]interface{}, fileName string, ref string) (map[string]interface{}, bool, error) {
+	return downloadYAML(ctx, nil, kubeClient, namespace, repo, header, bodyMap, fileName, ref)
+}
+
+/* Download a YAML file from a git repository, through the cache if it is not nil */
+func downloadYAML(ctx context.Context, cache *RepositoryFileCache, kubeClient client.Client, namespace string, repo *eventsv1alpha1.EventGithubRepository, header map[string][]string, bodyMap map[string]interface{}, fileName string, ref string) (map[string]interface{}, bool, error) {
 
 	hostHeader, isEnterprise := header[http.CanonicalHeaderKey("x-github-enterprise-host")]
 	var host string
@@ -137,56 +146,49 @@ func DownloadYAML(kubeClient client.Client, namespace string, secretName string,
 
 	repositoryEvent := header["X-Github-Event"][0]
 
-	owner, name, htmlURL, ref, err := getRepositoryInfo(bodyMap, repositoryEvent)
+	owner, name, htmlURL, eventRef, err := getRepositoryInfo(bodyMap, repositoryEvent)
 	if err != nil {
 		return nil, false, fmt.Errorf("unable to get repository owner, name, or html_url from webhook message: %v", err)
 	}
-
-	user, token, err := GetGitHubSecret(kubeClient, namespace, secretName,  htmlURL)
-	if err != nil {
-		return nil, false, fmt.Errorf("unable to get user/token secret for URL %s: %v", htmlURL, err)
+	if ref == "" {
+		ref = eventRef
 	}
 
 	githubURL := "https://" + host
+	download := func() ([]byte, bool, error) {
+		user, token, err := GetGithubCredentials(ctx, kubeClient, namespace, repo, githubAPIURL(githubURL, isEnterprise), owner, name, htmlURL)
+		if err != nil {
+			return nil, false, err
+		}
+		return DownloadFileFromGithub(ctx, owner, name, fileName, ref, githubURL, user, token, isEnterprise)
+	}
 
-	bytes, found, err := DownloadFileFromGithub(owner, name, fileName, ref, githubURL, user, token, isEnterprise)
-	if err != nil {
+	var bytes []byte
+	var found bool
+	if cache != nil {
+		key := RepositoryFileKey{Host: host, Owner: owner, Repo: name, Ref: ref, File: fileName}
+		bytes, found, err = cache.GetOrDownload(key, download)
+	} else {
+		bytes, found, err = download()
+	}
+	if err != nil || !found {
 		return nil, found, err
 	}
 	retMap, err := YAMLToMap(bytes)
 	return retMap, found, err
 }
 
-// DownloadFileFromGithub Downloads a file and returns: bytes of the file, true if file exists, and any error
-func DownloadFileFromGithub(owner, repository, fileName, ref, githubURL, user, token string, isEnterprise bool) ([]byte, bool, error) {
+// DownloadFileFromGithub Downloads a file and returns: bytes of the file, true if file exists, and any error.
+// If user is "", token is a GitHub App installation token.
+func DownloadFileFromGithub(ctx context.Context, owner, repository, fileName, ref, githubURL, user, token string, isEnterprise bool) ([]byte, bool, error) {
 
 //	if klog.V(5) {
 		klog.Infof("downloadFileFromGithub owner: %v, repo: %v, file: %v, ref: %v, githubURL: %v, user: %v, isEnterprise: %v", owner, repository, fileName, ref, githubURL, user, isEnterprise)
 //	}
 
-	ctx := context.Background()
-
-	tp := github.BasicAuthTransport{
-		Username: user,
-		Password: token,
-	}
-	/*
-		tokenService := oauth2.StaticTokenSource(
-			&oauth2.Token{AccessToken: token},
-		)
-		tokenClient := oauth2.NewClient(ctx, tokenService)
-	*/
-
-	var err error
-	var client *github.Client
-	if isEnterprise {
-		githubURL = githubURL + "/api/v3"
-		client, err = github.NewEnterpriseClient(githubURL, githubURL, tp.Client())
-		if err != nil {
-			return nil, false, err
-		}
-	} else {
-		client = github.NewClient(tp.Client())
+	client, err := newGithubClient(githubURL, user, token, isEnterprise)
+	if err != nil {
+		return nil, false, err
 	}
 
 	var options *github.RepositoryContentGetOptions
@@ -231,6 +233,62 @@ func DownloadFileFromGithub(owner, repository, fileName, ref, githubURL, user, t
 
 }
 
+/* Return the html_url of the repository of a webhook message body, or "" if not found */
+func GetRepositoryURL(body map[string]interface{}) string {
+	repository, ok := body["repository"].(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	htmlURL, _ := repository["html_url"].(string)
+	return htmlURL
+}
+
+/* Return true if the repository entry of a mediator matches the html_url of a repository */
+func repositoryMatches(repo *eventsv1alpha1.EventRepository, htmlURL string) bool {
+	if repo.UrlPattern == "" && repo.Org == "" {
+		return true
+	}
+	if htmlURL == "" {
+		return false
+	}
+	if repo.UrlPattern != "" {
+		matched, err := path.Match(repo.UrlPattern, strings.TrimSuffix(htmlURL, "/"))
+		if err != nil {
+			klog.Errorf("invalid urlPattern %v of repository: %v", repo.UrlPattern, err)
+			return false
+		}
+		if !matched {
+			return false
+		}
+	}
+	if repo.Org != "" {
+		_, org, _, err := ParseGithubURL(htmlURL)
+		if err != nil || !strings.EqualFold(org, repo.Org) {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+MatchGithubRepositories returns the GitHub configuration of the repositories of a mediator that match a repository, in the order declared.
+An entry matches if its urlPattern, if specified, matches htmlURL, and its org, if specified, is the owner of the repository.
+An entry without urlPattern and org matches any repository.
+*/
+func MatchGithubRepositories(repositories *[]eventsv1alpha1.EventRepository, htmlURL string) []*eventsv1alpha1.EventGithubRepository {
+	ret := make([]*eventsv1alpha1.EventGithubRepository, 0)
+	if repositories == nil {
+		return ret
+	}
+	for index := range *repositories {
+		repo := &(*repositories)[index]
+		if repo.Github != nil && repositoryMatches(repo, htmlURL) {
+			ret = append(ret, repo.Github)
+		}
+	}
+	return ret
+}
+
 /* Return true if header is from Github event */
 func IsHeaderGithub(header map[string][]string) bool {
 
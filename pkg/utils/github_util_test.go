@@ -0,0 +1,44 @@
+package utils_test
+
+import (
+	eventsv1alpha1 "github.com/kabanero-io/events-operator/pkg/apis/events/v1alpha1"
+	"github.com/kabanero-io/events-operator/pkg/utils"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("TestMatchGithubRepositories", func() {
+	repositories := &[]eventsv1alpha1.EventRepository{
+		{UrlPattern: "https://github.com/kabanero-io/*", Github: &eventsv1alpha1.EventGithubRepository{Secret: "kabanero"}},
+		{Org: "Appsody", Github: &eventsv1alpha1.EventGithubRepository{Secret: "appsody"}},
+		{UrlPattern: "https://github.ibm.com/*/*", Org: "kabanero", Github: &eventsv1alpha1.EventGithubRepository{Secret: "ibm"}},
+		{Github: &eventsv1alpha1.EventGithubRepository{Secret: "default"}},
+	}
+	secrets := func(htmlURL string) []string {
+		ret := make([]string, 0)
+		for _, repo := range utils.MatchGithubRepositories(repositories, htmlURL) {
+			ret = append(ret, repo.Secret)
+		}
+		return ret
+	}
+
+	It("should match repositories by url pattern and org, in the order declared", func() {
+		Expect(secrets("https://github.com/kabanero-io/demo")).To(Equal([]string{"kabanero", "default"}))
+		Expect(secrets("https://github.com/appsody/stacks")).To(Equal([]string{"appsody", "default"}))
+		Expect(secrets("https://github.ibm.com/kabanero/demo")).To(Equal([]string{"ibm", "default"}))
+		Expect(secrets("https://github.ibm.com/other/demo")).To(Equal([]string{"default"}))
+		Expect(secrets("")).To(Equal([]string{"default"}))
+	})
+
+	It("should not match any repository without a matching entry", func() {
+		specific := (*repositories)[0:3]
+		Expect(utils.MatchGithubRepositories(&specific, "https://github.com/other/demo")).To(BeEmpty())
+		Expect(utils.MatchGithubRepositories(nil, "https://github.com/other/demo")).To(BeEmpty())
+	})
+
+	It("should find the url of the repository of a webhook message", func() {
+		body := map[string]interface{}{"repository": map[string]interface{}{"html_url": "https://github.com/kabanero-io/demo"}}
+		Expect(utils.GetRepositoryURL(body)).To(Equal("https://github.com/kabanero-io/demo"))
+		Expect(utils.GetRepositoryURL(map[string]interface{}{})).To(BeEmpty())
+	})
+})
@@ -151,12 +151,13 @@ GetGitHubSecret will return the username and token of a secret whose annotation'
 Note that a secret with the `kabanero.io/git-*` annotation is preferred over one with `tekton.dev/git-*`.
 Return: username, token, error
 Input:
+    ctx: context of the request
     kubeClient: client to API server
     namespace: namespace to look for secret
     name: name of the secret, or "" to auto-scan
     repoURL: name of the repository
 */
-func GetGitHubSecret(kubeClient client.Client, namespace string, name string, repoURL string) (string, string, error) {
+func GetGitHubSecret(ctx context.Context, kubeClient client.Client, namespace string, name string, repoURL string) (string, string, error) {
 	// TODO: Change to controller pattern and cache the secrets.
 	if klog.V(8) {
 		klog.Infof("GetGitHubSecret namespace: %s, repoURL: %s", namespace, repoURL)
@@ -166,7 +167,7 @@ func GetGitHubSecret(kubeClient client.Client, namespace string, name string, re
         /* Look for specific secret */
         objectKey := client.ObjectKey { Namespace: namespace, Name: name }
         secret := &corev1.Secret{}
-        err := kubeClient.Get(context.Background(), objectKey, secret)
+        err := kubeClient.Get(ctx, objectKey, secret)
         if err != nil {
             return "", "", err
         }
@@ -178,7 +179,7 @@ func GetGitHubSecret(kubeClient client.Client, namespace string, name string, re
     } else {
         secrets := &corev1.SecretList{}
         options := []client.ListOption{client.InNamespace(namespace)}
-        err := kubeClient.List(context.Background(), secrets, options...)
+        err := kubeClient.List(ctx, secrets, options...)
         if err != nil {
             return "", "", err
         }
@@ -249,31 +250,20 @@ func  imageMatches(repoStackImage string,  images []kabanerov1alpha2.ImageStatus
 }
 
 
-func findEventListener(versionStatus *kabanerov1alpha2.StackVersionStatus) (string, string) {
-    for _, pipeline := range versionStatus.Pipelines {
-        for _, activeAsset := range pipeline.ActiveAssets {
-             if activeAsset.Group == TRIGGER_TEKTON_DEV && activeAsset.Kind == EVENT_LISTENER &&
-                     /* TODO: kludge to ignore kustomize listeners */ strings.Index(activeAsset.Name, "kustomize") < 0  {
-                  /* found */
-                  return activeAsset.Namespace, activeAsset.Name
-             }
-        }
-    }
-    return "", ""
-}
-
 /* Find the Kabanero Tekton event listener for stack 
 input:
+   ctx: context of the event being processed
    kubeClient: client to API server
    namespace: namespace of stack to search for event listener
    repoStackImage:  the name  of image as specified in .appsody-config.yaml. For example, "docker.io/appsody/nodejs:0.3"
-   repoStackVersion: the semantic version for the stack, as specified in .appsody-config.yaml. For example "0.3"
+   repoStackVersion: the version constraint for the stack, as specified in .appsody-config.yaml, or by the mediator. For example "0.3", "^0.3", or ">=0.2 <0.4"
+   selection: the event type and listener selectors of the mediator, or nil
 Return:
    name of listener, or "" if no match
    exact version found
    error : if any error occurred when matching the repository to an event listener
 */
-func FindEventListenerForStack(kubeClient client.Client, namespace string, repoStackImage string, repoStackVersion string) (string, string, error) {
+func FindEventListenerForStack(ctx context.Context, kubeClient client.Client, namespace string, repoStackImage string, repoStackVersion string, selection *ListenerSelection) (string, string, error) {
     /*
     if true {
         return "http://el-listener-mcheng.tekton-pipelines.svc.cluster.local:8080", "0.2.0", nil
@@ -282,74 +272,107 @@ func FindEventListenerForStack(kubeClient client.Client, namespace string, repoS
 
     // klog.Infof("FindEventListenerForStack namespace: %s, reposStackImage: %v, repoStackVersion: %v", namespace, repoStackImage, repoStackVersion)
 
-    repoVersion, err := semverimage.NewVersion(repoStackVersion)
+    constraint, err := semverimage.NewConstraint(repoStackVersion)
     if err != nil {
         return "", "", err
     }
+    return findEventListenerForStack(ctx, kubeClient, namespace, repoStackImage + ":" + repoStackVersion, constraint, selection,
+        func(stack *kabanerov1alpha2.Stack, versionStatus *kabanerov1alpha2.StackVersionStatus) bool {
+            // klog.Infof("repo image: %v, stack images: %v", repoStackImage, versionStatus.Images)
+            return imageMatches(repoStackImage, versionStatus.Images)
+        })
+}
+
+/* Find the Kabanero Tekton event listener for a stack referenced by name, such as the parent of a devfile
+input:
+   ctx: context of the event being processed
+   kubeClient: client to API server
+   namespace: namespace of stack to search for event listener
+   stackName: the name of the Stack resource, or of its spec. For example, "nodejs"
+   stackVersion: the version constraint for the stack. For example "0.3", or "^0.3". Any version matches if "" or "latest"
+   selection: the event type and listener selectors of the mediator, or nil
+Return:
+   name of listener, or "" if no match
+   exact version found
+   error : if any error occurred when matching the repository to an event listener
+*/
+func FindEventListenerForStackName(ctx context.Context, kubeClient client.Client, namespace string, stackName string, stackVersion string, selection *ListenerSelection) (string, string, error) {
+    var constraint *semverimage.Constraint
+    if stackVersion != "" && stackVersion != "latest" {
+        var err error
+        constraint, err = semverimage.NewConstraint(stackVersion)
+        if err != nil {
+            return "", "", err
+        }
+    }
+    return findEventListenerForStack(ctx, kubeClient, namespace, stackName + ":" + stackVersion, constraint, selection,
+        func(stack *kabanerov1alpha2.Stack, versionStatus *kabanerov1alpha2.StackVersionStatus) bool {
+            return stack.Name == stackName || stack.Spec.Name == stackName
+        })
+}
+
+/* Find the event listener of the highest active version of the stacks that matches, and satisfies the constraint, as selected for the event.
+   Any version satisfies a nil constraint */
+func findEventListenerForStack(ctx context.Context, kubeClient client.Client, namespace string, description string, constraint *semverimage.Constraint, selection *ListenerSelection,
+        matches func(*kabanerov1alpha2.Stack, *kabanerov1alpha2.StackVersionStatus) bool) (string, string, error) {
     stacks := &kabanerov1alpha2.StackList{}
     options := []client.ListOption{client.InNamespace(namespace)}
-    err = kubeClient.List(context.Background(), stacks, options...) 
+    err := kubeClient.List(ctx, stacks, options...) 
     if err != nil {
 		return "", "", err
 	}
 
-    currentListener := ""
-    currentNamespace := ""
-    currentVersion, _ := semverimage.NewVersion("0.0.0")
-    for _, stack  := range stacks.Items {
-        // klog.Infof("Checking stack: %v/%v", stack.Namespace, stack.Name)
-        status := stack.Status
-        for _, versionStatus := range status.Versions {
-           // klog.Infof("Stack status: %v", versionStatus.Status)
-           if versionStatus.Status != ACTIVE  {
-                continue
-           }
-           // klog.Infof("repo image: %v, stack images: %v", repoStackImage, versionStatus.Images)
-           if  !imageMatches(repoStackImage, versionStatus.Images)  {
-               continue
-           }
-           matchedVersion, err := semverimage.NewVersion(versionStatus.Version)
-           if err != nil {
-                return "", "", err
-           }
-           // klog.Infof("matching repoVersion : %v, stack Version : %v", repoVersion, matchedVersion)
-           matched := repoVersion.IsCompatible(matchedVersion)
-           if !matched {
-                continue
-           }
-           // klog.Infof("calling findEventListener for %v", versionStatus)
-           matchedNamespace, matchedListener := findEventListener(&versionStatus)
-           if  matchedListener == "" {
-                 continue
-           }
-           if currentListener == "" || matchedVersion.GreaterThan(currentVersion) {
-               currentListener = matchedListener
-               currentNamespace = matchedNamespace
-               currentVersion = matchedVersion
-           }
+    /* labels and annotations of the listeners, read only if a rule matches them */
+    listenerMeta := func(listenerNamespace string, listenerName string) (map[string]string, map[string]string, bool) {
+        listener := &triggers.EventListener{}
+        err := kubeClient.Get(ctx, client.ObjectKey { Namespace: listenerNamespace, Name: listenerName }, listener)
+        if err != nil {
+            klog.Errorf("Unable to read listener %v/%v: %v", listenerNamespace, listenerName, err)
+            return nil, nil, false
         }
+        return listener.Labels, listener.Annotations, true
     }
 
-    if currentListener == "" {
-        klog.Errorf("Unable to find listener from stack for appsody repo %v:%v", repoStackImage, repoStackVersion)
-        return currentListener, currentVersion.String(), nil
+    /* the same selection as the stack index */
+    candidates := make([]*stackIndexEntry, 0)
+    for stackIndex := range stacks.Items {
+        candidates = append(candidates, activeStackVersions(&stacks.Items[stackIndex], matches)...)
+    }
+    sortStackVersions(candidates)
+    entry, asset, ok := selectStackListener(candidates, constraint, selection, listenerMeta)
+    if !ok {
+        klog.Errorf("Unable to find listener from stack for repo %v", description)
+        return "", "0.0.0", nil
     }
 
-
-    urlStr, err := EventListenerURL(kubeClient, currentNamespace, currentListener)
+    urlStr, err := EventListenerURL(ctx, kubeClient, asset.Namespace, asset.Name)
     if err != nil {
         /* not found */
-        klog.Errorf("Unable to find listener %v in namespace %v. Error: %v", currentListener, currentNamespace, err)
-        return "", currentVersion.String(), err
+        klog.Errorf("Unable to find listener %v in namespace %v. Error: %v", asset.Name, asset.Namespace, err)
+        return "", entry.version.String(), err
+    }
+    return urlStr, entry.version.String(), nil
+}
+
+/* StackVersionConstraint returns the version constraint of a stack: the version of the first entry of stackVersions for the stack, if any, or version */
+func StackVersionConstraint(stackVersions *[]eventsv1alpha1.EventStackVersion, stack string, version string) string {
+    if stackVersions == nil {
+        return version
+    }
+    for _, stackVersion := range *stackVersions {
+        if stackVersion.Stack == stack {
+            klog.Infof("version %v of stack %v overridden by the mediator with %v", version, stack, stackVersion.Version)
+            return stackVersion.Version
+        }
     }
-    return urlStr, currentVersion.String(), nil
+    return version
 }
 
 /* FInd URL for EventListener */
-func EventListenerURL(kubeClient client.Client, namespace string, name string) (string, error) {
+func EventListenerURL(ctx context.Context, kubeClient client.Client, namespace string, name string) (string, error) {
     objectKey := client.ObjectKey { Namespace: namespace, Name: name }
     listener := &triggers.EventListener{}
-    err := kubeClient.Get(context.Background(), objectKey, listener)
+    err := kubeClient.Get(ctx, objectKey, listener)
     if err != nil {
         return "", fmt.Errorf("Unable to find listern %v/%v, error: %v", namespace, name, err)
     }
@@ -362,7 +385,7 @@ func EventListenerURL(kubeClient client.Client, namespace string, name string) (
 }
 
 /* Update status for mediator */
-func UpdateStatus(ctrlClient client.Client, namespace string, name string, summary []eventsv1alpha1.EventStatusSummary) error {
+func UpdateStatus(ctrlClient client.Client, namespace string, name string, status *eventsv1alpha1.EventMediatorStatus) error {
 
     objectKey := client.ObjectKey { Namespace: namespace, Name: name }
     mediator := &eventsv1alpha1.EventMediator {}
@@ -370,7 +393,8 @@ func UpdateStatus(ctrlClient client.Client, namespace string, name string, summa
     if err != nil {
         return err
     }
-    mediator.Status.Summary = summary
+    mediator.Status.Summary = status.Summary
+    mediator.Status.Conditions = status.Conditions
     err = ctrlClient.Status().Update(context.Background(),mediator)
     if err != nil {
          return err
@@ -380,7 +404,7 @@ func UpdateStatus(ctrlClient client.Client, namespace string, name string, summa
 
 
 /* Get value of Webhook secret */
-func GetWebhookSecret(kubeClient client.Client, namespace string, name string) (string,  error) {
+func GetWebhookSecret(ctx context.Context, kubeClient client.Client, namespace string, name string) (string,  error) {
 	if klog.V(8) {
 		klog.Infof("GetWebhookSecret namespace: %s, name: %s", namespace, name)
 	}
@@ -392,7 +416,7 @@ func GetWebhookSecret(kubeClient client.Client, namespace string, name string) (
     /* Look for specific secret */
     objectKey := client.ObjectKey { Namespace: namespace, Name: name }
     secret := &corev1.Secret{}
-    err := kubeClient.Get(context.Background(), objectKey, secret)
+    err := kubeClient.Get(ctx, objectKey, secret)
     if err != nil {
         return "", fmt.Errorf("Secret %s/%s not found", namespace, name)
     }
@@ -0,0 +1,180 @@
+/*
+Copyright 2020 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+/* Selection of the event listener of a stack version, when its pipelines have several EventListener assets.
+   Rules of the mediator are tried first, then the rules of the annotation of the stack, then the default rule.
+*/
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+
+	eventsv1alpha1 "github.com/kabanero-io/events-operator/pkg/apis/events/v1alpha1"
+	kabanerov1alpha2 "github.com/kabanero-io/kabanero-operator/pkg/apis/kabanero/v1alpha2"
+	"k8s.io/klog"
+)
+
+const (
+	LISTENER_SELECTORS_ANNOTATION = "events.kabanero.io/listener-selectors" // annotation of a Stack with a JSON list of listener selectors
+)
+
+/* Applies when no other rule does. Listeners of kustomize pipelines deploy rather than build */
+var defaultListenerSelector = eventsv1alpha1.EventListenerSelector{ExcludeListeners: []string{"*kustomize*"}}
+
+/* An EventListener asset of a pipeline of a stack version */
+type ListenerAsset struct {
+	Pipeline  string
+	Namespace string
+	Name      string
+}
+
+/* How to select the listener of a stack version for an event */
+type ListenerSelection struct {
+	EventType string                                 // push, pull_request, tag, or another GitHub event. Rules for specific events do not apply if ""
+	Selectors []eventsv1alpha1.EventListenerSelector // rules of the mediator
+}
+
+/* Returns the labels and annotations of an EventListener, and whether it exists */
+type ListenerMetaFunc func(namespace string, name string) (map[string]string, map[string]string, bool)
+
+/* ListenerAssets returns the EventListener assets of the pipelines of a stack version, in order */
+func ListenerAssets(versionStatus *kabanerov1alpha2.StackVersionStatus) []ListenerAsset {
+	ret := make([]ListenerAsset, 0)
+	for _, pipeline := range versionStatus.Pipelines {
+		for _, activeAsset := range pipeline.ActiveAssets {
+			if activeAsset.Group == TRIGGER_TEKTON_DEV && activeAsset.Kind == EVENT_LISTENER {
+				ret = append(ret, ListenerAsset{Pipeline: pipeline.Name, Namespace: activeAsset.Namespace, Name: activeAsset.Name})
+			}
+		}
+	}
+	return ret
+}
+
+/* StackListenerSelectors returns the rules of the LISTENER_SELECTORS_ANNOTATION of a stack, if any */
+func StackListenerSelectors(stack *kabanerov1alpha2.Stack) ([]eventsv1alpha1.EventListenerSelector, error) {
+	value, ok := stack.Annotations[LISTENER_SELECTORS_ANNOTATION]
+	if !ok {
+		return nil, nil
+	}
+	ret := make([]eventsv1alpha1.EventListenerSelector, 0)
+	if err := json.Unmarshal([]byte(value), &ret); err != nil {
+		return nil, fmt.Errorf("invalid annotation %v of stack %v/%v: %v", LISTENER_SELECTORS_ANNOTATION, stack.Namespace, stack.Name, err)
+	}
+	return ret, nil
+}
+
+/* StackKeys returns the names a rule may refer to a stack version by: its images, and the names of the stack */
+func StackKeys(stack *kabanerov1alpha2.Stack, versionStatus *kabanerov1alpha2.StackVersionStatus) []string {
+	ret := make([]string, 0, len(versionStatus.Images)+2)
+	for _, image := range versionStatus.Images {
+		ret = append(ret, image.Image)
+	}
+	ret = append(ret, stack.Name)
+	if stack.Spec.Name != "" && stack.Spec.Name != stack.Name {
+		ret = append(ret, stack.Spec.Name)
+	}
+	return ret
+}
+
+func containsString(values []string, value string) bool {
+	for _, elem := range values {
+		if elem == value {
+			return true
+		}
+	}
+	return false
+}
+
+/* Return true if name matches any of the globs */
+func matchAnyGlob(globs []string, name string) bool {
+	for _, glob := range globs {
+		if matched, err := path.Match(glob, name); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+/* Return true if values contains all of the required keys and values */
+func matchMap(required map[string]string, values map[string]string) bool {
+	for key, value := range required {
+		if actual, ok := values[key]; !ok || actual != value {
+			return false
+		}
+	}
+	return true
+}
+
+/* Return true if the rule applies to the stack and the event */
+func selectorApplies(selector *eventsv1alpha1.EventListenerSelector, stackKeys []string, eventType string) bool {
+	if selector.Stack != "" && !containsString(stackKeys, selector.Stack) {
+		return false
+	}
+	return len(selector.EventTypes) == 0 || containsString(selector.EventTypes, eventType)
+}
+
+/* Return true if the rule selects the asset */
+func selectorMatches(selector *eventsv1alpha1.EventListenerSelector, asset *ListenerAsset, listenerMeta ListenerMetaFunc) bool {
+	if len(selector.Pipelines) > 0 && !matchAnyGlob(selector.Pipelines, asset.Pipeline) {
+		return false
+	}
+	if len(selector.Listeners) > 0 && !matchAnyGlob(selector.Listeners, asset.Name) {
+		return false
+	}
+	if matchAnyGlob(selector.ExcludeListeners, asset.Name) {
+		return false
+	}
+	if len(selector.MatchLabels) == 0 && len(selector.MatchAnnotations) == 0 {
+		return true
+	}
+	labels, annotations, ok := listenerMeta(asset.Namespace, asset.Name)
+	return ok && matchMap(selector.MatchLabels, labels) && matchMap(selector.MatchAnnotations, annotations)
+}
+
+/* SelectListener returns the listener of a stack version for an event: the first asset selected by the first rule that applies and selects an asset.
+   The rules of the selection are tried first, then stackSelectors, then the default rule. Return false if no asset is selected
+*/
+func SelectListener(assets []ListenerAsset, stackKeys []string, selection *ListenerSelection, stackSelectors []eventsv1alpha1.EventListenerSelector, listenerMeta ListenerMetaFunc) (ListenerAsset, bool) {
+	if len(assets) == 0 {
+		return ListenerAsset{}, false
+	}
+	eventType := ""
+	selectors := make([]eventsv1alpha1.EventListenerSelector, 0)
+	if selection != nil {
+		eventType = selection.EventType
+		selectors = append(selectors, selection.Selectors...)
+	}
+	selectors = append(selectors, stackSelectors...)
+	selectors = append(selectors, defaultListenerSelector)
+	for index := range selectors {
+		selector := &selectors[index]
+		if !selectorApplies(selector, stackKeys, eventType) {
+			continue
+		}
+		for _, asset := range assets {
+			if selectorMatches(selector, &asset, listenerMeta) {
+				if klog.V(5) {
+					klog.Infof("Listener %v/%v of pipeline %v selected by rule %v for %v event", asset.Namespace, asset.Name, asset.Pipeline, index, eventType)
+				}
+				return asset, true
+			}
+		}
+	}
+	return ListenerAsset{}, false
+}
@@ -0,0 +1,97 @@
+package utils_test
+
+import (
+	eventsv1alpha1 "github.com/kabanero-io/events-operator/pkg/apis/events/v1alpha1"
+	"github.com/kabanero-io/events-operator/pkg/utils"
+	kabanerov1alpha2 "github.com/kabanero-io/kabanero-operator/pkg/apis/kabanero/v1alpha2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("TestListenerSelect", func() {
+	assets := []utils.ListenerAsset{
+		{Pipeline: "deploy", Namespace: "tekton-pipelines", Name: "listener-kustomize-031"},
+		{Pipeline: "build-push", Namespace: "tekton-pipelines", Name: "listener-build-031"},
+		{Pipeline: "pull-request", Namespace: "tekton-pipelines", Name: "listener-pr-031"},
+	}
+	stackKeys := []string{"docker.io/kabanero/nodejs", "nodejs"}
+	listenerMeta := func(namespace string, name string) (map[string]string, map[string]string, bool) {
+		if name == "listener-pr-031" {
+			return map[string]string{"events.kabanero.io/event-type": "pull_request"}, nil, true
+		}
+		return nil, map[string]string{"owner": "kabanero"}, true
+	}
+
+	It("should skip kustomize listeners by default", func() {
+		asset, ok := utils.SelectListener(assets, stackKeys, nil, nil, listenerMeta)
+		Expect(ok).To(BeTrue())
+		Expect(asset.Name).To(Equal("listener-build-031"))
+
+		_, ok = utils.SelectListener(assets[:1], stackKeys, nil, nil, listenerMeta)
+		Expect(ok).To(BeFalse())
+	})
+
+	It("should select listeners by event type, pipeline, and labels", func() {
+		selection := &utils.ListenerSelection{
+			EventType: "pull_request",
+			Selectors: []eventsv1alpha1.EventListenerSelector{
+				{Stack: "java", Listeners: []string{"*"}},
+				{EventTypes: []string{"pull_request"}, MatchLabels: map[string]string{"events.kabanero.io/event-type": "pull_request"}},
+				{EventTypes: []string{"tag"}, Pipelines: []string{"deploy"}},
+			},
+		}
+		asset, ok := utils.SelectListener(assets, stackKeys, selection, nil, listenerMeta)
+		Expect(ok).To(BeTrue())
+		Expect(asset.Name).To(Equal("listener-pr-031"))
+
+		selection.EventType = "tag"
+		asset, ok = utils.SelectListener(assets, stackKeys, selection, nil, listenerMeta)
+		Expect(ok).To(BeTrue())
+		Expect(asset.Name).To(Equal("listener-kustomize-031"))
+
+		/* a rule that selects nothing falls through to the next one */
+		selection.EventType = "push"
+		selection.Selectors = []eventsv1alpha1.EventListenerSelector{{Pipelines: []string{"release-*"}}}
+		asset, ok = utils.SelectListener(assets, stackKeys, selection, nil, listenerMeta)
+		Expect(ok).To(BeTrue())
+		Expect(asset.Name).To(Equal("listener-build-031"))
+	})
+
+	It("should apply the rules of the annotation of the stack after those of the mediator", func() {
+		stack := &kabanerov1alpha2.Stack{ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "kabanero",
+			Name:        "nodejs",
+			Annotations: map[string]string{utils.LISTENER_SELECTORS_ANNOTATION: `[{"eventTypes": ["push"], "listeners": ["listener-pr-*"]}]`},
+		}}
+		stackSelectors, err := utils.StackListenerSelectors(stack)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stackSelectors).To(HaveLen(1))
+
+		asset, ok := utils.SelectListener(assets, stackKeys, &utils.ListenerSelection{EventType: "push"}, stackSelectors, listenerMeta)
+		Expect(ok).To(BeTrue())
+		Expect(asset.Name).To(Equal("listener-pr-031"))
+
+		selection := &utils.ListenerSelection{
+			EventType: "push",
+			Selectors: []eventsv1alpha1.EventListenerSelector{{MatchAnnotations: map[string]string{"owner": "kabanero"}, ExcludeListeners: []string{"*kustomize*"}}},
+		}
+		asset, ok = utils.SelectListener(assets, stackKeys, selection, stackSelectors, listenerMeta)
+		Expect(ok).To(BeTrue())
+		Expect(asset.Name).To(Equal("listener-build-031"))
+
+		stack.Annotations[utils.LISTENER_SELECTORS_ANNOTATION] = "listeners: [ a ]"
+		_, err = utils.StackListenerSelectors(stack)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should list the listener assets of a stack version", func() {
+		versionStatus := stackVersion("0.3.1", "docker.io/kabanero/nodejs", "listener-031")
+		versionStatus.Pipelines[0].Name = "default"
+		Expect(utils.ListenerAssets(&versionStatus)).To(Equal([]utils.ListenerAsset{{Pipeline: "default", Namespace: "tekton-pipelines", Name: "listener-031"}}))
+		stack := &kabanerov1alpha2.Stack{ObjectMeta: metav1.ObjectMeta{Name: "nodejs"}}
+		stack.Spec.Name = "nodejs-stack"
+		Expect(utils.StackKeys(stack, &versionStatus)).To(Equal([]string{"docker.io/kabanero/nodejs", "nodejs", "nodejs-stack"}))
+	})
+})
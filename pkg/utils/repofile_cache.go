@@ -0,0 +1,150 @@
+/*
+Copyright 2020 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"container/list"
+	"context"
+	"sync"
+
+	eventsv1alpha1 "github.com/kabanero-io/events-operator/pkg/apis/events/v1alpha1"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	DEFAULT_REPOSITORY_FILE_CACHE_SIZE = 512 // maximum number of repository files to cache
+)
+
+/* Identifies a file at one commit of a repository */
+type RepositoryFileKey struct {
+	Host  string
+	Owner string
+	Repo  string
+	Ref   string
+	File  string
+}
+
+/* Counts of the repository file cache */
+type RepositoryFileCacheStats struct {
+	Hits       int64 `json:"hits"`
+	Misses     int64 `json:"misses"`
+	Entries    int   `json:"entries"`
+	MaxEntries int   `json:"maxEntries"`
+}
+
+type repositoryFileEntry struct {
+	key     RepositoryFileKey
+	content []byte
+	found   bool
+}
+
+/* RepositoryFileCache is a size bounded LRU cache of files downloaded from repositories.
+   Files that are not found are cached too. Only files at a commit SHA are cached, as the content at a SHA does not change.
+*/
+type RepositoryFileCache struct {
+	maxEntries int
+	entries    map[RepositoryFileKey]*list.Element
+	lru        *list.List // most recently used first
+	hits       int64
+	misses     int64
+	mutex      sync.Mutex
+}
+
+func NewRepositoryFileCache(maxEntries int) *RepositoryFileCache {
+	if maxEntries <= 0 {
+		maxEntries = DEFAULT_REPOSITORY_FILE_CACHE_SIZE
+	}
+	return &RepositoryFileCache{
+		maxEntries: maxEntries,
+		entries:    make(map[RepositoryFileKey]*list.Element),
+		lru:        list.New(),
+	}
+}
+
+/* Return the content of a cached file, whether the file exists, and whether it is in the cache */
+func (cache *RepositoryFileCache) Get(key RepositoryFileKey) ([]byte, bool, bool) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	elem, ok := cache.entries[key]
+	if !ok {
+		cache.misses++
+		return nil, false, false
+	}
+	cache.hits++
+	cache.lru.MoveToFront(elem)
+	entry := elem.Value.(*repositoryFileEntry)
+	return entry.content, entry.found, true
+}
+
+/* Add a file to the cache, dropping the least recently used file if the cache is full */
+func (cache *RepositoryFileCache) Add(key RepositoryFileKey, content []byte, found bool) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	if elem, ok := cache.entries[key]; ok {
+		cache.lru.MoveToFront(elem)
+		entry := elem.Value.(*repositoryFileEntry)
+		entry.content = content
+		entry.found = found
+		return
+	}
+	cache.entries[key] = cache.lru.PushFront(&repositoryFileEntry{key: key, content: content, found: found})
+	for cache.lru.Len() > cache.maxEntries {
+		oldest := cache.lru.Back()
+		cache.lru.Remove(oldest)
+		delete(cache.entries, oldest.Value.(*repositoryFileEntry).key)
+	}
+}
+
+/* Return the hit and miss counts of the cache */
+func (cache *RepositoryFileCache) Stats() RepositoryFileCacheStats {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	return RepositoryFileCacheStats{
+		Hits:       cache.hits,
+		Misses:     cache.misses,
+		Entries:    cache.lru.Len(),
+		MaxEntries: cache.maxEntries,
+	}
+}
+
+/* Return the content of a file, and whether it exists, from the cache. The file is downloaded if it is not cached.
+   Errors are not cached. The file is not cached if the key has no ref, as the content of a branch may change.
+*/
+func (cache *RepositoryFileCache) GetOrDownload(key RepositoryFileKey, download func() ([]byte, bool, error)) ([]byte, bool, error) {
+	if key.Ref == "" {
+		return download()
+	}
+	if content, found, ok := cache.Get(key); ok {
+		klog.Infof("repository file %v/%v/%v at %v found in cache, exists: %v", key.Host, key.Owner, key.Repo, key.Ref, found)
+		return content, found, nil
+	}
+	content, found, err := download()
+	if err != nil {
+		return content, found, err
+	}
+	cache.Add(key, content, found)
+	return content, found, nil
+}
+
+/* DownloadYAML downloads a YAML file from the repository of a webhook message, like utils.DownloadYAML, through the cache */
+func (cache *RepositoryFileCache) DownloadYAML(ctx context.Context, kubeClient client.Client, namespace string, repo *eventsv1alpha1.EventGithubRepository, header map[string][]string, bodyMap map[string]interface{}, fileName string, ref string) (map[string]interface{}, bool, error) {
+	return downloadYAML(ctx, cache, kubeClient, namespace, repo, header, bodyMap, fileName, ref)
+}
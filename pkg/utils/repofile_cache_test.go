@@ -0,0 +1,95 @@
+package utils_test
+
+import (
+	"fmt"
+
+	"github.com/kabanero-io/events-operator/pkg/utils"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("TestRepositoryFileCache", func() {
+	key := func(ref string, file string) utils.RepositoryFileKey {
+		return utils.RepositoryFileKey{Host: "github.com", Owner: "kabanero-io", Repo: "demo", Ref: ref, File: file}
+	}
+
+	It("should download each file at a commit once", func() {
+		cache := utils.NewRepositoryFileCache(10)
+		downloads := 0
+		download := func() ([]byte, bool, error) {
+			downloads++
+			return []byte("stack: java"), true, nil
+		}
+		for i := 0; i < 3; i++ {
+			content, found, err := cache.GetOrDownload(key("abc123", ".appsody-config.yaml"), download)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeTrue())
+			Expect(string(content)).To(Equal("stack: java"))
+		}
+		Expect(downloads).To(Equal(1))
+
+		_, _, err := cache.GetOrDownload(key("def456", ".appsody-config.yaml"), download)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(downloads).To(Equal(2))
+		Expect(cache.Stats()).To(Equal(utils.RepositoryFileCacheStats{Hits: 2, Misses: 2, Entries: 2, MaxEntries: 10}))
+	})
+
+	It("should cache files that are not found, but not errors", func() {
+		cache := utils.NewRepositoryFileCache(10)
+		downloads := 0
+		notFound := func() ([]byte, bool, error) {
+			downloads++
+			return nil, false, nil
+		}
+		for i := 0; i < 2; i++ {
+			_, found, err := cache.GetOrDownload(key("abc123", "missing.yaml"), notFound)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeFalse())
+		}
+		Expect(downloads).To(Equal(1))
+
+		failed := func() ([]byte, bool, error) {
+			downloads++
+			return nil, false, fmt.Errorf("rate limited")
+		}
+		for i := 0; i < 2; i++ {
+			_, _, err := cache.GetOrDownload(key("abc123", "other.yaml"), failed)
+			Expect(err).To(HaveOccurred())
+		}
+		Expect(downloads).To(Equal(3))
+	})
+
+	It("should not cache files without a commit", func() {
+		cache := utils.NewRepositoryFileCache(10)
+		downloads := 0
+		download := func() ([]byte, bool, error) {
+			downloads++
+			return []byte("stack: java"), true, nil
+		}
+		for i := 0; i < 2; i++ {
+			_, _, err := cache.GetOrDownload(key("", ".appsody-config.yaml"), download)
+			Expect(err).ToNot(HaveOccurred())
+		}
+		Expect(downloads).To(Equal(2))
+		Expect(cache.Stats().Entries).To(Equal(0))
+	})
+
+	It("should drop the least recently used file when full", func() {
+		cache := utils.NewRepositoryFileCache(2)
+		cache.Add(key("1", "a"), []byte("1"), true)
+		cache.Add(key("2", "a"), []byte("2"), true)
+		_, _, ok := cache.Get(key("1", "a"))
+		Expect(ok).To(BeTrue())
+		cache.Add(key("3", "a"), []byte("3"), true)
+
+		_, _, ok = cache.Get(key("2", "a"))
+		Expect(ok).To(BeFalse())
+		content, found, ok := cache.Get(key("1", "a"))
+		Expect(ok).To(BeTrue())
+		Expect(found).To(BeTrue())
+		Expect(string(content)).To(Equal("1"))
+		_, _, ok = cache.Get(key("3", "a"))
+		Expect(ok).To(BeTrue())
+		Expect(cache.Stats().Entries).To(Equal(2))
+	})
+})
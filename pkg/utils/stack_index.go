@@ -0,0 +1,345 @@
+/*
+Copyright 2020 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	eventsv1alpha1 "github.com/kabanero-io/events-operator/pkg/apis/events/v1alpha1"
+	"github.com/kabanero-io/events-operator/pkg/semverimage"
+	kabanerov1alpha2 "github.com/kabanero-io/kabanero-operator/pkg/apis/kabanero/v1alpha2"
+	triggers "github.com/tektoncd/triggers/pkg/apis/triggers/v1alpha1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog"
+)
+
+/* Key of the versions of the stacks of a namespace with an image, or a name */
+type stackIndexKey struct {
+	namespace string
+	name      string
+}
+
+/* An active version of a stack, and the event listeners of its pipelines */
+type stackIndexEntry struct {
+	stack     types.NamespacedName
+	version   *semverimage.Version
+	images    []string
+	assets    []ListenerAsset
+	stackKeys []string                               // images and names of the stack, for the rules that apply to a stack
+	selectors []eventsv1alpha1.EventListenerSelector // rules of the annotation of the stack
+}
+
+/* The parts of an EventListener used to select and call it */
+type indexedListener struct {
+	url         string // "" if the listener has no address yet
+	labels      map[string]string
+	annotations map[string]string
+}
+
+/* Counts of the stack listener index */
+type StackListenerIndexStats struct {
+	Stacks    int   `json:"stacks"`
+	Listeners int   `json:"listeners"`
+	Lookups   int64 `json:"lookups"`
+	Rebuilds  int64 `json:"rebuilds"`
+	Synced    bool  `json:"synced"`
+}
+
+/* StackListenerIndex resolves stacks to the URLs of their Tekton event listeners without calls to the API server.
+   It is fed from the watches of Stacks and EventListeners, and keyed by image and by stack name, with the active versions of each key sorted
+   highest first. The keys are rebuilt on the first lookup after a change.
+   The index is only complete once the watches have synced, before which lookups should read the API server.
+*/
+type StackListenerIndex struct {
+	stacks    map[types.NamespacedName]*kabanerov1alpha2.Stack
+	listeners map[types.NamespacedName]*indexedListener
+	stale     bool               // true if stacks changed since the keys were built
+	active    []*stackIndexEntry // all active versions
+	byImage   map[stackIndexKey][]*stackIndexEntry
+	byName    map[stackIndexKey][]*stackIndexEntry // by metadata.name and spec.name of the stack
+	lookups   int64                                // updated atomically, as lookups hold the read lock
+	rebuilds  int64
+	synced    int32 // 1 once the watches have synced, updated atomically
+	mutex     sync.RWMutex
+}
+
+func NewStackListenerIndex() *StackListenerIndex {
+	return &StackListenerIndex{
+		stacks:    make(map[types.NamespacedName]*kabanerov1alpha2.Stack),
+		listeners: make(map[types.NamespacedName]*indexedListener),
+		byImage:   make(map[stackIndexKey][]*stackIndexEntry),
+		byName:    make(map[stackIndexKey][]*stackIndexEntry),
+	}
+}
+
+/* Mark the index as complete, once the watches that feed it have synced */
+func (index *StackListenerIndex) SetSynced() {
+	atomic.StoreInt32(&index.synced, 1)
+}
+
+/* Return true if the watches that feed the index have synced */
+func (index *StackListenerIndex) Synced() bool {
+	return atomic.LoadInt32(&index.synced) == 1
+}
+
+/* Add or replace a stack */
+func (index *StackListenerIndex) SetStack(stack *kabanerov1alpha2.Stack) {
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+
+	index.stacks[types.NamespacedName{Namespace: stack.Namespace, Name: stack.Name}] = stack.DeepCopy()
+	index.stale = true
+}
+
+func (index *StackListenerIndex) DeleteStack(namespace string, name string) {
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+
+	delete(index.stacks, types.NamespacedName{Namespace: namespace, Name: name})
+	index.stale = true
+}
+
+/* Add or replace an event listener. Only its URL, labels, and annotations are kept */
+func (index *StackListenerIndex) SetEventListener(listener *triggers.EventListener) {
+	indexed := &indexedListener{labels: listener.Labels, annotations: listener.Annotations}
+	if listener.Status.Address != nil && listener.Status.Address.URL != nil {
+		indexed.url = listener.Status.Address.URL.String()
+	}
+
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+
+	index.listeners[types.NamespacedName{Namespace: listener.Namespace, Name: listener.Name}] = indexed
+}
+
+func (index *StackListenerIndex) DeleteEventListener(namespace string, name string) {
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+
+	delete(index.listeners, types.NamespacedName{Namespace: namespace, Name: name})
+}
+
+/* Take the read lock, rebuilding the keys first if they are stale */
+func (index *StackListenerIndex) readLock() {
+	index.mutex.RLock()
+	for index.stale {
+		index.mutex.RUnlock()
+		index.mutex.Lock()
+		if index.stale {
+			index.rebuild()
+		}
+		index.mutex.Unlock()
+		index.mutex.RLock()
+	}
+}
+
+/* Return the active versions of a stack for which matches returns true, or all of them if matches is nil.
+   Versions that are not valid are logged and skipped, so that one stack with a bad version does not hide the listeners of the others.
+   Shared by the index and the lookups of the API server, so that both select the same listener */
+func activeStackVersions(stack *kabanerov1alpha2.Stack, matches func(*kabanerov1alpha2.Stack, *kabanerov1alpha2.StackVersionStatus) bool) []*stackIndexEntry {
+	stackKey := types.NamespacedName{Namespace: stack.Namespace, Name: stack.Name}
+	selectors, err := StackListenerSelectors(stack)
+	if err != nil {
+		klog.Error(err)
+	}
+	ret := make([]*stackIndexEntry, 0)
+	for versionIndex := range stack.Status.Versions {
+		versionStatus := &stack.Status.Versions[versionIndex]
+		if versionStatus.Status != ACTIVE {
+			continue
+		}
+		if matches != nil && !matches(stack, versionStatus) {
+			continue
+		}
+		version, err := semverimage.NewVersion(versionStatus.Version)
+		if err != nil {
+			klog.Errorf("Ignoring version %v of stack %v: %v", versionStatus.Version, stackKey, err)
+			continue
+		}
+		images := make([]string, 0, len(versionStatus.Images))
+		for _, image := range versionStatus.Images {
+			images = append(images, image.Image)
+		}
+		ret = append(ret, &stackIndexEntry{
+			stack:     stackKey,
+			version:   version,
+			images:    images,
+			assets:    ListenerAssets(versionStatus),
+			stackKeys: StackKeys(stack, versionStatus),
+			selectors: selectors,
+		})
+	}
+	return ret
+}
+
+/* Sort stack versions highest first. The same version of two stacks is sorted by stack */
+func sortStackVersions(entries []*stackIndexEntry) {
+	sort.Slice(entries, func(i, j int) bool {
+		if result := entries[i].version.Compare(entries[j].version); result != 0 {
+			return result > 0
+		}
+		return entries[i].stack.String() < entries[j].stack.String()
+	})
+}
+
+/* Return the first of the sorted stack versions that satisfies the constraint, and has a listener selected for the event, with the listener.
+   Any version satisfies a nil constraint */
+func selectStackListener(entries []*stackIndexEntry, constraint *semverimage.Constraint, selection *ListenerSelection, listenerMeta ListenerMetaFunc) (*stackIndexEntry, ListenerAsset, bool) {
+	for _, entry := range entries {
+		if constraint != nil && !constraint.Check(entry.version) {
+			continue
+		}
+		if asset, ok := SelectListener(entry.assets, entry.stackKeys, selection, entry.selectors, listenerMeta); ok {
+			return entry, asset, true
+		}
+	}
+	return nil, ListenerAsset{}, false
+}
+
+/* Rebuild the keys from the stacks. Called with the write lock held */
+func (index *StackListenerIndex) rebuild() {
+	active := make([]*stackIndexEntry, 0)
+	byImage := make(map[stackIndexKey][]*stackIndexEntry)
+	byName := make(map[stackIndexKey][]*stackIndexEntry)
+	for _, stack := range index.stacks {
+		for _, entry := range activeStackVersions(stack, nil) {
+			active = append(active, entry)
+
+			for _, image := range entry.images {
+				key := stackIndexKey{namespace: stack.Namespace, name: image}
+				byImage[key] = append(byImage[key], entry)
+			}
+			key := stackIndexKey{namespace: stack.Namespace, name: stack.Name}
+			byName[key] = append(byName[key], entry)
+			if stack.Spec.Name != "" && stack.Spec.Name != stack.Name {
+				key = stackIndexKey{namespace: stack.Namespace, name: stack.Spec.Name}
+				byName[key] = append(byName[key], entry)
+			}
+		}
+	}
+	for _, keys := range []map[stackIndexKey][]*stackIndexEntry{byImage, byName} {
+		for _, entries := range keys {
+			sortStackVersions(entries)
+		}
+	}
+	index.active = active
+	index.byImage = byImage
+	index.byName = byName
+	index.stale = false
+	index.rebuilds++
+}
+
+/* Return the labels and annotations of an indexed listener. Called with the read lock held */
+func (index *StackListenerIndex) listenerMeta(namespace string, name string) (map[string]string, map[string]string, bool) {
+	listener, ok := index.listeners[types.NamespacedName{Namespace: namespace, Name: name}]
+	if !ok {
+		return nil, nil, false
+	}
+	return listener.labels, listener.annotations, true
+}
+
+/* Find the URL of the event listener of the highest active version of entries that satisfies the constraint, and has a listener selected for the event.
+   Any version satisfies a nil constraint. Called with the read lock held */
+func (index *StackListenerIndex) find(entries []*stackIndexEntry, description string, constraint *semverimage.Constraint, selection *ListenerSelection) (string, string, error) {
+	entry, asset, ok := selectStackListener(entries, constraint, selection, index.listenerMeta)
+	if !ok {
+		klog.Errorf("Unable to find listener from stack for repo %v", description)
+		return "", "0.0.0", nil
+	}
+	key := types.NamespacedName{Namespace: asset.Namespace, Name: asset.Name}
+	listener, ok := index.listeners[key]
+	if !ok {
+		klog.Errorf("Unable to find listener %v in namespace %v", asset.Name, asset.Namespace)
+		return "", entry.version.String(), fmt.Errorf("Unable to find listener %v", key)
+	}
+	if listener.url == "" {
+		klog.Errorf("Listener %v has empty URL status", key)
+		return "", entry.version.String(), fmt.Errorf("Listener %v has empty URL status", key)
+	}
+	return listener.url, entry.version.String(), nil
+}
+
+/* FindEventListenerForStack is FindEventListenerForStack of the API server, from the index */
+func (index *StackListenerIndex) FindEventListenerForStack(namespace string, repoStackImage string, repoStackVersion string, selection *ListenerSelection) (string, string, error) {
+	constraint, err := semverimage.NewConstraint(repoStackVersion)
+	if err != nil {
+		return "", "", err
+	}
+
+	index.readLock()
+	defer index.mutex.RUnlock()
+
+	atomic.AddInt64(&index.lookups, 1)
+	return index.find(index.byImage[stackIndexKey{namespace: namespace, name: repoStackImage}], repoStackImage+":"+repoStackVersion, constraint, selection)
+}
+
+/* FindEventListenerForStackName is FindEventListenerForStackName of the API server, from the index */
+func (index *StackListenerIndex) FindEventListenerForStackName(namespace string, stackName string, stackVersion string, selection *ListenerSelection) (string, string, error) {
+	var constraint *semverimage.Constraint
+	if stackVersion != "" && stackVersion != "latest" {
+		var err error
+		constraint, err = semverimage.NewConstraint(stackVersion)
+		if err != nil {
+			return "", "", err
+		}
+	}
+
+	index.readLock()
+	defer index.mutex.RUnlock()
+
+	atomic.AddInt64(&index.lookups, 1)
+	return index.find(index.byName[stackIndexKey{namespace: namespace, name: stackName}], stackName+":"+stackVersion, constraint, selection)
+}
+
+/* StacksWithoutListeners returns the active stack versions, as namespace/name:version, that have no event listener with a URL
+   selected by the rules that apply to all events. Sorted */
+func (index *StackListenerIndex) StacksWithoutListeners() []string {
+	index.readLock()
+	defer index.mutex.RUnlock()
+
+	ret := make([]string, 0)
+	for _, entry := range index.active {
+		usable := make([]ListenerAsset, 0, len(entry.assets))
+		for _, asset := range entry.assets {
+			if listener, ok := index.listeners[types.NamespacedName{Namespace: asset.Namespace, Name: asset.Name}]; ok && listener.url != "" {
+				usable = append(usable, asset)
+			}
+		}
+		if _, ok := SelectListener(usable, entry.stackKeys, nil, entry.selectors, index.listenerMeta); ok {
+			continue
+		}
+		ret = append(ret, fmt.Sprintf("%v:%v", entry.stack, entry.version))
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+func (index *StackListenerIndex) Stats() StackListenerIndexStats {
+	index.mutex.RLock()
+	defer index.mutex.RUnlock()
+
+	return StackListenerIndexStats{
+		Stacks:    len(index.stacks),
+		Listeners: len(index.listeners),
+		Lookups:   atomic.LoadInt64(&index.lookups),
+		Rebuilds:  index.rebuilds,
+		Synced:    index.Synced(),
+	}
+}
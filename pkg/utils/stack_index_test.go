@@ -0,0 +1,167 @@
+package utils_test
+
+import (
+	"context"
+
+	"github.com/kabanero-io/events-operator/pkg/utils"
+	kab_operator "github.com/kabanero-io/kabanero-operator/pkg/apis"
+	kabanerov1alpha2 "github.com/kabanero-io/kabanero-operator/pkg/apis/kabanero/v1alpha2"
+	triggers "github.com/tektoncd/triggers/pkg/apis/triggers/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+/* An active stack version with an image, and an event listener unless listener is "" */
+func stackVersion(version string, image string, listener string) kabanerov1alpha2.StackVersionStatus {
+	ret := kabanerov1alpha2.StackVersionStatus{
+		Version: version,
+		Status:  utils.ACTIVE,
+		Images:  []kabanerov1alpha2.ImageStatus{{Image: image}},
+	}
+	if listener != "" {
+		ret.Pipelines = []kabanerov1alpha2.PipelineStatus{{
+			ActiveAssets: []kabanerov1alpha2.RepositoryAssetStatus{
+				{Name: listener, Namespace: "tekton-pipelines", Group: utils.TRIGGER_TEKTON_DEV, Kind: utils.EVENT_LISTENER},
+			},
+		}}
+	}
+	return ret
+}
+
+func eventListener(name string, hostname string) *triggers.EventListener {
+	listener := &triggers.EventListener{ObjectMeta: metav1.ObjectMeta{Namespace: "tekton-pipelines", Name: name}}
+	listener.Status.SetAddress(hostname)
+	return listener
+}
+
+var _ = Describe("TestStackIndex", func() {
+	var index *utils.StackListenerIndex
+
+	BeforeEach(func() {
+		index = utils.NewStackListenerIndex()
+		stack := &kabanerov1alpha2.Stack{ObjectMeta: metav1.ObjectMeta{Namespace: "kabanero", Name: "nodejs"}}
+		stack.Spec.Name = "nodejs-stack"
+		stack.Status.Versions = []kabanerov1alpha2.StackVersionStatus{
+			stackVersion("0.3.1", "docker.io/kabanero/nodejs", "listener-031"),
+			stackVersion("0.4.0-rc.1", "docker.io/kabanero/nodejs", "listener-040"),
+			stackVersion("0.3.6", "docker.io/kabanero/nodejs", "listener-036"),
+			stackVersion("0.2.0", "docker.io/kabanero/nodejs", ""),
+		}
+		index.SetStack(stack)
+		index.SetEventListener(eventListener("listener-031", "el-listener-031.tekton-pipelines.svc.cluster.local:8080"))
+		index.SetEventListener(eventListener("listener-036", "el-listener-036.tekton-pipelines.svc.cluster.local:8080"))
+		index.SetEventListener(eventListener("listener-040", "el-listener-040.tekton-pipelines.svc.cluster.local:8080"))
+	})
+
+	It("should find the listener of the highest version that satisfies the constraint", func() {
+		url, version, err := index.FindEventListenerForStack("kabanero", "docker.io/kabanero/nodejs", "0.3", nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(url).To(Equal("http://el-listener-036.tekton-pipelines.svc.cluster.local:8080"))
+		Expect(version).To(Equal("0.3.6"))
+
+		url, version, err = index.FindEventListenerForStack("kabanero", "docker.io/kabanero/nodejs", ">=0.4.0-rc.1", nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(url).To(Equal("http://el-listener-040.tekton-pipelines.svc.cluster.local:8080"))
+		Expect(version).To(Equal("0.4.0-rc.1"))
+
+		url, _, err = index.FindEventListenerForStack("kabanero", "docker.io/kabanero/java", "0.3", nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(url).To(BeEmpty())
+
+		url, _, err = index.FindEventListenerForStack("other", "docker.io/kabanero/nodejs", "0.3", nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(url).To(BeEmpty())
+	})
+
+	It("should find stacks by name and spec name", func() {
+		url, version, err := index.FindEventListenerForStackName("kabanero", "nodejs-stack", "^0.3", nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(url).To(Equal("http://el-listener-036.tekton-pipelines.svc.cluster.local:8080"))
+		Expect(version).To(Equal("0.3.6"))
+
+		_, version, err = index.FindEventListenerForStackName("kabanero", "nodejs", "~0.3.1 <0.3.5", nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(version).To(Equal("0.3.1"))
+	})
+
+	It("should follow changes of stacks and listeners", func() {
+		Expect(index.StacksWithoutListeners()).To(Equal([]string{"kabanero/nodejs:0.2.0"}))
+
+		index.SetEventListener(eventListener("listener-036", ""))
+		_, _, err := index.FindEventListenerForStack("kabanero", "docker.io/kabanero/nodejs", "0.3", nil)
+		Expect(err).To(HaveOccurred())
+		Expect(index.StacksWithoutListeners()).To(Equal([]string{"kabanero/nodejs:0.2.0", "kabanero/nodejs:0.3.6"}))
+
+		index.DeleteEventListener("tekton-pipelines", "listener-031")
+		Expect(index.StacksWithoutListeners()).To(Equal([]string{"kabanero/nodejs:0.2.0", "kabanero/nodejs:0.3.1", "kabanero/nodejs:0.3.6"}))
+
+		stack := &kabanerov1alpha2.Stack{ObjectMeta: metav1.ObjectMeta{Namespace: "kabanero", Name: "nodejs"}}
+		stack.Status.Versions = []kabanerov1alpha2.StackVersionStatus{stackVersion("0.4.0-rc.1", "docker.io/kabanero/nodejs", "listener-040")}
+		index.SetStack(stack)
+		Expect(index.StacksWithoutListeners()).To(BeEmpty())
+		url, _, err := index.FindEventListenerForStack("kabanero", "docker.io/kabanero/nodejs", "0.3", nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(url).To(BeEmpty())
+
+		index.DeleteStack("kabanero", "nodejs")
+		url, _, err = index.FindEventListenerForStackName("kabanero", "nodejs", "", nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(url).To(BeEmpty())
+
+		stats := index.Stats()
+		Expect(stats.Stacks).To(Equal(0))
+		Expect(stats.Listeners).To(Equal(2))
+		Expect(stats.Lookups).To(Equal(int64(3)))
+		Expect(stats.Rebuilds).To(Equal(int64(3)))
+		Expect(stats.Synced).To(BeFalse())
+	})
+
+	It("should select the same listener as the API server, and skip versions that are not valid", func() {
+		broken := &kabanerov1alpha2.Stack{ObjectMeta: metav1.ObjectMeta{Namespace: "kabanero", Name: "java"}}
+		broken.Status.Versions = []kabanerov1alpha2.StackVersionStatus{
+			stackVersion("latest", "docker.io/kabanero/nodejs", "listener-bad"),
+			stackVersion("0.3.6", "docker.io/kabanero/nodejs", "listener-java"),
+		}
+		index.SetStack(broken)
+		index.SetEventListener(eventListener("listener-bad", "el-listener-bad.tekton-pipelines.svc.cluster.local:8080"))
+		index.SetEventListener(eventListener("listener-java", "el-listener-java.tekton-pipelines.svc.cluster.local:8080"))
+
+		scheme := runtime.NewScheme()
+		Expect(kab_operator.AddToScheme(scheme)).To(Succeed())
+		Expect(triggers.AddToScheme(scheme)).To(Succeed())
+		stack := &kabanerov1alpha2.Stack{ObjectMeta: metav1.ObjectMeta{Namespace: "kabanero", Name: "nodejs"}}
+		stack.Status.Versions = []kabanerov1alpha2.StackVersionStatus{
+			stackVersion("0.3.6", "docker.io/kabanero/nodejs", "listener-036"),
+			stackVersion("0.3.1", "docker.io/kabanero/nodejs", "listener-031"),
+		}
+		kubeClient := fake.NewFakeClientWithScheme(scheme, broken, stack,
+			eventListener("listener-bad", "el-listener-bad.tekton-pipelines.svc.cluster.local:8080"),
+			eventListener("listener-java", "el-listener-java.tekton-pipelines.svc.cluster.local:8080"),
+			eventListener("listener-031", "el-listener-031.tekton-pipelines.svc.cluster.local:8080"),
+			eventListener("listener-036", "el-listener-036.tekton-pipelines.svc.cluster.local:8080"))
+
+		/* the same version of two stacks selects the listener of the first stack by name */
+		for _, constraint := range []string{"0.3", "0.3.1"} {
+			indexURL, indexVersion, err := index.FindEventListenerForStack("kabanero", "docker.io/kabanero/nodejs", constraint, nil)
+			Expect(err).ToNot(HaveOccurred())
+			url, version, err := utils.FindEventListenerForStack(context.Background(), kubeClient, "kabanero", "docker.io/kabanero/nodejs", constraint, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(url).To(Equal(indexURL))
+			Expect(version).To(Equal(indexVersion))
+		}
+		url, _, err := utils.FindEventListenerForStack(context.Background(), kubeClient, "kabanero", "docker.io/kabanero/nodejs", "0.3", nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(url).To(Equal("http://el-listener-java.tekton-pipelines.svc.cluster.local:8080"))
+	})
+
+	It("should record when the watches have synced", func() {
+		Expect(index.Synced()).To(BeFalse())
+		index.SetSynced()
+		Expect(index.Synced()).To(BeTrue())
+		Expect(index.Stats().Synced).To(BeTrue())
+	})
+})
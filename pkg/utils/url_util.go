@@ -0,0 +1,140 @@
+/*
+Copyright 2020 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	eventsv1alpha1 "github.com/kabanero-io/events-operator/pkg/apis/events/v1alpha1"
+)
+
+/* Return true if the URL pattern is a glob or regular expression, and not a plain path */
+func IsURLPattern(pattern string) bool {
+	return strings.HasPrefix(pattern, "^") || strings.ContainsAny(pattern, "{*?[")
+}
+
+/* The compiled urlPattern of a selector */
+type URLPattern struct {
+	pattern  string         // the pattern without its leading "/"
+	regex    *regexp.Regexp // the regular expression, or nil
+	segments []string       // the segments of a glob, or nil
+}
+
+/*
+CompileURLPattern compiles the urlPattern of a selector, so that it is validated once, and not for each request.
+The pattern is one of:
+  - a regular expression, if it starts with "^". Named groups such as (?P<env>dev|prod) are captured.
+  - a glob, where each segment of the path matches one segment of the pattern. The segment {name} matches any segment,
+    which is captured as name. Other segments may contain the wildcards *, ?, and [] of path.Match.
+  - a plain path, which must be equal to the path.
+A leading "/" is ignored.
+*/
+func CompileURLPattern(pattern string) (*URLPattern, error) {
+	if strings.HasPrefix(pattern, "^") {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid urlPattern %v: %v", pattern, err)
+		}
+		return &URLPattern{pattern: pattern, regex: re}, nil
+	}
+
+	pattern = strings.TrimPrefix(pattern, "/")
+	if !IsURLPattern(pattern) {
+		return &URLPattern{pattern: pattern}, nil
+	}
+	segments := strings.Split(pattern, "/")
+	for _, segment := range segments {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			if segment == "{}" {
+				return nil, fmt.Errorf("empty parameter name in urlPattern %v", pattern)
+			}
+			continue
+		}
+		if _, err := path.Match(segment, ""); err != nil {
+			return nil, fmt.Errorf("invalid urlPattern %v: %v", pattern, err)
+		}
+	}
+	return &URLPattern{pattern: pattern, segments: segments}, nil
+}
+
+/* Match the path of a request, without regard to a leading "/". Return whether the path matches, and the captured parameters, which are nil for a plain path */
+func (urlPattern *URLPattern) Match(urlPath string) (bool, map[string]string) {
+	urlPath = strings.TrimPrefix(urlPath, "/")
+	if urlPattern.regex != nil {
+		return urlPattern.matchRegex(urlPath)
+	}
+	if urlPattern.segments == nil {
+		return urlPattern.pattern == urlPath, nil
+	}
+
+	pathSegments := strings.Split(urlPath, "/")
+	params := make(map[string]string)
+	if len(urlPattern.segments) != len(pathSegments) {
+		return false, params
+	}
+	for index, patternSegment := range urlPattern.segments {
+		pathSegment := pathSegments[index]
+		if strings.HasPrefix(patternSegment, "{") && strings.HasSuffix(patternSegment, "}") {
+			if pathSegment == "" {
+				return false, params
+			}
+			params[patternSegment[1:len(patternSegment)-1]] = pathSegment
+			continue
+		}
+		/* the segment was validated by CompileURLPattern */
+		if matched, _ := path.Match(patternSegment, pathSegment); !matched {
+			return false, params
+		}
+	}
+	return true, params
+}
+
+func (urlPattern *URLPattern) matchRegex(urlPath string) (bool, map[string]string) {
+	params := make(map[string]string)
+	submatches := urlPattern.regex.FindStringSubmatch(urlPath)
+	if submatches == nil {
+		return false, params
+	}
+	for index, name := range urlPattern.regex.SubexpNames() {
+		if name != "" {
+			params[name] = submatches[index]
+		}
+	}
+	return true, params
+}
+
+/* CompileURLPatterns compiles the urlPattern of each mediation of a mediator that has a selector, by name of mediation */
+func CompileURLPatterns(mediator *eventsv1alpha1.EventMediator) (map[string]*URLPattern, error) {
+	ret := make(map[string]*URLPattern)
+	if mediator.Spec.Mediations == nil {
+		return ret, nil
+	}
+	for _, mediation := range *mediator.Spec.Mediations {
+		if mediation.Selector == nil {
+			continue
+		}
+		urlPattern, err := CompileURLPattern(mediation.Selector.UrlPattern)
+		if err != nil {
+			return nil, fmt.Errorf("mediation %v of mediator %v: %v", mediation.Name, mediator.Name, err)
+		}
+		ret[mediation.Name] = urlPattern
+	}
+	return ret, nil
+}
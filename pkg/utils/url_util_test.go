@@ -0,0 +1,82 @@
+package utils_test
+
+import (
+	eventsv1alpha1 "github.com/kabanero-io/events-operator/pkg/apis/events/v1alpha1"
+	"github.com/kabanero-io/events-operator/pkg/utils"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("TestURLPattern", func() {
+	compile := func(pattern string) *utils.URLPattern {
+		urlPattern, err := utils.CompileURLPattern(pattern)
+		Expect(err).ToNot(HaveOccurred())
+		return urlPattern
+	}
+
+	It("should match plain paths exactly", func() {
+		urlPattern := compile("webhook")
+		matched, params := urlPattern.Match("/webhook")
+		Expect(matched).To(BeTrue())
+		Expect(params).To(BeNil())
+
+		matched, _ = urlPattern.Match("/webhook/other")
+		Expect(matched).To(BeFalse())
+	})
+
+	It("should match globs and capture parameters", func() {
+		urlPattern := compile("/teams/{team}/build")
+		matched, params := urlPattern.Match("/teams/blue/build")
+		Expect(matched).To(BeTrue())
+		Expect(params).To(Equal(map[string]string{"team": "blue"}))
+
+		matched, _ = urlPattern.Match("/teams/blue/deploy")
+		Expect(matched).To(BeFalse())
+
+		matched, _ = urlPattern.Match("/teams//build")
+		Expect(matched).To(BeFalse())
+
+		matched, params = compile("hooks/*/{env}").Match("hooks/web/dev")
+		Expect(matched).To(BeTrue())
+		Expect(params).To(Equal(map[string]string{"env": "dev"}))
+	})
+
+	It("should match regular expressions and capture named groups", func() {
+		urlPattern := compile("^hooks/(?P<env>dev|prod)$")
+		matched, params := urlPattern.Match("/hooks/prod")
+		Expect(matched).To(BeTrue())
+		Expect(params).To(Equal(map[string]string{"env": "prod"}))
+
+		matched, _ = urlPattern.Match("/hooks/test")
+		Expect(matched).To(BeFalse())
+	})
+
+	It("should reject invalid patterns when they are compiled", func() {
+		for _, pattern := range []string{"hooks/{}", "^hooks/(", "hooks/[a-"} {
+			_, err := utils.CompileURLPattern(pattern)
+			Expect(err).To(HaveOccurred(), pattern)
+		}
+	})
+
+	It("should compile the urlPattern of each mediation with a selector", func() {
+		mediations := []eventsv1alpha1.EventMediationImpl{
+			{Name: "build", Selector: &eventsv1alpha1.EventMediationSelector{UrlPattern: "/teams/{team}/build"}},
+			{Name: "other"},
+		}
+		mediator := &eventsv1alpha1.EventMediator{
+			ObjectMeta: metav1.ObjectMeta{Name: "webhook"},
+			Spec:       eventsv1alpha1.EventMediatorSpec{Mediations: &mediations},
+		}
+		urlPatterns, err := utils.CompileURLPatterns(mediator)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(urlPatterns).To(HaveLen(1))
+		matched, _ := urlPatterns["build"].Match("/teams/blue/build")
+		Expect(matched).To(BeTrue())
+
+		mediations[1].Selector = &eventsv1alpha1.EventMediationSelector{UrlPattern: "^teams/("}
+		_, err = utils.CompileURLPatterns(mediator)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("other"))
+	})
+})
@@ -125,9 +125,30 @@ func YAMLToMap(bytes []byte) (map[string]interface{}, error) {
 	if err != nil {
 		return nil, err
 	}
+	for key, value := range myMap {
+		myMap[key] = convertYAMLValue(value)
+	}
 	return myMap, nil
 }
 
+/* Convert the map[interface{}]interface{} of nested YAML maps to map[string]interface{}, like the maps of JSON messages */
+func convertYAMLValue(value interface{}) interface{} {
+	switch typed := value.(type) {
+	case map[interface{}]interface{}:
+		ret := make(map[string]interface{}, len(typed))
+		for key, elem := range typed {
+			ret[fmt.Sprintf("%v", key)] = convertYAMLValue(elem)
+		}
+		return ret
+	case []interface{}:
+		for index, elem := range typed {
+			typed[index] = convertYAMLValue(elem)
+		}
+		return typed
+	}
+	return value
+}
+
 /*
 DownloadTrigger Download the trigger.tar.gz and unpack into the directory
   triggerURL: URL that serves the trigger gzipped tar
@@ -220,7 +241,8 @@ func getHash(hashType string) (HashFunc, error) {
 	return nil, fmt.Errorf("unrecognized hash type '%s'", hashType)
 }
 
-func hashPayload(sigType, secret string, payload []byte) (string, error) {
+// HashPayload returns the hex encoded HMAC of the payload, using hash type sha1 or sha256
+func HashPayload(sigType, secret string, payload []byte) (string, error) {
 	h, err := getHash(sigType)
 	if err != nil {
 		return "", err
@@ -233,7 +255,7 @@ func hashPayload(sigType, secret string, payload []byte) (string, error) {
 
 // ValidatePayload verifies that a payload hashed with some secret matches the expected signature
 func ValidatePayload(sigType, sigHash, secret string, payload []byte) error {
-	hashedPayload, err := hashPayload(sigType, secret, payload)
+	hashedPayload, err := HashPayload(sigType, secret, payload)
 	if err != nil {
 		return err
 	}